@@ -26,6 +26,7 @@ import (
 
 	configcmd "github.com/redhat-marketplace/datactl/cmd/datactl/app/config"
 	"github.com/redhat-marketplace/datactl/cmd/datactl/app/metering"
+	"github.com/redhat-marketplace/datactl/cmd/datactl/app/mustgather"
 	"github.com/redhat-marketplace/datactl/cmd/datactl/app/sources"
 	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
@@ -158,6 +159,7 @@ func NewDatactlCommand(in io.Reader, out, err io.Writer) *cobra.Command {
 			Commands: []*cobra.Command{
 				metering.NewCmdExport(rhmConfigFlags, f, ioStreams),
 				sources.NewCmdSources(rhmConfigFlags, f, ioStreams),
+				metering.NewCmdRun(rhmConfigFlags, f, ioStreams),
 			},
 		},
 		{
@@ -166,6 +168,12 @@ func NewDatactlCommand(in io.Reader, out, err io.Writer) *cobra.Command {
 				configcmd.NewCmdConfig(rhmConfigFlags, f, ioStreams),
 			},
 		},
+		{
+			Message: "Troubleshooting Commands:",
+			Commands: []*cobra.Command{
+				mustgather.NewCmdMustGather(rhmConfigFlags, f, ioStreams),
+			},
+		},
 	}
 	groups.Add(cmds)
 
@@ -18,7 +18,11 @@ import (
 	"fmt"
 
 	"github.com/go-logr/logr"
+	"github.com/redhat-marketplace/datactl/pkg/bundle"
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	dataservicev1 "github.com/redhat-marketplace/datactl/pkg/datactl/api/dataservice/v1"
 	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers/output"
 	"github.com/spf13/cobra"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/klog/v2/klogr"
@@ -43,6 +47,13 @@ func NewCmdExport(rhmFlags *config.ConfigFlags, f cmdutil.Factory, ioStreams gen
 	cmd.AddCommand(NewCmdExportPull(rhmFlags, f, ioStreams))
 	cmd.AddCommand(NewCmdExportCommit(rhmFlags, f, ioStreams))
 	cmd.AddCommand(NewCmdExportPush(rhmFlags, f, ioStreams))
+	cmd.AddCommand(NewCmdExportSync(rhmFlags, f, ioStreams))
+	cmd.AddCommand(NewCmdExportStatus(rhmFlags, f, ioStreams))
+	cmd.AddCommand(NewCmdExportNew(rhmFlags, f, ioStreams))
+	cmd.AddCommand(NewCmdExportList(rhmFlags, f, ioStreams))
+	cmd.AddCommand(NewCmdExportUse(rhmFlags, f, ioStreams))
+	cmd.AddCommand(NewCmdExportInspect(rhmFlags, f, ioStreams))
+	cmd.AddCommand(NewCmdExportRepair(rhmFlags, f, ioStreams))
 
 	return cmd
 }
@@ -63,6 +74,33 @@ func NewCmdExport(rhmFlags *config.ConfigFlags, f cmdutil.Factory, ioStreams gen
 // > Export started with
 // datactl
 
+// reportRecovery warns about the entries lost from a bundle that was not
+// closed properly and marks them with an error so they are pulled again.
+func reportRecovery(p *output.HumanOutput, export *datactlapi.MeteringExport, recovery *bundle.Recovery) {
+	if recovery == nil {
+		return
+	}
+
+	p.WithDetails("exportFile", export.FileName, "truncated", recovery.Truncated).
+		Warnf(i18n.T("export file was not closed properly and has been recovered"))
+
+	lost := map[string]interface{}{}
+	for _, name := range recovery.Lost {
+		lost[name] = nil
+		p.Sub().WithDetails("name", name).Warnf(i18n.T("file lost, pull again to restore it"))
+	}
+
+	for _, file := range export.Files {
+		if _, ok := lost[file.Name]; !ok {
+			continue
+		}
+
+		file.Result = dataservicev1.Error
+		file.Error = "file lost from export file, pull again"
+		file.Pushed = false
+	}
+}
+
 func helpErrorf(cmd *cobra.Command, format string, args ...interface{}) error {
 	cmd.Help()
 	msg := fmt.Sprintf(format, args...)
@@ -18,9 +18,11 @@ import (
 	"context"
 	"time"
 
+	"emperror.dev/errors"
 	"github.com/redhat-marketplace/datactl/pkg/bundle"
 	"github.com/redhat-marketplace/datactl/pkg/clients/dataservice"
 	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	dataservicev1 "github.com/redhat-marketplace/datactl/pkg/datactl/api/dataservice/v1"
 	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
 	"github.com/redhat-marketplace/datactl/pkg/printers"
 	"github.com/redhat-marketplace/datactl/pkg/printers/output"
@@ -39,7 +41,10 @@ var (
 
 		Committing indicates that the user will be delivering the files for
 		processing by using the "{{ .cmd }} export push" command. Commiting files
-		are recorded in the datactl config file.`))
+		are recorded in the datactl config file.
+
+		Files that are not pushed are skipped and listed, so the sources keep the
+		files that didn't reach the upload API. Use --force to commit them anyway.`))
 
 	commitExample = templates.Examples(i18n.T(`
 		# Commit all files in the active export file.
@@ -47,6 +52,13 @@ var (
 
 		# Run the commit but perform no actions (dry-run).
 		{{ .cmd }} export commit --dry-run
+
+		# Commit a single file by name, or by its dataservice id
+		{{ .cmd }} export commit --file rhm-upload-20211111T000959Z.tar.gz
+		{{ .cmd }} export commit --id 6a8b1e0c-0d1e-4b7f-9d2a-3c4e5f6a7b8c
+
+		# Commit files even if they are not pushed
+		{{ .cmd }} export commit --force
 `))
 )
 
@@ -58,7 +70,7 @@ func NewCmdExportCommit(rhmFlags *config.ConfigFlags, f cmdutil.Factory, ioStrea
 	}
 
 	cmd := &cobra.Command{
-		Use:                   "commit [(--dry-run)]",
+		Use:                   "commit [(--dry-run)] [(--force)] [(--file FILE)...] [(--id ID)...]",
 		DisableFlagsInUseLine: true,
 		Short:                 i18n.T("Finalizes the download of files."),
 		Long:                  output.ReplaceCommandStrings(commitLong),
@@ -77,6 +89,9 @@ func NewCmdExportCommit(rhmFlags *config.ConfigFlags, f cmdutil.Factory, ioStrea
 	cmd.Flags().MarkHidden("show-labels")
 
 	cmd.Flags().BoolVar(&o.dryRun, "dry-run", false, i18n.T("No action taken. Print only."))
+	cmd.Flags().BoolVar(&o.force, "force", false, i18n.T("commit files that are not pushed"))
+	cmd.Flags().StringSliceVar(&o.fileNames, "file", nil, i18n.T("name of a file of the active export to commit, all files if not set"))
+	cmd.Flags().StringSliceVar(&o.fileIDs, "id", nil, i18n.T("dataservice id of a file of the active export to commit, all files if not set"))
 	return cmd
 }
 
@@ -84,7 +99,10 @@ type exportCommitOptions struct {
 	rhmConfigFlags *config.ConfigFlags
 	PrintFlags     *get.PrintFlags
 
-	dryRun bool
+	dryRun    bool
+	force     bool
+	fileNames []string
+	fileIDs   []string
 
 	//internal
 	args        []string
@@ -96,6 +114,9 @@ type exportCommitOptions struct {
 	currentMeteringExport *datactlapi.MeteringExport
 	bundle                *bundle.BundleFile
 
+	// errs are the errors of the sources that failed to commit
+	errs []error
+
 	printer printers.Printer
 
 	genericclioptions.IOStreams
@@ -139,6 +160,32 @@ func (c *exportCommitOptions) Complete(cmd *cobra.Command, args []string) error
 }
 
 func (c *exportCommitOptions) Validate() error {
+	names := map[string]bool{}
+	ids := map[string]bool{}
+
+	for _, file := range c.currentMeteringExport.Files {
+		if file.FileInfo == nil {
+			continue
+		}
+
+		names[file.Name] = true
+		if file.Id != "" {
+			ids[file.Id] = true
+		}
+	}
+
+	for _, name := range c.fileNames {
+		if !names[name] {
+			return errors.NewWithDetails("file is not in the active export", "file", name)
+		}
+	}
+
+	for _, id := range c.fileIDs {
+		if !ids[id] {
+			return errors.NewWithDetails("file id is not in the active export", "id", id)
+		}
+	}
+
 	return nil
 }
 
@@ -163,18 +210,26 @@ func (c *exportCommitOptions) Run() error {
 		}
 
 		p = p.Sub()
+		reportRecovery(p, c.currentMeteringExport, c.bundle.Recovery())
 		p.WithDetails("exportFile", c.currentMeteringExport.FileName).Infof(i18n.T("file commit status:"))
 		return p
 	})
 
-	errs := []error{}
+	c.errs = []error{}
 	committed := 0
 
+	opts := sources.NewOptions(
+		sources.DryRun, c.dryRun,
+		sources.Force, c.force,
+		sources.CommitFileNames, c.fileNames,
+		sources.CommitFileIDs, c.fileIDs,
+	)
+
 	for name := range c.rhmRawConfig.Sources {
 		s := c.rhmRawConfig.Sources[name]
 		source, err := c.Factory.FromSource(*s)
 		if err != nil {
-			errs = append(errs, err)
+			c.errs = append(c.errs, err)
 			continue
 		}
 
@@ -190,9 +245,9 @@ func (c *exportCommitOptions) Run() error {
 			return p
 		})
 
-		count, err := commitSource.Commit(ctx, c.currentMeteringExport, c.bundle, sources.EmptyOptions())
+		count, err := commitSource.Commit(ctx, c.currentMeteringExport, c.bundle, opts)
 		if err != nil {
-			errs = append(errs, err)
+			c.errs = append(c.errs, err)
 		}
 		committed += count
 
@@ -211,7 +266,15 @@ func (c *exportCommitOptions) Run() error {
 		p := ho
 		p.WithDetails("committed", committed, "files", len(c.currentMeteringExport.Files)).Infof(i18n.T("commit finished"))
 
-		if len(errs) != 0 {
+		if skipped := c.skippedFiles(); len(skipped) != 0 {
+			p.WithDetails("files", len(skipped)).Warnf(i18n.T("files not pushed were skipped; push them or use --force"))
+			p2 := p.Sub()
+			for _, name := range skipped {
+				p2.WithDetails("name", name).Warnf(i18n.T("not pushed"))
+			}
+		}
+
+		if len(c.errs) != 0 {
 			p.Errorf(nil, "errors have occurred")
 		}
 		return p
@@ -228,3 +291,32 @@ func (c *exportCommitOptions) Run() error {
 
 	return nil
 }
+
+// skippedFiles returns the names of the selected files that were skipped
+// because they are not pushed.
+func (c *exportCommitOptions) skippedFiles() []string {
+	names := map[string]bool{}
+	for _, name := range c.fileNames {
+		names[name] = true
+	}
+
+	ids := map[string]bool{}
+	for _, id := range c.fileIDs {
+		ids[id] = true
+	}
+
+	skipped := []string{}
+	for _, file := range c.currentMeteringExport.Files {
+		if file.FileInfo == nil || file.Action != dataservicev1.Commit || file.Result != dataservicev1.Skipped {
+			continue
+		}
+
+		if (len(names) != 0 || len(ids) != 0) && !names[file.Name] && !(file.Id != "" && ids[file.Id]) {
+			continue
+		}
+
+		skipped = append(skipped, file.Name)
+	}
+
+	return skipped
+}
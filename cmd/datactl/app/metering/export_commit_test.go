@@ -0,0 +1,71 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metering
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	dataservicev1 "github.com/redhat-marketplace/datactl/pkg/datactl/api/dataservice/v1"
+)
+
+var _ = Describe("export_commit", func() {
+	var (
+		sut     *exportCommitOptions
+		pushed  *dataservicev1.FileInfoCTLAction
+		skipped *dataservicev1.FileInfoCTLAction
+	)
+
+	BeforeEach(func() {
+		pushed = newPushedFile("a.tar.gz", "a")
+		pushed.Id = "1"
+
+		skipped = newPushedFile("b.tar.gz", "b")
+		skipped.Id = "2"
+		skipped.Pushed = false
+		skipped.Action = dataservicev1.Commit
+		skipped.Result = dataservicev1.Skipped
+
+		sut = &exportCommitOptions{
+			currentMeteringExport: &datactlapi.MeteringExport{
+				Files: []*dataservicev1.FileInfoCTLAction{pushed, skipped},
+			},
+		}
+	})
+
+	It("should only select files of the active export", func() {
+		sut.fileNames = []string{"a.tar.gz"}
+		sut.fileIDs = []string{"2"}
+		Expect(sut.Validate()).To(Succeed())
+
+		sut.fileNames = []string{"missing.tar.gz"}
+		Expect(sut.Validate()).ToNot(Succeed())
+
+		sut.fileNames = nil
+		sut.fileIDs = []string{"3"}
+		Expect(sut.Validate()).ToNot(Succeed())
+	})
+
+	It("should list the selected files that were skipped", func() {
+		Expect(sut.skippedFiles()).To(Equal([]string{"b.tar.gz"}))
+
+		sut.fileIDs = []string{"2"}
+		Expect(sut.skippedFiles()).To(Equal([]string{"b.tar.gz"}))
+
+		sut.fileIDs = []string{"1"}
+		Expect(sut.skippedFiles()).To(BeEmpty())
+	})
+})
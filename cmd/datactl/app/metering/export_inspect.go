@@ -0,0 +1,149 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metering
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/redhat-marketplace/datactl/pkg/bundle"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers/output"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var (
+	inspectLong = templates.LongDesc(i18n.T(`
+		Lists the entries of a bundle file with the metadata recorded when they
+		were pulled: source, source type, remote id, checksum, pull time and
+		export name.
+
+		The metadata is read from the bundle itself, so a bundle copied from another
+		machine can be inspected without its datactl config file.`))
+
+	inspectExample = templates.Examples(i18n.T(`
+		# Inspect the bundle of the active export
+		{{ .cmd }} export inspect
+
+		# Inspect a specific bundle file
+		{{ .cmd }} export inspect --file={{ .defaultDataPath }}/rhm-upload-20211111T000959Z.tar
+`))
+)
+
+func NewCmdExportInspect(rhmFlags *config.ConfigFlags, f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := exportInspectOptions{
+		rhmConfigFlags: rhmFlags,
+		IOStreams:      ioStreams,
+	}
+
+	cmd := &cobra.Command{
+		Use:                   "inspect [(--file FILE)]",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Lists the entries of a bundle file."),
+		Long:                  output.ReplaceCommandStrings(inspectLong),
+		Example:               output.ReplaceCommandStrings(inspectExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVar(&o.file, "file", "", i18n.T("bundle file to inspect, defaults to the active export"))
+
+	return cmd
+}
+
+type exportInspectOptions struct {
+	rhmConfigFlags *config.ConfigFlags
+
+	file string
+
+	//internal
+	args []string
+
+	genericclioptions.IOStreams
+}
+
+func (e *exportInspectOptions) Complete(cmd *cobra.Command, args []string) error {
+	e.args = args
+
+	if e.file != "" {
+		return nil
+	}
+
+	currentMeteringExport, err := e.rhmConfigFlags.MeteringExport()
+	if err != nil {
+		return err
+	}
+
+	e.file = currentMeteringExport.FileName
+	return nil
+}
+
+func (e *exportInspectOptions) Validate() error {
+	if e.file == "" {
+		return fmt.Errorf("active export has no file, use --file")
+	}
+
+	if _, err := os.Stat(e.file); os.IsNotExist(err) {
+		return fmt.Errorf("file does not exist %s", e.file)
+	}
+
+	return nil
+}
+
+func (e *exportInspectOptions) Run() error {
+	p := output.NewHumanOutput()
+	p.WithDetails("file", e.file).Titlef(i18n.T("bundle entries"))
+	p = p.Sub()
+
+	entries := 0
+
+	err := bundle.WalkTar(e.file, func(header *tar.Header, r io.Reader) error {
+		entries = entries + 1
+
+		m, ok := bundle.MetadataFromHeader(header)
+		if !ok {
+			p.WithDetails("size", header.Size).Warnf("%s has no metadata", header.Name)
+			return nil
+		}
+
+		p.WithDetails(
+			"size", header.Size,
+			"source", m.Source,
+			"sourceType", m.SourceType,
+			"id", m.ID,
+			"checksum", m.Checksum,
+			"pulledAt", m.PulledAt.Format(time.RFC3339),
+			"export", m.ExportName,
+		).Infof("%s", header.Name)
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	p.WithDetails("entries", entries).Infof(i18n.T("inspect finished"))
+	return nil
+}
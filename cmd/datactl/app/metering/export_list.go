@@ -0,0 +1,151 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metering
+
+import (
+	"sort"
+
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers/output"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var (
+	listLong = templates.LongDesc(i18n.T(`
+		Lists the active export and the exports in the history with the number of
+		files pulled, pushed and committed for each.`))
+
+	listExample = templates.Examples(i18n.T(`
+		# List the exports
+		{{ .cmd }} export list
+`))
+)
+
+func NewCmdExportList(rhmFlags *config.ConfigFlags, f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := exportListOptions{
+		rhmConfigFlags: rhmFlags,
+		IOStreams:      ioStreams,
+	}
+
+	cmd := &cobra.Command{
+		Use:                   "list",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Lists the active export and the export history."),
+		Long:                  output.ReplaceCommandStrings(listLong),
+		Example:               output.ReplaceCommandStrings(listExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	return cmd
+}
+
+type exportListOptions struct {
+	rhmConfigFlags *config.ConfigFlags
+
+	//internal
+	args []string
+
+	rhmRawConfig *datactlapi.Config
+
+	currentMeteringExport *datactlapi.MeteringExport
+
+	genericclioptions.IOStreams
+}
+
+func (e *exportListOptions) Complete(cmd *cobra.Command, args []string) error {
+	e.args = args
+
+	var err error
+	e.rhmRawConfig, err = e.rhmConfigFlags.RawPersistentConfigLoader().RawConfig()
+	if err != nil {
+		return err
+	}
+
+	e.currentMeteringExport, err = e.rhmConfigFlags.MeteringExport()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (e *exportListOptions) Validate() error {
+	return nil
+}
+
+func (e *exportListOptions) Run() error {
+	p := output.NewHumanOutput()
+
+	p.Titlef(i18n.T("active export"))
+	printExportSummary(p.Sub(), e.currentMeteringExport)
+
+	p.WithDetails("exports", len(e.rhmRawConfig.MeteringExports)).Titlef(i18n.T("export history"))
+	p2 := p.Sub()
+
+	for _, key := range sortedExportKeys(e.rhmRawConfig) {
+		printExportSummary(p2, e.rhmRawConfig.MeteringExports[key])
+	}
+
+	return nil
+}
+
+type exportSummary struct {
+	files, pushed, committed int
+}
+
+func summarizeExport(export *datactlapi.MeteringExport) exportSummary {
+	summary := exportSummary{files: len(export.Files)}
+
+	for _, file := range export.Files {
+		if file.Pushed {
+			summary.pushed = summary.pushed + 1
+		}
+
+		if file.Committed {
+			summary.committed = summary.committed + 1
+		}
+	}
+
+	return summary
+}
+
+func printExportSummary(p *output.HumanOutput, export *datactlapi.MeteringExport) {
+	summary := summarizeExport(export)
+
+	p.WithDetails(
+		"exportFile", export.FileName,
+		"files", summary.files,
+		"pushed", summary.pushed,
+		"committed", summary.committed,
+	).Infof("%s", export.DisplayName())
+}
+
+func sortedExportKeys(cfg *datactlapi.Config) []string {
+	keys := make([]string, 0, len(cfg.MeteringExports))
+	for key := range cfg.MeteringExports {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
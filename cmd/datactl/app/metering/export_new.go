@@ -0,0 +1,183 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metering
+
+import (
+	"fmt"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/redhat-marketplace/datactl/pkg/bundle"
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers/output"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var (
+	newLong = templates.LongDesc(i18n.T(`
+		Archives the active export into the export history and starts a new export.
+
+		The active export is recorded in the history of the datactl config file under
+		a timestamped name. A new bundle file is created in the data directory and
+		becomes the active export for the following pull, push and commit commands.`))
+
+	newExample = templates.Examples(i18n.T(`
+		# Archive the active export and start a new one
+		{{ .cmd }} export new
+
+		# Start a new export even if files of the active export were not pushed
+		{{ .cmd }} export new --force
+`))
+)
+
+func NewCmdExportNew(rhmFlags *config.ConfigFlags, f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := exportNewOptions{
+		rhmConfigFlags: rhmFlags,
+		IOStreams:      ioStreams,
+	}
+
+	cmd := &cobra.Command{
+		Use:                   "new [(--force)]",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Archives the active export and starts a new one."),
+		Long:                  output.ReplaceCommandStrings(newLong),
+		Example:               output.ReplaceCommandStrings(newExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().BoolVar(&o.force, "force", false, i18n.T("archive the active export even if files are not pushed"))
+
+	return cmd
+}
+
+type exportNewOptions struct {
+	rhmConfigFlags *config.ConfigFlags
+
+	force bool
+
+	//internal
+	args []string
+
+	rhmRawConfig *datactlapi.Config
+
+	currentMeteringExport *datactlapi.MeteringExport
+
+	genericclioptions.IOStreams
+}
+
+func (e *exportNewOptions) Complete(cmd *cobra.Command, args []string) error {
+	e.args = args
+
+	var err error
+	e.rhmRawConfig, err = e.rhmConfigFlags.RawPersistentConfigLoader().RawConfig()
+	if err != nil {
+		return err
+	}
+
+	e.currentMeteringExport, err = e.rhmConfigFlags.MeteringExport()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (e *exportNewOptions) Validate() error {
+	if e.force {
+		return nil
+	}
+
+	pending := 0
+	for _, file := range e.currentMeteringExport.Files {
+		if !file.Pushed {
+			pending = pending + 1
+		}
+	}
+
+	if pending != 0 {
+		return errors.NewWithDetails("active export has files not pushed, use --force to archive it anyway", "files", pending)
+	}
+
+	return nil
+}
+
+func (e *exportNewOptions) Run() error {
+	p := output.NewHumanOutput()
+
+	name, err := archiveExport(e.rhmRawConfig, e.currentMeteringExport)
+	if err != nil {
+		return err
+	}
+
+	if name != "" {
+		p.WithDetails("name", name, "exportFile", e.currentMeteringExport.FileName).Infof(i18n.T("export archived"))
+	}
+
+	bundleFile, err := bundle.NewBundleWithDefaultName()
+	if err != nil {
+		return err
+	}
+
+	if err := bundleFile.Close(); err != nil {
+		return err
+	}
+
+	if bundleFile.Name() == e.currentMeteringExport.FileName {
+		return errors.NewWithDetails("new export file is the same as the archived one, retry in a second", "exportFile", bundleFile.Name())
+	}
+
+	e.rhmRawConfig.CurrentMeteringExport = &datactlapi.MeteringExport{
+		FileName: bundleFile.Name(),
+	}
+
+	if err := config.ModifyConfig(e.rhmConfigFlags.ConfigAccess(), *e.rhmRawConfig, true); err != nil {
+		return err
+	}
+
+	p.WithDetails("exportFile", bundleFile.Name()).Infof(i18n.T("export started"))
+	return nil
+}
+
+// archiveExport moves the export into the history of the config and returns
+// the name it is recorded under. An export without a file is not archived.
+func archiveExport(cfg *datactlapi.Config, export *datactlapi.MeteringExport) (string, error) {
+	if export == nil || (export.FileName == "" && len(export.Files) == 0) {
+		return "", nil
+	}
+
+	if export.Name == "" {
+		export.Name = fmt.Sprintf("export-%s", time.Now().UTC().Format("20060102T150405Z"))
+	}
+
+	if existing, ok := cfg.MeteringExports[export.Name]; ok && existing != export {
+		return "", errors.NewWithDetails("export already exists in history", "name", export.Name)
+	}
+
+	if cfg.MeteringExports == nil {
+		cfg.MeteringExports = map[string]*datactlapi.MeteringExport{}
+	}
+
+	cfg.MeteringExports[export.Name] = export
+	return export.Name, nil
+}
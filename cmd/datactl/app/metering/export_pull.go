@@ -2,15 +2,13 @@ package metering
 
 import (
 	"context"
-	"fmt"
-	"io"
-	"os"
-	"regexp"
+	"sort"
 	"strings"
 	"time"
 
-	"github.com/manifoldco/promptui"
+	"emperror.dev/errors"
 	"github.com/redhat-marketplace/datactl/pkg/bundle"
+	"github.com/redhat-marketplace/datactl/pkg/clients/ilmt"
 	"github.com/redhat-marketplace/datactl/pkg/datactl/api"
 	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
 	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
@@ -18,6 +16,7 @@ import (
 	"github.com/redhat-marketplace/datactl/pkg/printers/output"
 	"github.com/redhat-marketplace/datactl/pkg/sources"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	clientapi "k8s.io/client-go/tools/clientcmd/api"
 	"k8s.io/kubectl/pkg/cmd/get"
@@ -32,6 +31,10 @@ var (
 
 		Prints a table of the files pulled with basic information.
 
+		The export is saved after each file is pulled. If a pull is interrupted,
+		running it again continues from the last page listed from each dataservice
+		source and skips the files already downloaded and verified.
+
 		Please use the sources commands to add new sources for pulling.`))
 
 	pullExample = templates.Examples(i18n.T(`
@@ -49,13 +52,14 @@ var (
 
 		# Pull all data from a particular source and source type. startdate and enddate flags are optional, if startdate, enddate not given for ILMT source will asks for prompt.
 		{{ .cmd }} export pull all -source-type dataService/ilmt --source-name my-dataservice-cluster/my-ilmt-server-hostname --start-date 2022-02-04 --end-date 2022-06-02
+
+		# Pull ILMT data into one report file per day, so each day can be pushed on its own
+		{{ .cmd }} export pull all --source-type ilmt --start-date 2022-02-04 --end-date 2022-02-10 --daily-reports
 `))
 )
 
 const (
-	EMPTY     string = ""
-	StartDate        = "startDate"
-	EndDate          = "endDate"
+	EMPTY string = ""
 )
 
 func NewCmdExportPull(rhmFlags *config.ConfigFlags, f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
@@ -82,8 +86,7 @@ func NewCmdExportPull(rhmFlags *config.ConfigFlags, f cmdutil.Factory, ioStreams
 
 	cmd.Flags().StringVar(&o.sourceType, "source-type", EMPTY, i18n.T("Source Name"))
 	cmd.Flags().StringVar(&o.sourceName, "source-name", EMPTY, i18n.T("Source Type"))
-	cmd.Flags().StringVar(&o.startDate, "start-date", EMPTY, i18n.T("Start Date"))
-	cmd.Flags().StringVar(&o.endDate, "end-date", EMPTY, i18n.T("End Date"))
+	addSourcePullFlags(cmd.Flags())
 
 	cmd.Flags().MarkHidden("label-columns")
 	cmd.Flags().MarkHidden("sort-by")
@@ -101,13 +104,19 @@ type exportPullOptions struct {
 	// flags
 	sourceName, sourceType string
 
-	//start & end date
-	startDate, endDate string
-
 	//internal
 	args      []string
+	flags     *pflag.FlagSet
+	options   map[string]sources.GenericOptions
 	rawConfig clientapi.Config
 
+	// errs are the errors of the sources that failed to pull, by source name
+	errs map[string]error
+
+	// skipUpToDate skips the sources with nothing new to pull instead of
+	// failing them
+	skipUpToDate bool
+
 	printer printers.Printer
 
 	genericclioptions.IOStreams
@@ -118,6 +127,12 @@ type exportPullOptions struct {
 
 func (e *exportPullOptions) Complete(cmd *cobra.Command, args []string) error {
 	e.args = args
+	e.options = map[string]sources.GenericOptions{}
+
+	if cmd != nil {
+		e.flags = cmd.Flags()
+	}
+
 	var err error
 	e.rhmRawConfig, err = e.rhmConfigFlags.RawPersistentConfigLoader().RawConfig()
 	if err != nil {
@@ -141,113 +156,56 @@ func (e *exportPullOptions) Complete(cmd *cobra.Command, args []string) error {
 }
 
 func (e *exportPullOptions) Validate() error {
-	for name := range e.rhmRawConfig.Sources {
+	for _, name := range e.selectedSources() {
 		s := e.rhmRawConfig.Sources[name]
 
-		switch s.Type {
-		case api.ILMT:
-			if e.startDate == EMPTY {
-				if e.rhmRawConfig.ILMTEndpoints[s.Name].LastPulldate == EMPTY {
-					startDate, err := e.promptStartDate()
-					if err != nil {
-						e.printer.HumanOutput(func(ho *output.HumanOutput) *output.HumanOutput {
-							p := ho
-							p.Errorf(err, i18n.T(err.Error()))
-							return p
-						})
-						os.Exit(1)
-					}
-					if startDate == EMPTY {
-						e.printer.HumanOutput(func(ho *output.HumanOutput) *output.HumanOutput {
-							p := ho
-							p.Infof(i18n.T("Startdate mandatory to provide in case of pulling data from source first time"))
-							return p
-						})
-						os.Exit(1)
-					}
-					e.startDate = startDate
-				} else {
-					e.startDate = e.rhmRawConfig.ILMTEndpoints[s.Name].LastPulldate
-				}
-			}
+		opts, err := e.pullOptions(s)
+		if err != nil {
+			return errors.WithDetails(err, "sourceName", s.Name, "sourceType", s.Type)
+		}
 
-			re := regexp.MustCompile(`((19|20)\d\d)-(0?[1-9]|1[012])-(0?[1-9]|[12][0-9]|3[01])`)
-			startDateMatched := re.MatchString(e.startDate)
-			if !startDateMatched {
-				e.printer.HumanOutput(func(ho *output.HumanOutput) *output.HumanOutput {
-					p := ho
-					p.Infof(i18n.T("Startdate must be in format yyyy-mm-dd"))
-					return p
-				})
-				os.Exit(1)
-			}
+		e.options[name] = opts
+	}
 
-			startDate, err := time.Parse("2006-01-02", e.startDate)
-			if err != nil {
-				e.printer.HumanOutput(func(ho *output.HumanOutput) *output.HumanOutput {
-					p := ho
-					p.Errorf(err, i18n.T(err.Error()))
-					return p
-				})
-				os.Exit(1)
-			}
-			isStartDateCheckFailed := startDate.After(time.Now().AddDate(0, 0, -1))
-			if isStartDateCheckFailed {
-				e.printer.HumanOutput(func(ho *output.HumanOutput) *output.HumanOutput {
-					p := ho
-					p.Infof(i18n.T("Start date must not be greater than yesterday date"))
-					return p
-				})
-				os.Exit(1)
-			}
+	return nil
+}
 
-			if e.endDate == EMPTY {
-				yesterdayDate := fmt.Sprintf("%04d-%02d-%02d", time.Now().AddDate(0, 0, -1).Year(), time.Now().AddDate(0, 0, -1).Month(), time.Now().AddDate(0, 0, -1).Day())
-				e.endDate = yesterdayDate
-			}
+// selectedSources returns the names of the sources filtered by the source type
+// and name flags.
+func (e *exportPullOptions) selectedSources() []string {
+	names := []string{}
 
-			endDateMatched := re.MatchString(e.endDate)
-			if !endDateMatched {
-				e.printer.HumanOutput(func(ho *output.HumanOutput) *output.HumanOutput {
-					p := ho
-					p.Infof(i18n.T("Enddate must be in format yyyy-mm-dd"))
-					return p
-				})
-				os.Exit(1)
-			}
+	for name, s := range e.rhmRawConfig.Sources {
+		if (e.sourceType == EMPTY && e.sourceName == EMPTY) ||
+			strings.EqualFold(e.sourceType, s.Type.String()) ||
+			strings.EqualFold(e.sourceName, s.Name) {
+			names = append(names, name)
+		}
+	}
 
-			endDate, err := time.Parse("2006-01-02", e.endDate)
-			if err != nil {
-				e.printer.HumanOutput(func(ho *output.HumanOutput) *output.HumanOutput {
-					p := ho
-					p.Errorf(err, i18n.T(err.Error()))
-					return p
-				})
-				os.Exit(1)
-			}
-			isEndDateCheckFailed := endDate.After(time.Now().AddDate(0, 0, -1)) || endDate.Before(startDate)
-			if isEndDateCheckFailed {
-				e.printer.HumanOutput(func(ho *output.HumanOutput) *output.HumanOutput {
-					p := ho
-					p.Infof(i18n.T("End date must not be less than start date or greater than yesterday date"))
-					return p
-				})
-				os.Exit(1)
-			}
+	sort.Strings(names)
+	return names
+}
 
-		case api.DataService:
+// pullOptions returns the options of the pull of a source from its source
+// type.
+func (e *exportPullOptions) pullOptions(s *datactlapi.Source) (sources.GenericOptions, error) {
+	if opts, ok := e.options[s.Name]; ok {
+		return opts, nil
+	}
 
-		default:
-			e.printer.HumanOutput(func(ho *output.HumanOutput) *output.HumanOutput {
-				p := ho
-				p.Infof(i18n.T("Unsupported source type"))
-				return p
-			})
-			os.Exit(1)
-		}
+	r, err := sources.Lookup(s.Type)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return r.PullOptionsFor(&sources.PullRequest{
+		Config:     e.rhmRawConfig,
+		Source:     s,
+		Flags:      e.flags,
+		Checkpoint: sources.CheckpointFunc(e.checkpoint),
+		IOStreams:  e.IOStreams,
+	})
 }
 
 func (e *exportPullOptions) Run() error {
@@ -264,20 +222,32 @@ func (e *exportPullOptions) Run() error {
 		return err
 	}
 
-	for name := range e.rhmRawConfig.Sources {
+	e.printer.HumanOutput(func(p *output.HumanOutput) *output.HumanOutput {
+		reportRecovery(p, currentMeteringExport, bundleFile.Recovery())
+		return p
+	})
+
+	e.printer.HumanOutput(func(p *output.HumanOutput) *output.HumanOutput {
+		p.WithDetails("exportFile", currentMeteringExport.FileName).Titlef(i18n.T("pulling sources to file"))
+		return p.Sub()
+	})
+
+	e.errs = map[string]error{}
+
+	for _, name := range e.selectedSources() {
 		s := e.rhmRawConfig.Sources[name]
 
-		if ((e.sourceType == EMPTY && e.sourceName == EMPTY) || (strings.EqualFold(e.sourceType, s.Type.String()) || strings.EqualFold(e.sourceName, s.Name))) && (strings.EqualFold(s.Type.String(), string(api.DataService))) {
-			err := e.DataServicePullBase(s, ctx, currentMeteringExport, bundleFile)
-			if err != nil {
-				continue
-			}
-		} else if ((e.sourceType == EMPTY && e.sourceName == EMPTY) || (strings.EqualFold(e.sourceType, s.Type.String()) || strings.EqualFold(e.sourceName, s.Name))) && (strings.EqualFold(s.Type.String(), string(api.ILMT))) {
-			_, _, err := e.IlmtPullBase(s, ctx, currentMeteringExport, bundleFile)
-			if err != nil {
-				continue
-			}
-			e.rhmRawConfig.ILMTEndpoints[s.Name].LastPulldate = strings.Split(time.Now().String(), " ")[0]
+		_, _, err := e.pullSource(s, ctx, currentMeteringExport, bundleFile)
+		if e.skipUpToDate && errors.Is(err, sources.UpToDateError) {
+			continue
+		}
+
+		if err != nil {
+			e.errs[s.Name] = err
+		}
+
+		if r, lookupErr := sources.Lookup(s.Type); lookupErr == nil && r.Pulled != nil {
+			r.Pulled(e.rhmRawConfig, s, err)
 		}
 	}
 
@@ -303,15 +273,23 @@ func (e *exportPullOptions) Run() error {
 	return nil
 }
 
-func (e *exportPullOptions) DataServicePullBase(s *datactlapi.Source, ctx context.Context,
-	currentMeteringExport *api.MeteringExport,
-	bundleFile *bundle.BundleFile) error {
+// failedSources returns the names of the sources that failed to pull in the
+// last run, separated by commas.
+func (e *exportPullOptions) failedSources() string {
+	names := make([]string, 0, len(e.errs))
+	for name := range e.errs {
+		names = append(names, name)
+	}
 
-	e.printer.HumanOutput(func(p *output.HumanOutput) *output.HumanOutput {
-		p.WithDetails("exportFile", currentMeteringExport.FileName).Titlef(i18n.T("pulling sources to file"))
-		return p.Sub()
-	})
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
 
+// pullSource pulls a source into the bundle and returns the count of files
+// pulled and the response of the source.
+func (e *exportPullOptions) pullSource(s *datactlapi.Source, ctx context.Context,
+	currentMeteringExport *api.MeteringExport,
+	bundleFile *bundle.BundleFile) (int, string, error) {
 	source, err := e.Factory.FromSource(*s)
 	if err != nil {
 		e.printer.HumanOutput(func(ho *output.HumanOutput) *output.HumanOutput {
@@ -319,44 +297,21 @@ func (e *exportPullOptions) DataServicePullBase(s *datactlapi.Source, ctx contex
 			p.Errorf(err, i18n.T("failed to get source"))
 			return p
 		})
-
-		return err
+		return -1, EMPTY, err
 	}
 
-	e.printer.HumanOutput(func(p *output.HumanOutput) *output.HumanOutput {
-
-		p = p.WithDetails("sourceName", s.Name, "sourceType", s.Type)
-		p.Infof(i18n.T("pull start"))
-		return p
-	})
-
-	count, err := source.Pull(ctx, currentMeteringExport, bundleFile, sources.EmptyOptions())
-
-	if err != nil {
+	opts, err := e.pullOptions(s)
+	if e.skipUpToDate && errors.Is(err, sources.UpToDateError) {
 		e.printer.HumanOutput(func(p *output.HumanOutput) *output.HumanOutput {
-			p.Errorf(err, i18n.T("pull failed"))
+			p.WithDetails("sourceName", s.Name, "sourceType", s.Type).Infof(i18n.T("source is up to date, pull skipped"))
 			return p
 		})
-
-		return err
+		return 0, EMPTY, err
 	}
 
-	e.printer.HumanOutput(func(p *output.HumanOutput) *output.HumanOutput {
-		p.WithDetails("count", count).Infof(i18n.T("pull complete"))
-		return p
-	})
-
-	return nil
-}
-
-func (e *exportPullOptions) IlmtPullBase(s *datactlapi.Source, ctx context.Context,
-	currentMeteringExport *api.MeteringExport,
-	bundleFile *bundle.BundleFile) (int, string, error) {
-	source, err := e.Factory.FromSource(*s)
 	if err != nil {
-		e.printer.HumanOutput(func(ho *output.HumanOutput) *output.HumanOutput {
-			p := ho
-			p.Errorf(err, i18n.T("failed to get source"))
+		e.printer.HumanOutput(func(p *output.HumanOutput) *output.HumanOutput {
+			p.Errorf(err, i18n.T("pull failed"))
 			return p
 		})
 		return -1, EMPTY, err
@@ -368,12 +323,34 @@ func (e *exportPullOptions) IlmtPullBase(s *datactlapi.Source, ctx context.Conte
 		return p
 	})
 
-	productCount, err := source.Pull(ctx, currentMeteringExport, bundleFile, sources.NewOptions(
-		StartDate, e.startDate,
-		EndDate, e.endDate,
-	))
+	if c, ok := currentMeteringExport.PullCheckpoints[s.Name]; ok && c != nil && c.PageToken != "" {
+		e.printer.HumanOutput(func(p *output.HumanOutput) *output.HumanOutput {
+			p.WithDetails("sourceName", s.Name).Infof(i18n.T("resuming interrupted pull"))
+			return p
+		})
+	}
+
+	count, err := source.Pull(ctx, currentMeteringExport, bundleFile, opts)
+
+	response := source.GetResponse()
+
+	var partial *ilmt.PartialError
+	if errors.As(err, &partial) {
+		e.printer.HumanOutput(func(p *output.HumanOutput) *output.HumanOutput {
+			for _, day := range partial.Failed {
+				p.WithDetails("date", day.Date).Errorf(day.Err, i18n.T("failed to pull day"))
+			}
+
+			if partial.Stopped {
+				p.WithDetails("date", partial.Failed[len(partial.Failed)-1].Date).Warnf(i18n.T("days after date not pulled"))
+			}
 
-	productUsageResponseStr := source.GetResponse()
+			p.WithDetails("count", count, "nextStartDate", partial.FirstFailedDate()).Warnf(i18n.T("pull partially complete"))
+			return p
+		})
+
+		return count, response, err
+	}
 
 	if err != nil {
 		e.printer.HumanOutput(func(p *output.HumanOutput) *output.HumanOutput {
@@ -385,32 +362,40 @@ func (e *exportPullOptions) IlmtPullBase(s *datactlapi.Source, ctx context.Conte
 	}
 
 	e.printer.HumanOutput(func(p *output.HumanOutput) *output.HumanOutput {
-		p.WithDetails("count", productCount).Infof(i18n.T("pull complete"))
+		p.WithDetails("count", count).Infof(i18n.T("pull complete"))
 		return p
 	})
 
-	return productCount, productUsageResponseStr, nil
+	return count, response, nil
 }
 
-func (e *exportPullOptions) promptStartDate() (string, error) {
-	promptStartDate := promptui.Prompt{
-		Label:  fmt.Sprintf(i18n.T("Enter start date in %s format"), "yyyy-mm-dd"),
-		Stdin:  io.NopCloser(e.In),
-		Stdout: NopWCloser(e.Out),
-	}
-	startDate, err := promptStartDate.Run()
-	if err != nil {
-		return EMPTY, err
-	}
-	return startDate, nil
+// checkpoint saves the config so the files pulled so far are kept if the pull
+// is interrupted.
+func (e *exportPullOptions) checkpoint() error {
+	return config.ModifyConfig(e.rhmConfigFlags.ConfigAccess(), *e.rhmRawConfig, true)
 }
 
-func NopWCloser(w io.Writer) io.WriteCloser {
-	return nopWCloser{w}
-}
+// addSourcePullFlags adds the pull flags of the registered source types,
+// except the skipped ones. A flag shared by source types is added once.
+func addSourcePullFlags(flags *pflag.FlagSet, skip ...string) {
+	for _, r := range sources.Registrations() {
+		if r.PullFlags == nil {
+			continue
+		}
 
-type nopWCloser struct {
-	io.Writer
-}
+		typeFlags := pflag.NewFlagSet(r.Type.String(), pflag.ContinueOnError)
+		r.PullFlags(typeFlags)
 
-func (nopWCloser) Close() error { return nil }
+		typeFlags.VisitAll(func(f *pflag.Flag) {
+			for _, name := range skip {
+				if f.Name == name {
+					return
+				}
+			}
+
+			if flags.Lookup(f.Name) == nil {
+				flags.AddFlag(f)
+			}
+		})
+	}
+}
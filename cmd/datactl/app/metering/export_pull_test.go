@@ -23,6 +23,8 @@ import (
 	. "github.com/onsi/gomega"
 
 	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/sources"
+	"github.com/spf13/pflag"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/kubectl/pkg/cmd/get"
 )
@@ -55,16 +57,15 @@ var _ = Describe("export_pull_ilmt", func() {
 				PrintFlags:     get.NewGetPrintFlags(),
 				sourceName:     "demo.ilmt.ibmcloudsecurity.com",
 				sourceType:     "ILMT",
-				startDate:      "2022-06-17",
-				endDate:        "2022-06-17",
 			}
 
 			o.rhmRawConfig, _ = o.rhmConfigFlags.RawPersistentConfigLoader().RawConfig()
 			o.Complete(nil, nil)
+			o.flags = ilmtPullFlags()
 			for name := range o.rhmRawConfig.Sources {
 				s := o.rhmRawConfig.Sources[name]
 				if s.Type.String() == o.sourceType {
-					_, _, err := o.IlmtPullBase(s, ctx, nil, nil)
+					_, _, err := o.pullSource(s, ctx, nil, nil)
 					Expect(err).To(Succeed())
 				}
 			}
@@ -81,16 +82,15 @@ var _ = Describe("export_pull_ilmt", func() {
 				PrintFlags:     get.NewGetPrintFlags(),
 				sourceName:     "demo.ilmt.ibmcloudsecurity.com",
 				sourceType:     "ILMT",
-				startDate:      "2022-06-17",
-				endDate:        "2022-06-17",
 			}
 
 			o.rhmRawConfig, _ = o.rhmConfigFlags.RawPersistentConfigLoader().RawConfig()
 			o.Complete(nil, nil)
+			o.flags = ilmtPullFlags()
 			for name := range o.rhmRawConfig.Sources {
 				s := o.rhmRawConfig.Sources[name]
 				if s.Type.String() == o.sourceType {
-					count, _, _ := o.IlmtPullBase(s, ctx, nil, nil)
+					count, _, _ := o.pullSource(s, ctx, nil, nil)
 					Expect(1).To(Equal(count))
 				}
 			}
@@ -107,16 +107,15 @@ var _ = Describe("export_pull_ilmt", func() {
 				PrintFlags:     get.NewGetPrintFlags(),
 				sourceName:     "demo.ilmt.ibmcloudsecurity.com",
 				sourceType:     "ILMT",
-				startDate:      "2022-06-17",
-				endDate:        "2022-06-17",
 			}
 
 			o.rhmRawConfig, _ = o.rhmConfigFlags.RawPersistentConfigLoader().RawConfig()
 			o.Complete(nil, nil)
+			o.flags = ilmtPullFlags()
 			for name := range o.rhmRawConfig.Sources {
 				s := o.rhmRawConfig.Sources[name]
 				if s.Type.String() == o.sourceType {
-					_, response, _ := o.IlmtPullBase(s, ctx, nil, nil)
+					_, response, _ := o.pullSource(s, ctx, nil, nil)
 					expectedRespPathComplete := os.Getenv("HOME")
 					expectedRespPath := "/.datactl/productusageresponse.json"
 					expectedRespPathComplete += expectedRespPath
@@ -128,3 +127,12 @@ var _ = Describe("export_pull_ilmt", func() {
 		})
 	})
 })
+
+// ilmtPullFlags returns the pull flags with the date range of the ILMT specs.
+func ilmtPullFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("pull", pflag.ContinueOnError)
+	addSourcePullFlags(flags)
+	flags.Set(sources.StartDateFlag, "2022-06-17")
+	flags.Set(sources.EndDateFlag, "2022-06-17")
+	return flags
+}
@@ -17,20 +17,27 @@ package metering
 import (
 	"archive/tar"
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"emperror.dev/errors"
 	"github.com/gotidy/ptr"
+	"github.com/liggitt/tabwriter"
 	"github.com/redhat-marketplace/datactl/pkg/bundle"
 	"github.com/redhat-marketplace/datactl/pkg/clients/marketplace"
 	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
 	dataservicev1 "github.com/redhat-marketplace/datactl/pkg/datactl/api/dataservice/v1"
 	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
 	"github.com/redhat-marketplace/datactl/pkg/printers/output"
+	"github.com/redhat-marketplace/datactl/pkg/sources"
 	"github.com/spf13/cobra"
+	"golang.org/x/time/rate"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/cli-runtime/pkg/printers"
 	clientapi "k8s.io/client-go/tools/clientcmd/api"
@@ -45,7 +52,11 @@ var (
 		Pushes files to the metrics processing backends.
 
 		Pushing uses the current kubernetes context and records the results into
-		the datactl config file.`))
+		the datactl config file.
+
+		With --wait, each new upload is polled until the marketplace has processed
+		it. A file the marketplace rejects is marked as not pushed, so it is not
+		committed, and its error is recorded.`))
 
 	pushExamples = templates.Examples(i18n.T(`
 		# Push the files in the active export
@@ -56,9 +67,21 @@ var (
 
 		# Push a specific {{ .cmd }} file
 		{{ .cmd }} export push --file={{ .defaultDataPath }}/rhm-upload-20211111T000959Z.tar
+
+		# Stage the bundle of the active export in the bucket of an S3 source instead of pushing it
+		{{ .cmd }} export push --destination minio-usage
+
+		# Upload 8 files at a time, starting at most 2 uploads per second
+		{{ .cmd }} export push --workers 8 --rate 2
+
+		# Wait for the marketplace to process each upload
+		{{ .cmd }} export push --wait
 `))
 )
 
+// defaultPushWorkers is the number of files uploaded in parallel by default.
+const defaultPushWorkers = 4
+
 func NewCmdExportPush(rhmFlags *config.ConfigFlags, f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
 	o := exportPushOptions{
 		rhmConfigFlags: rhmFlags,
@@ -88,6 +111,10 @@ func NewCmdExportPush(rhmFlags *config.ConfigFlags, f cmdutil.Factory, ioStreams
 
 	cmd.Flags().StringVar(&o.OverrideFile, "file", "", i18n.T("tar file to upload from"))
 	cmd.Flags().BoolVar(&o.dryRun, "dry-run", false, i18n.T("No action taken. Print only."))
+	cmd.Flags().StringVar(&o.destination, "destination", "", i18n.T("name of an S3 source to stage the bundle in instead of pushing it to the marketplace"))
+	cmd.Flags().IntVar(&o.workers, "workers", defaultPushWorkers, i18n.T("number of files uploaded in parallel"))
+	cmd.Flags().Float64Var(&o.rate, "rate", 0, i18n.T("maximum uploads started per second, unlimited if 0"))
+	cmd.Flags().BoolVar(&o.wait, "wait", false, i18n.T("wait for the marketplace to process each upload"))
 
 	return cmd
 }
@@ -99,6 +126,10 @@ type exportPushOptions struct {
 	// Flags
 	dryRun       bool
 	OverrideFile string
+	destination  string
+	workers      int
+	rate         float64
+	wait         bool
 
 	//internal
 	humanOutput bool
@@ -111,6 +142,9 @@ type exportPushOptions struct {
 	currentMeteringExport *datactlapi.MeteringExport
 	bundle                *bundle.BundleFile
 
+	// errs are the errors of the files that failed to push, by file name
+	errs map[string]error
+
 	ToPrinter func(string) (printers.ResourcePrinter, error)
 
 	genericclioptions.IOStreams
@@ -125,9 +159,12 @@ func (e *exportPushOptions) Complete(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	e.marketplace, err = e.rhmConfigFlags.MarketplaceClient()
-	if err != nil {
-		return err
+	// staging doesn't need the marketplace, which may not be reachable
+	if e.destination == "" {
+		e.marketplace, err = e.rhmConfigFlags.MarketplaceClient()
+		if err != nil {
+			return err
+		}
 	}
 
 	e.currentMeteringExport, err = e.rhmConfigFlags.MeteringExport()
@@ -162,6 +199,20 @@ func (e *exportPushOptions) Validate() error {
 		}
 	}
 
+	if e.workers < 1 {
+		return errors.NewWithDetails("workers must be at least 1", "workers", e.workers)
+	}
+
+	if e.rate < 0 {
+		return errors.NewWithDetails("rate must not be negative", "rate", e.rate)
+	}
+
+	if e.destination != "" {
+		if _, _, ok := e.rhmRawConfig.SourceByName(e.destination); !ok {
+			return fmt.Errorf("destination is not a source %s", e.destination)
+		}
+	}
+
 	return nil
 }
 
@@ -187,6 +238,10 @@ func (e *exportPushOptions) Run() error {
 		print = output.NewPushFileOnlyCLITableOrStruct(e.PrintFlags, print)
 	}
 
+	if e.destination != "" {
+		return e.stage(ctx, p, file)
+	}
+
 	if e.humanOutput {
 		p.WithDetails("uploadHost", e.rhmRawConfig.MarketplaceEndpoint.Host).
 			Titlef(i18n.T("push started"))
@@ -196,6 +251,8 @@ func (e *exportPushOptions) Run() error {
 			p.Warnf(i18n.T("dry-run enabled; files will not be pushed"))
 		}
 
+		reportRecovery(p, e.currentMeteringExport, e.bundle.Recovery())
+
 		p.WithDetails("exportFile", file).Infof(i18n.T("pushing files status:"))
 	}
 
@@ -208,91 +265,163 @@ func (e *exportPushOptions) Run() error {
 		}
 	}
 
-	errs := map[string]error{}
+	results := &pushResults{print: print, writer: writer}
+
+	// the export is saved as each file finishes, so an interrupted push keeps
+	// the files already pushed
+	if !e.dryRun && e.OverrideFile == "" {
+		results.save = func() error {
+			return config.ModifyConfig(e.rhmConfigFlags.RawPersistentConfigLoader().ConfigAccess(), *e.rhmRawConfig, true)
+		}
+	}
+
+	var limiter *rate.Limiter
+	if e.rate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(e.rate), 1)
+	}
+
+	e.errs = map[string]error{}
 	found := 0
 	pushed := 0
+	processing := 0
 
-	err = bundle.WalkTar(e.currentMeteringExport.FileName, func(header *tar.Header, r io.Reader) error {
+	sem := make(chan struct{}, e.workers)
+	wg := sync.WaitGroup{}
+
+	// the tar is read in order and each entry is spooled before it's handed to
+	// a worker, so at most one spool per worker is on disk
+	err = bundle.WalkTar(file, func(header *tar.Header, r io.Reader) error {
 		// skip our helper commit file
 		if header.Name == "commit.json" {
 			return nil
 		}
 
-		log := logger.WithValues("file", header.Name).V(5)
+		name := header.Name
+		log := logger.WithValues("file", name).V(5)
 
-		file := files[header.Name]
+		file := files[name]
 
 		if file == nil && e.OverrideFile == "" {
-			log.Info("tar file has no info in the config file skipping", "file", header.Name)
+			log.Info("tar file has no info in the config file skipping", "file", name)
 			return nil
 		}
 
 		if e.OverrideFile != "" {
-			// handle the case where we are just uploading from a file and have no config
-			file = &dataservicev1.FileInfoCTLAction{
-				FileInfo: &dataservicev1.FileInfo{},
-			}
-			file.Name = header.Name
-			file.Size = uint32(header.Size)
-			file.Action = "Pushed"
+			// handle the case where we are just uploading from a file and have no config,
+			// the file info is read from the bundle metadata
+			file = bundle.FileInfoFromHeader(header)
 		}
 
-		found = found + 1
+		checksum := file.VerifiedChecksum
+		if m, ok := bundle.MetadataFromHeader(header); ok && checksum == "" {
+			checksum = m.Checksum
+		}
 
-		file.Action = dataservicev1.Push
-		file.Result = dataservicev1.Ok
+		found = found + 1
+		i := results.add(file)
 
 		if e.dryRun || file.Pushed {
-			if e.dryRun {
-				file.Result = dataservicev1.DryRun
-			}
-
 			if file.Pushed {
 				log.Info("file is already pushed")
 			}
 
-			print.PrintObj(file, writer)
-			writer.Flush()
+			results.finish(i, func() {
+				file.Action = dataservicev1.Push
+				file.Result = dataservicev1.Ok
+
+				if e.dryRun {
+					file.Result = dataservicev1.DryRun
+				}
+			})
 			return nil
 		}
 
-		id, err := e.marketplace.Metrics().Upload(ctx, header.Name, r)
+		sem <- struct{}{}
+
+		spool, err := spoolVerified(r, checksum)
 		if err != nil {
-			details := errors.GetDetails(err)
-			err = errors.Errorf("%s %+v", err.Error(), details)
-			log.Info("failed to push file", "err", err)
-			errs[file.Name] = err
-			file.Error = err.Error()
-			file.Action = dataservicev1.Pull
-			file.Result = dataservicev1.Error
-			file.Pushed = false
-			print.PrintObj(file, writer)
-			writer.Flush()
+			<-sem
+
+			err = errors.Errorf("%s %+v", err.Error(), errors.GetDetails(err))
+			log.Info("failed to verify file", "err", err)
+			results.finish(i, func() {
+				e.errs[file.Name] = err
+				file.Action = dataservicev1.Push
+				file.Error = err.Error()
+				file.Result = dataservicev1.Error
+				file.Pushed = false
+			})
 			return nil
 		}
 
-		file.UploadError = ""
-		file.Error = ""
-		file.Pushed = true
-		file.UploadID = id
-		print.PrintObj(file, writer)
-		writer.Flush()
-		pushed = pushed + 1
-		log.Info("push file success")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			defer spool.Close()
+
+			id, err := e.upload(ctx, limiter, name, spool)
+			if err != nil {
+				details := errors.GetDetails(err)
+				err = errors.Errorf("%s %+v", err.Error(), details)
+				log.Info("failed to push file", "err", err)
+				results.finish(i, func() {
+					e.errs[file.Name] = err
+					file.Error = err.Error()
+					file.Action = dataservicev1.Pull
+					file.Result = dataservicev1.Error
+					file.Pushed = false
+				})
+				return
+			}
+
+			log.Info("push file success")
+
+			var status *marketplace.MarketplaceUsageResponse
+			if e.wait && id != "" {
+				status, err = e.marketplace.Metrics().Wait(ctx, id)
+				if err != nil {
+					log.Info("failed to wait for upload", "err", err)
+				}
+			}
+
+			results.finish(i, func() {
+				if err := recordUpload(file, id, status); err != nil {
+					e.errs[file.Name] = err
+					return
+				}
+
+				pushed = pushed + 1
+				if status != nil && !status.Status.IsFinal() {
+					processing = processing + 1
+				}
+			})
+		}()
+
 		return nil
 	})
 
+	wg.Wait()
+
 	if err != nil {
 		return err
 	}
 
+	if err := results.saveErr; err != nil {
+		return err
+	}
+
 	if e.humanOutput {
 		p.WithDetails("pushed", pushed, "files", found).Infof(i18n.T("push finished"))
 
-		if len(errs) != 0 {
+		if processing != 0 {
+			p.WithDetails("files", processing).Warnf(i18n.T("uploads still processing; check them with export status"))
+		}
+
+		if len(e.errs) != 0 {
 			p.Errorf(nil, "errors have occurred")
 			p2 := p.Sub()
-			for name, err := range errs {
+			for name, err := range e.errs {
 				p2.WithDetails("name", name).Errorf(nil, err.Error())
 			}
 		}
@@ -318,3 +447,174 @@ func (e *exportPushOptions) Run() error {
 	}
 	return nil
 }
+
+// recordUpload records a new upload of a file and the status the marketplace
+// processed it with, if it was waited for. A file the marketplace rejected is
+// not pushed, so it isn't committed, and its error is returned.
+func recordUpload(file *dataservicev1.FileInfoCTLAction, id string, status *marketplace.MarketplaceUsageResponse) error {
+	file.Action = dataservicev1.Push
+	file.Result = dataservicev1.Ok
+	file.UploadError = ""
+	file.UploadStatus = ""
+	file.Error = ""
+	file.Pushed = true
+	file.UploadID = id
+
+	if status == nil {
+		return nil
+	}
+
+	return recordUploadStatus(file, status)
+}
+
+// upload uploads a file once the limiter allows it.
+func (e *exportPushOptions) upload(ctx context.Context, limiter *rate.Limiter, name string, r io.Reader) (string, error) {
+	if limiter != nil {
+		if err := limiter.Wait(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	return e.marketplace.Metrics().Upload(ctx, name, r)
+}
+
+// pushResults prints the results of the files pushed by the workers in the
+// order of the bundle, whichever finishes first, and saves the export as each
+// file finishes. The files are only changed while holding its lock, so they
+// are never changed while the config is saved.
+type pushResults struct {
+	mu sync.Mutex
+
+	files   []*dataservicev1.FileInfoCTLAction
+	done    []bool
+	printed int
+
+	print  printers.ResourcePrinter
+	writer *tabwriter.Writer
+
+	save    func() error
+	saveErr error
+}
+
+// add adds a file in bundle order and returns its index.
+func (r *pushResults) add(file *dataservicev1.FileInfoCTLAction) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.files = append(r.files, file)
+	r.done = append(r.done, false)
+	return len(r.files) - 1
+}
+
+// finish records the result of the file at index i with update, then prints
+// the results that are next in bundle order and saves the export.
+func (r *pushResults) finish(i int, update func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	update()
+	r.done[i] = true
+
+	for r.printed < len(r.files) && r.done[r.printed] {
+		r.print.PrintObj(r.files[r.printed], r.writer)
+		r.printed = r.printed + 1
+	}
+	r.writer.Flush()
+
+	if r.save != nil && r.saveErr == nil {
+		r.saveErr = r.save()
+	}
+}
+
+// stage copies the bundle to the destination source instead of pushing its
+// files. The files are not marked pushed, so they are still pushed to the
+// marketplace later.
+func (e *exportPushOptions) stage(ctx context.Context, p *output.HumanOutput, file string) error {
+	_, source, _ := e.rhmRawConfig.SourceByName(e.destination)
+
+	r, err := sources.Lookup(source.Type)
+	if err != nil {
+		return err
+	}
+
+	s, err := r.New(e.rhmConfigFlags, nil, *source)
+	if err != nil {
+		return err
+	}
+
+	stager, ok := s.(sources.Stager)
+	if !ok {
+		return errors.NewWithDetails("source can't be a destination", "source", source.String())
+	}
+
+	if e.humanOutput {
+		p.WithDetails("destination", source.String(), "exportFile", file).Titlef(i18n.T("staging started"))
+		p = p.Sub()
+	}
+
+	if e.dryRun {
+		if e.humanOutput {
+			p.Warnf(i18n.T("dry-run enabled; bundle will not be staged"))
+		}
+		return nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	key, err := stager.Stage(ctx, filepath.Base(file), f)
+	if err != nil {
+		return err
+	}
+
+	if e.humanOutput {
+		p.WithDetails("key", key).Infof(i18n.T("bundle staged"))
+	}
+
+	return nil
+}
+
+// spoolVerified copies a tar entry into a temporary file while hashing it. If
+// checksum is set, the entry must match the digest verified on pull so the
+// uploaded bytes are the ones the source produced. The file is removed on
+// Close.
+func spoolVerified(r io.Reader, checksum string) (*spoolFile, error) {
+	f, err := os.CreateTemp("", "datactl-push-*")
+	if err != nil {
+		return nil, err
+	}
+
+	spool := &spoolFile{File: f}
+
+	sha := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, sha), r); err != nil {
+		spool.Close()
+		return nil, err
+	}
+
+	actual := fmt.Sprintf("%x", sha.Sum(nil))
+	if checksum != "" && !strings.EqualFold(checksum, actual) {
+		spool.Close()
+		return nil, errors.NewWithDetails("checksum mismatch", "expected", checksum, "actual", actual)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		spool.Close()
+		return nil, err
+	}
+
+	return spool, nil
+}
+
+type spoolFile struct {
+	*os.File
+}
+
+func (s *spoolFile) Close() error {
+	err := s.File.Close()
+	os.Remove(s.Name())
+	return err
+}
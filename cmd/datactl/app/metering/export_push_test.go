@@ -0,0 +1,337 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metering
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gotidy/ptr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/redhat-marketplace/datactl/pkg/bundle"
+	"github.com/redhat-marketplace/datactl/pkg/clients/marketplace"
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	dataservicev1 "github.com/redhat-marketplace/datactl/pkg/datactl/api/dataservice/v1"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
+	"k8s.io/kubectl/pkg/cmd/get"
+)
+
+var _ = Describe("export_push", func() {
+	It("should spool a file matching the verified checksum", func() {
+		checksum := fmt.Sprintf("%x", sha256.Sum256([]byte("foo")))
+
+		spool, err := spoolVerified(strings.NewReader("foo"), checksum)
+		Expect(err).To(Succeed())
+
+		data, err := io.ReadAll(spool)
+		Expect(err).To(Succeed())
+		Expect(string(data)).To(Equal("foo"))
+
+		Expect(spool.Close()).To(Succeed())
+		_, err = os.Stat(spool.Name())
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("should reject a file not matching the verified checksum", func() {
+		checksum := fmt.Sprintf("%x", sha256.Sum256([]byte("foo")))
+
+		_, err := spoolVerified(strings.NewReader("bar"), checksum)
+		Expect(err).To(MatchError(ContainSubstring("checksum mismatch")))
+	})
+
+	It("should accept files without a verified checksum", func() {
+		spool, err := spoolVerified(strings.NewReader("bar"), "")
+		Expect(err).To(Succeed())
+		Expect(spool.Close()).To(Succeed())
+	})
+
+	It("should print the results in bundle order whichever finishes first", func() {
+		out := &bytes.Buffer{}
+		saves := 0
+
+		results := &pushResults{
+			print: printers.ResourcePrinterFunc(func(obj runtime.Object, w io.Writer) error {
+				_, err := fmt.Fprintln(w, obj.(*dataservicev1.FileInfoCTLAction).Name)
+				return err
+			}),
+			writer: printers.GetNewTabWriter(out),
+			save: func() error {
+				saves = saves + 1
+				return nil
+			},
+		}
+
+		files := []*dataservicev1.FileInfoCTLAction{}
+		for _, name := range []string{"a", "b", "c"} {
+			file := dataservicev1.NewFileInfoCTLAction(&dataservicev1.FileInfo{})
+			file.Name = name
+			files = append(files, file)
+			Expect(results.add(file)).To(Equal(len(files) - 1))
+		}
+
+		results.finish(2, func() { files[2].Pushed = true })
+		Expect(out.String()).To(BeEmpty())
+
+		results.finish(0, func() { files[0].Pushed = true })
+		Expect(out.String()).To(Equal("a\n"))
+
+		results.finish(1, func() { files[1].Pushed = true })
+		Expect(out.String()).To(Equal("a\nb\nc\n"))
+
+		Expect(saves).To(Equal(3))
+		Expect(results.saveErr).To(Succeed())
+	})
+
+	It("should record an upload that is not waited for as pushed", func() {
+		file := newPushedFile("a", "")
+		file.Pushed = false
+		file.UploadError = "100 failed"
+
+		Expect(recordUpload(file, "id", nil)).To(Succeed())
+		Expect(file.Pushed).To(BeTrue())
+		Expect(file.UploadID).To(Equal("id"))
+		Expect(file.UploadStatus).To(BeEmpty())
+		Expect(file.UploadError).To(BeEmpty())
+	})
+
+	It("should record the status of a processed upload", func() {
+		file := newPushedFile("a", "")
+
+		Expect(recordUpload(file, "id", &marketplace.MarketplaceUsageResponse{Status: marketplace.MktplStatusSuccess})).To(Succeed())
+		Expect(file.Pushed).To(BeTrue())
+		Expect(file.UploadStatus).To(Equal("success"))
+	})
+
+	It("should not keep a rejected upload as pushed", func() {
+		file := newPushedFile("a", "")
+
+		err := recordUpload(file, "id", &marketplace.MarketplaceUsageResponse{
+			Status:    marketplace.MktplStatusFailed,
+			ErrorCode: "100",
+			Message:   "invalid report",
+		})
+		Expect(err).To(MatchError(ContainSubstring("upload failed")))
+		Expect(file.Pushed).To(BeFalse())
+		Expect(file.UploadID).To(Equal("id"))
+		Expect(file.UploadStatus).To(Equal("failed"))
+		Expect(file.UploadError).To(Equal("100 invalid report"))
+		Expect(file.Result).To(Equal(dataservicev1.Error))
+	})
+})
+
+// blockingMarketplace holds each upload until it is released, and records how
+// many uploads and push spools there are at once.
+type blockingMarketplace struct {
+	mu sync.Mutex
+
+	// spoolDir is the directory of the push spools
+	spoolDir string
+
+	active, maxActive int
+	maxSpools         int
+	starts            []time.Time
+
+	started chan string
+	release chan struct{}
+}
+
+func (f *blockingMarketplace) Metrics() marketplace.MarketplaceMetrics {
+	return f
+}
+
+func (f *blockingMarketplace) Status(ctx context.Context, id string) (*marketplace.MarketplaceUsageResponse, error) {
+	return nil, nil
+}
+
+func (f *blockingMarketplace) Wait(ctx context.Context, id string) (*marketplace.MarketplaceUsageResponse, error) {
+	return nil, nil
+}
+
+func (f *blockingMarketplace) Upload(ctx context.Context, fileName string, reader io.Reader) (string, error) {
+	spools, err := filepath.Glob(filepath.Join(f.spoolDir, "datactl-push-*"))
+	if err != nil {
+		return "", err
+	}
+
+	f.mu.Lock()
+	f.active = f.active + 1
+	if f.active > f.maxActive {
+		f.maxActive = f.active
+	}
+	if len(spools) > f.maxSpools {
+		f.maxSpools = len(spools)
+	}
+	f.starts = append(f.starts, time.Now())
+	f.mu.Unlock()
+
+	f.started <- fileName
+	<-f.release
+
+	f.mu.Lock()
+	f.active = f.active - 1
+	f.mu.Unlock()
+
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return "", err
+	}
+
+	return "id-" + fileName, nil
+}
+
+var _ = Describe("export_push run", func() {
+	const files = 5
+
+	var (
+		configPath string
+		fake       *blockingMarketplace
+		o          *exportPushOptions
+	)
+
+	// savedUploads returns the names of the files saved as pushed with the id
+	// of their upload
+	savedUploads := func() []string {
+		cfg, err := config.LoadFromFile(configPath)
+		if err != nil || cfg.CurrentMeteringExport == nil {
+			return nil
+		}
+
+		names := []string{}
+		for _, file := range cfg.CurrentMeteringExport.Files {
+			if file.Pushed && file.UploadID == "id-"+file.Name {
+				names = append(names, file.Name)
+			}
+		}
+		return names
+	}
+
+	BeforeEach(func() {
+		dir := GinkgoT().TempDir()
+		configPath = filepath.Join(dir, "config")
+		Expect(os.WriteFile(configPath, nil, 0600)).To(Succeed())
+
+		// the spools are created in the temp dir
+		spoolDir := GinkgoT().TempDir()
+		tmpDir, hasTmpDir := os.LookupEnv("TMPDIR")
+		Expect(os.Setenv("TMPDIR", spoolDir)).To(Succeed())
+		DeferCleanup(func() {
+			if hasTmpDir {
+				os.Setenv("TMPDIR", tmpDir)
+				return
+			}
+			os.Unsetenv("TMPDIR")
+		})
+
+		rhmFlags := config.NewConfigFlags(genericclioptions.NewConfigFlags(true).WithDeprecatedPasswordFlag())
+		rhmFlags.DATACTLConfig = ptr.String(configPath)
+		rhmFlags.MarketplaceHost = ptr.String("marketplace.example.com")
+		rhmFlags.MarketplaceToken = ptr.String("token")
+
+		cfg, err := rhmFlags.RawPersistentConfigLoader().RawConfig()
+		Expect(err).To(Succeed())
+
+		export := &datactlapi.MeteringExport{FileName: filepath.Join(dir, "export.tar")}
+		b, err := bundle.NewBundle(export.FileName)
+		Expect(err).To(Succeed())
+
+		for i := 0; i < files; i++ {
+			data := []byte(fmt.Sprintf("data of file %d", i))
+
+			file := dataservicev1.NewFileInfoCTLAction(&dataservicev1.FileInfo{})
+			file.Name = fmt.Sprintf("file-%d", i)
+			file.VerifiedChecksum = fmt.Sprintf("%x", sha256.Sum256(data))
+			export.Files = append(export.Files, file)
+
+			w, err := b.NewFileWithMetadata(file.Name, int64(len(data)), bundle.NewFileMetadata(file, export.DisplayName()))
+			Expect(err).To(Succeed())
+			_, err = w.Write(data)
+			Expect(err).To(Succeed())
+		}
+		Expect(b.Close()).To(Succeed())
+
+		cfg.CurrentMeteringExport = export
+		Expect(config.ModifyConfig(rhmFlags.ConfigAccess(), *cfg, true)).To(Succeed())
+
+		fake = &blockingMarketplace{
+			spoolDir: spoolDir,
+			started:  make(chan string, files),
+			release:  make(chan struct{}),
+		}
+
+		o = &exportPushOptions{
+			rhmConfigFlags: rhmFlags,
+			PrintFlags:     get.NewGetPrintFlags(),
+			IOStreams:      genericclioptions.IOStreams{Out: GinkgoWriter, ErrOut: GinkgoWriter},
+			workers:        2,
+		}
+		Expect(o.Complete(nil, nil)).To(Succeed())
+		Expect(o.Validate()).To(Succeed())
+		o.marketplace = fake
+	})
+
+	AfterEach(func() {
+		o.bundle.Close()
+	})
+
+	It("should upload a file per worker at once and save each file pushed", func() {
+		done := make(chan error)
+		go func() { done <- o.Run() }()
+
+		Eventually(fake.started, 5*time.Second).Should(Receive())
+		Eventually(fake.started, 5*time.Second).Should(Receive())
+		Consistently(fake.started, 100*time.Millisecond).ShouldNot(Receive())
+
+		for i := 1; i <= files; i++ {
+			fake.release <- struct{}{}
+			Eventually(savedUploads, 5*time.Second).Should(HaveLen(i))
+
+			// the worker released takes the next file
+			if i <= files-2 {
+				Eventually(fake.started, 5*time.Second).Should(Receive())
+				Consistently(fake.started, 20*time.Millisecond).ShouldNot(Receive())
+			}
+		}
+
+		Eventually(done, 5*time.Second).Should(Receive(BeNil()))
+		Expect(o.errs).To(BeEmpty())
+		Expect(fake.maxActive).To(Equal(2))
+		Expect(fake.maxSpools).To(BeNumerically("<=", 2))
+	})
+
+	It("should start uploads at the rate", func() {
+		o.workers = files
+		o.rate = 20
+		close(fake.release)
+
+		Expect(o.Run()).To(Succeed())
+		Expect(savedUploads()).To(HaveLen(files))
+
+		Expect(fake.starts).To(HaveLen(files))
+		for i := 1; i < files; i++ {
+			Expect(fake.starts[i].Sub(fake.starts[i-1])).To(BeNumerically(">=", 40*time.Millisecond))
+		}
+	})
+})
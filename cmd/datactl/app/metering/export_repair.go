@@ -0,0 +1,199 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metering
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/redhat-marketplace/datactl/pkg/bundle"
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	dataservicev1 "github.com/redhat-marketplace/datactl/pkg/datactl/api/dataservice/v1"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers/output"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var (
+	repairLong = templates.LongDesc(i18n.T(`
+		Rebuilds the file records of the active export from its bundle file.
+
+		Each entry of the bundle records its source, source type, remote id and
+		checksum when it is pulled. The entries that have no record in the datactl
+		config file, for example after the config file was lost or restored from a
+		backup, get one from that metadata. The records in the config file are kept.
+
+		Pushes are not recorded in the bundle, so the files added are not marked
+		pushed and are uploaded by the next push.`))
+
+	repairExample = templates.Examples(i18n.T(`
+		# Rebuild the missing file records of the active export
+		{{ .cmd }} export repair
+
+		# List the file records that would be rebuilt
+		{{ .cmd }} export repair --dry-run
+`))
+)
+
+func NewCmdExportRepair(rhmFlags *config.ConfigFlags, f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := exportRepairOptions{
+		rhmConfigFlags: rhmFlags,
+		IOStreams:      ioStreams,
+	}
+
+	cmd := &cobra.Command{
+		Use:                   "repair [(--dry-run)]",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Rebuilds the file records of the active export from its bundle."),
+		Long:                  output.ReplaceCommandStrings(repairLong),
+		Example:               output.ReplaceCommandStrings(repairExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().BoolVar(&o.dryRun, "dry-run", false, i18n.T("No action taken. Print only."))
+
+	return cmd
+}
+
+type exportRepairOptions struct {
+	rhmConfigFlags *config.ConfigFlags
+
+	// flags
+	dryRun bool
+
+	//internal
+	args []string
+
+	rhmRawConfig          *datactlapi.Config
+	currentMeteringExport *datactlapi.MeteringExport
+
+	genericclioptions.IOStreams
+}
+
+func (e *exportRepairOptions) Complete(cmd *cobra.Command, args []string) error {
+	e.args = args
+
+	var err error
+	e.rhmRawConfig, err = e.rhmConfigFlags.RawPersistentConfigLoader().RawConfig()
+	if err != nil {
+		return err
+	}
+
+	e.currentMeteringExport, err = e.rhmConfigFlags.MeteringExport()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (e *exportRepairOptions) Validate() error {
+	if e.currentMeteringExport.FileName == "" {
+		return fmt.Errorf("active export has no file")
+	}
+
+	if _, err := os.Stat(e.currentMeteringExport.FileName); os.IsNotExist(err) {
+		return fmt.Errorf("file does not exist %s", e.currentMeteringExport.FileName)
+	}
+
+	return nil
+}
+
+func (e *exportRepairOptions) Run() error {
+	p := output.NewHumanOutput()
+	p.WithDetails("exportFile", e.currentMeteringExport.FileName).Titlef(i18n.T("repair started"))
+	p = p.Sub()
+
+	if e.dryRun {
+		p.Warnf(i18n.T("dry-run enabled; config will not be saved"))
+	}
+
+	added, err := repairFiles(e.currentMeteringExport)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range added {
+		if file.SourceType == "" {
+			p.WithDetails("name", file.Name).Warnf(i18n.T("file record rebuilt without metadata"))
+			continue
+		}
+
+		p.WithDetails("name", file.Name, "source", file.Source, "sourceType", file.SourceType).Infof(i18n.T("file record rebuilt"))
+	}
+
+	p.WithDetails("files", len(added)).Infof(i18n.T("repair finished"))
+
+	if e.dryRun || len(added) == 0 {
+		return nil
+	}
+
+	return config.ModifyConfig(e.rhmConfigFlags.ConfigAccess(), *e.rhmRawConfig, true)
+}
+
+// repairFiles adds a file record to the export for each entry of its bundle
+// that has none, from the metadata of the entry, and returns the records
+// added. A name written more than once is recorded from its last entry, the
+// one kept when the bundle is compacted.
+func repairFiles(export *datactlapi.MeteringExport) ([]*dataservicev1.FileInfoCTLAction, error) {
+	known := map[string]interface{}{}
+	for _, file := range export.Files {
+		known[file.Name] = nil
+	}
+
+	added := []*dataservicev1.FileInfoCTLAction{}
+	index := map[string]int{}
+
+	err := bundle.WalkTar(export.FileName, func(header *tar.Header, r io.Reader) error {
+		// skip our helper commit file
+		if header.Name == "commit.json" {
+			return nil
+		}
+
+		if _, ok := known[header.Name]; ok {
+			return nil
+		}
+
+		file := bundle.FileInfoFromHeader(header)
+		file.Action = dataservicev1.Pull
+		file.Result = dataservicev1.Ok
+
+		if i, ok := index[header.Name]; ok {
+			added[i] = file
+			return nil
+		}
+
+		index[header.Name] = len(added)
+		added = append(added, file)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	export.Files = append(export.Files, added...)
+	return added, nil
+}
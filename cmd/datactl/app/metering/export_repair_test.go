@@ -0,0 +1,105 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metering
+
+import (
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-marketplace/datactl/pkg/bundle"
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	dataservicev1 "github.com/redhat-marketplace/datactl/pkg/datactl/api/dataservice/v1"
+)
+
+var _ = Describe("export_repair", func() {
+	var export *datactlapi.MeteringExport
+
+	BeforeEach(func() {
+		export = &datactlapi.MeteringExport{
+			FileName: filepath.Join(GinkgoT().TempDir(), "export.tar"),
+			Files: []*dataservicev1.FileInfoCTLAction{
+				newPushedFile("a.tar.gz", "upload-a"),
+			},
+		}
+
+		b, err := bundle.NewBundle(export.FileName)
+		Expect(err).To(Succeed())
+
+		write := func(name, source string) {
+			file := dataservicev1.NewFileInfoCTLAction(&dataservicev1.FileInfo{})
+			file.Name = name
+			file.Id = "id-" + name
+			file.Source = source
+			file.SourceType = "report"
+			file.VerifiedChecksum = "checksum-" + source
+
+			w, err := b.NewFileWithMetadata(name, int64(len(name)), bundle.NewFileMetadata(file, "export"))
+			Expect(err).To(Succeed())
+			_, err = w.Write([]byte(name))
+			Expect(err).To(Succeed())
+		}
+
+		write("a.tar.gz", "ilmt.example.com")
+		write("b.tar.gz", "ilmt.example.com")
+		write("c.tar.gz", "old.example.com")
+		write("c.tar.gz", "new.example.com")
+
+		w, err := b.NewFile("d.tar.gz", 1)
+		Expect(err).To(Succeed())
+		_, err = w.Write([]byte("d"))
+		Expect(err).To(Succeed())
+
+		Expect(b.Close()).To(Succeed())
+	})
+
+	It("should rebuild the missing file records from the bundle metadata", func() {
+		added, err := repairFiles(export)
+		Expect(err).To(Succeed())
+		Expect(added).To(HaveLen(3))
+		Expect(export.Files).To(HaveLen(4))
+
+		// the records in the config are kept
+		Expect(export.Files[0].Pushed).To(BeTrue())
+		Expect(export.Files[0].UploadID).To(Equal("upload-a"))
+
+		b := export.Files[1]
+		Expect(b.Name).To(Equal("b.tar.gz"))
+		Expect(b.Id).To(Equal("id-b.tar.gz"))
+		Expect(b.Source).To(Equal("ilmt.example.com"))
+		Expect(b.SourceType).To(Equal("report"))
+		Expect(b.VerifiedChecksum).To(Equal("checksum-ilmt.example.com"))
+		Expect(b.Result).To(Equal(dataservicev1.Ok))
+		Expect(b.Pushed).To(BeFalse())
+
+		// the last entry of a name is the one kept
+		Expect(export.Files[2].Name).To(Equal("c.tar.gz"))
+		Expect(export.Files[2].Source).To(Equal("new.example.com"))
+
+		Expect(export.Files[3].Name).To(Equal("d.tar.gz"))
+		Expect(export.Files[3].SourceType).To(BeEmpty())
+	})
+
+	It("should not add records twice", func() {
+		_, err := repairFiles(export)
+		Expect(err).To(Succeed())
+
+		added, err := repairFiles(export)
+		Expect(err).To(Succeed())
+		Expect(added).To(BeEmpty())
+		Expect(export.Files).To(HaveLen(4))
+	})
+})
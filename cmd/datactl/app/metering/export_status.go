@@ -13,3 +13,322 @@
 // limitations under the License.
 
 package metering
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/gotidy/ptr"
+	"github.com/redhat-marketplace/datactl/pkg/clients/marketplace"
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	dataservicev1 "github.com/redhat-marketplace/datactl/pkg/datactl/api/dataservice/v1"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers/output"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
+	"k8s.io/kubectl/pkg/cmd/get"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var (
+	statusLong = templates.LongDesc(i18n.T(`
+		Checks the processing status of pushed files on the metrics processing backend.
+
+		Each pushed file has an upload id recorded in the datactl config file. The status
+		of every upload is requested and the final state (success or failed) is recorded
+		into the datactl config file. A failed file is marked as not pushed, so it is
+		not committed.`))
+
+	statusExamples = templates.Examples(i18n.T(`
+		# Show the status of the files pushed in the active export
+	 	{{ .cmd }} export status
+
+		# Include the exports in the history
+		{{ .cmd }} export status --all
+
+		# Poll until every upload has finished processing
+		{{ .cmd }} export status --watch --interval=30s
+`))
+)
+
+func NewCmdExportStatus(rhmFlags *config.ConfigFlags, f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := exportStatusOptions{
+		rhmConfigFlags: rhmFlags,
+		PrintFlags:     get.NewGetPrintFlags(),
+		IOStreams:      ioStreams,
+	}
+
+	cmd := &cobra.Command{
+		Use:                   "status [(--all) (--watch)]",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Shows the processing status of pushed files."),
+		Long:                  output.ReplaceCommandStrings(statusLong),
+		Example:               output.ReplaceCommandStrings(statusExamples),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	o.PrintFlags.AddFlags(cmd)
+	cmd.Flags().MarkHidden("label-columns")
+	cmd.Flags().MarkHidden("sort-by")
+	cmd.Flags().MarkHidden("show-kind")
+	cmd.Flags().MarkHidden("show-managed-fields")
+	cmd.Flags().MarkHidden("show-labels")
+
+	cmd.Flags().BoolVar(&o.all, "all", false, i18n.T("include the exports in the history"))
+	cmd.Flags().BoolVar(&o.watch, "watch", false, i18n.T("poll until every upload reaches a final state"))
+	cmd.Flags().DurationVar(&o.interval, "interval", 10*time.Second, i18n.T("time to wait between polls when watching"))
+	cmd.Flags().DurationVar(&o.timeout, "timeout", 10*time.Minute, i18n.T("maximum time to wait for the status"))
+
+	return cmd
+}
+
+type exportStatusOptions struct {
+	rhmConfigFlags *config.ConfigFlags
+	PrintFlags     *get.PrintFlags
+
+	// Flags
+	all      bool
+	watch    bool
+	interval time.Duration
+	timeout  time.Duration
+
+	//internal
+	humanOutput bool
+	args        []string
+
+	rhmRawConfig *datactlapi.Config
+	marketplace  marketplace.Client
+
+	currentMeteringExport *datactlapi.MeteringExport
+
+	ToPrinter func(string) (printers.ResourcePrinter, error)
+
+	genericclioptions.IOStreams
+}
+
+func (e *exportStatusOptions) Complete(cmd *cobra.Command, args []string) error {
+	e.args = args
+
+	var err error
+	e.rhmRawConfig, err = e.rhmConfigFlags.RawPersistentConfigLoader().RawConfig()
+	if err != nil {
+		return err
+	}
+
+	e.marketplace, err = e.rhmConfigFlags.MarketplaceClient()
+	if err != nil {
+		return err
+	}
+
+	e.currentMeteringExport, err = e.rhmConfigFlags.MeteringExport()
+	if err != nil {
+		return err
+	}
+
+	e.ToPrinter = func(operation string) (printers.ResourcePrinter, error) {
+		e.PrintFlags.NamePrintFlags.Operation = operation
+		return e.PrintFlags.ToPrinter()
+	}
+
+	if e.PrintFlags.OutputFormat == nil || *e.PrintFlags.OutputFormat == "wide" || *e.PrintFlags.OutputFormat == "" {
+		e.humanOutput = true
+		e.PrintFlags.OutputFormat = ptr.String("wide")
+	} else {
+		output.DisableColor()
+	}
+
+	return nil
+}
+
+func (e *exportStatusOptions) Validate() error {
+	if e.watch && e.interval <= 0 {
+		return fmt.Errorf("interval must be greater than 0")
+	}
+
+	return nil
+}
+
+func (e *exportStatusOptions) Run() error {
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	writer := printers.GetNewTabWriter(e.Out)
+	p := output.NewHumanOutput()
+
+	print, err := e.ToPrinter("status")
+	if err != nil {
+		return err
+	}
+
+	print = output.NewUploadStatusCLITableOrStruct(e.PrintFlags, print)
+
+	files := e.uploadedFiles()
+
+	if e.humanOutput {
+		p.WithDetails("uploadHost", e.rhmRawConfig.MarketplaceEndpoint.Host).
+			Titlef(i18n.T("status started"))
+		p = p.Sub()
+	}
+
+	errs := map[string]error{}
+
+	var waitErr error
+
+	for {
+		pending := e.updateStatus(ctx, files, errs)
+
+		if !e.watch || pending == 0 {
+			break
+		}
+
+		if e.humanOutput {
+			p.WithDetails("pending", pending, "files", len(files)).Infof(i18n.T("waiting for uploads to be processed"))
+		}
+
+		select {
+		case <-ctx.Done():
+			waitErr = errors.WrapWithDetails(ctx.Err(), "uploads still processing", "pending", pending)
+		case <-time.After(e.interval):
+		}
+
+		if waitErr != nil {
+			break
+		}
+	}
+
+	counts := map[marketplace.MktplStatus]int{}
+
+	for _, file := range files {
+		counts[marketplace.MktplStatus(file.UploadStatus)]++
+		print.PrintObj(file, writer)
+		writer.Flush()
+	}
+
+	if e.humanOutput {
+		p.WithDetails(
+			"success", counts[marketplace.MktplStatusSuccess],
+			"inProgress", counts[marketplace.MktplStatusInProgress],
+			"failed", counts[marketplace.MktplStatusFailed],
+			"files", len(files),
+		).Infof(i18n.T("status finished"))
+
+		if len(errs) != 0 {
+			p.Errorf(nil, "errors have occurred")
+			p2 := p.Sub()
+			for name, err := range errs {
+				p2.WithDetails("name", name).Errorf(nil, err.Error())
+			}
+		}
+	}
+
+	if err := config.ModifyConfig(e.rhmConfigFlags.ConfigAccess(), *e.rhmRawConfig, true); err != nil {
+		return err
+	}
+
+	return waitErr
+}
+
+// uploadedFiles returns the files of the active export, and the history if
+// requested, that have an upload id to check.
+func (e *exportStatusOptions) uploadedFiles() []*dataservicev1.FileInfoCTLAction {
+	exports := []*datactlapi.MeteringExport{e.currentMeteringExport}
+
+	if e.all {
+		for _, key := range sortedExportKeys(e.rhmRawConfig) {
+			export := e.rhmRawConfig.MeteringExports[key]
+			if export == e.currentMeteringExport {
+				continue
+			}
+			exports = append(exports, export)
+		}
+	}
+
+	files := []*dataservicev1.FileInfoCTLAction{}
+
+	for _, export := range exports {
+		if export == nil {
+			continue
+		}
+
+		for _, file := range export.Files {
+			if !file.Pushed || file.UploadID == "" {
+				continue
+			}
+
+			files = append(files, file)
+		}
+	}
+
+	return files
+}
+
+// updateStatus requests the status of each file not in a final state and
+// returns the number of files still being processed.
+func (e *exportStatusOptions) updateStatus(
+	ctx context.Context,
+	files []*dataservicev1.FileInfoCTLAction,
+	errs map[string]error,
+) int {
+	pending := 0
+
+	for _, file := range files {
+		if marketplace.MktplStatus(file.UploadStatus).IsFinal() {
+			continue
+		}
+
+		log := logger.WithValues("file", file.Name, "uploadID", file.UploadID)
+
+		status, err := e.marketplace.Metrics().Status(ctx, file.UploadID)
+		if err != nil {
+			details := errors.GetDetails(err)
+			err = errors.Errorf("%s %+v", err.Error(), details)
+			log.Info("failed to get status", "err", err)
+			errs[file.Name] = err
+			pending = pending + 1
+			continue
+		}
+
+		delete(errs, file.Name)
+
+		// a rejected file is reported in its upload error, not as an error of
+		// the status request
+		_ = recordUploadStatus(file, status)
+
+		if !status.Status.IsFinal() {
+			pending = pending + 1
+		}
+
+		log.Info("retrieved status", "status", status.Status)
+	}
+
+	return pending
+}
+
+// recordUploadStatus records the processing status of the upload of a file. A
+// file the marketplace rejected is not pushed anymore, so a commit doesn't
+// delete data the marketplace never accepted, and its error is returned.
+func recordUploadStatus(file *dataservicev1.FileInfoCTLAction, status *marketplace.MarketplaceUsageResponse) error {
+	file.UploadStatus = string(status.Status)
+	file.UploadError = ""
+
+	if status.Status != marketplace.MktplStatusFailed {
+		return nil
+	}
+
+	err := errors.NewWithDetails("upload failed", "uploadID", file.UploadID, "errorCode", status.ErrorCode, "message", status.Message)
+	file.UploadError = strings.TrimSpace(fmt.Sprintf("%s %s", status.ErrorCode, status.Message))
+	file.Error = err.Error()
+	file.Result = dataservicev1.Error
+	file.Pushed = false
+	return err
+}
@@ -0,0 +1,136 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metering
+
+import (
+	"context"
+	"io"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-marketplace/datactl/pkg/clients/marketplace"
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	dataservicev1 "github.com/redhat-marketplace/datactl/pkg/datactl/api/dataservice/v1"
+)
+
+type fakeMarketplace struct {
+	statuses map[string]*marketplace.MarketplaceUsageResponse
+	calls    map[string]int
+}
+
+func (f *fakeMarketplace) Metrics() marketplace.MarketplaceMetrics {
+	return f
+}
+
+func (f *fakeMarketplace) Status(ctx context.Context, id string) (*marketplace.MarketplaceUsageResponse, error) {
+	f.calls[id]++
+	return f.statuses[id], nil
+}
+
+func (f *fakeMarketplace) Wait(ctx context.Context, id string) (*marketplace.MarketplaceUsageResponse, error) {
+	return f.Status(ctx, id)
+}
+
+func (f *fakeMarketplace) Upload(ctx context.Context, fileName string, reader io.Reader) (string, error) {
+	return "", nil
+}
+
+func newPushedFile(name, uploadID string) *dataservicev1.FileInfoCTLAction {
+	file := dataservicev1.NewFileInfoCTLAction(&dataservicev1.FileInfo{})
+	file.Name = name
+	file.UploadID = uploadID
+	file.Pushed = true
+	return file
+}
+
+var _ = Describe("export_status", func() {
+	var (
+		sut  *exportStatusOptions
+		fake *fakeMarketplace
+	)
+
+	BeforeEach(func() {
+		fake = &fakeMarketplace{
+			statuses: map[string]*marketplace.MarketplaceUsageResponse{
+				"a": {Status: marketplace.MktplStatusSuccess},
+				"b": {Status: marketplace.MktplStatusInProgress},
+				"c": {Status: marketplace.MktplStatusFailed, ErrorCode: "100", Message: "bad format"},
+			},
+			calls: map[string]int{},
+		}
+
+		current := &datactlapi.MeteringExport{
+			FileName: "current",
+			Files: []*dataservicev1.FileInfoCTLAction{
+				newPushedFile("a.tar.gz", "a"),
+				newPushedFile("b.tar.gz", "b"),
+				dataservicev1.NewFileInfoCTLAction(&dataservicev1.FileInfo{}),
+			},
+		}
+
+		sut = &exportStatusOptions{
+			marketplace:           fake,
+			currentMeteringExport: current,
+			rhmRawConfig: &datactlapi.Config{
+				CurrentMeteringExport: current,
+				MeteringExports: map[string]*datactlapi.MeteringExport{
+					"old": {
+						FileName: "old",
+						Files: []*dataservicev1.FileInfoCTLAction{
+							newPushedFile("c.tar.gz", "c"),
+						},
+					},
+				},
+			},
+		}
+	})
+
+	It("should only check pushed files of the active export", func() {
+		files := sut.uploadedFiles()
+		Expect(files).To(HaveLen(2))
+	})
+
+	It("should include the history", func() {
+		sut.all = true
+		files := sut.uploadedFiles()
+		Expect(files).To(HaveLen(3))
+	})
+
+	It("should record the status and skip final states", func() {
+		sut.all = true
+		files := sut.uploadedFiles()
+		errs := map[string]error{}
+
+		pending := sut.updateStatus(context.Background(), files, errs)
+		Expect(pending).To(Equal(1))
+		Expect(errs).To(BeEmpty())
+
+		Expect(files[0].UploadStatus).To(Equal(string(marketplace.MktplStatusSuccess)))
+		Expect(files[1].UploadStatus).To(Equal(string(marketplace.MktplStatusInProgress)))
+		Expect(files[2].UploadStatus).To(Equal(string(marketplace.MktplStatusFailed)))
+		Expect(files[2].UploadError).To(Equal("100 bad format"))
+
+		// a rejected file isn't committed
+		Expect(files[0].Pushed).To(BeTrue())
+		Expect(files[2].Pushed).To(BeFalse())
+
+		fake.statuses["b"] = &marketplace.MarketplaceUsageResponse{Status: marketplace.MktplStatusSuccess}
+
+		pending = sut.updateStatus(context.Background(), files, errs)
+		Expect(pending).To(Equal(0))
+		Expect(fake.calls).To(Equal(map[string]int{"a": 1, "b": 2, "c": 1}))
+	})
+})
@@ -0,0 +1,230 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metering
+
+import (
+	"emperror.dev/errors"
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers/output"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/kubectl/pkg/cmd/get"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var (
+	syncLong = templates.LongDesc(i18n.T(`
+		Pulls from the sources, pushes the active export and commits the pushed files.
+
+		The stages run in order and the sync stops at the first stage that fails.
+		Only the files that were pushed are committed, so the sources keep the
+		files that didn't reach the upload API.
+
+		The stage reached is saved in the datactl config file after each stage.
+		Running sync again continues with the stage that failed.`))
+
+	syncExample = templates.Examples(i18n.T(`
+		# Pull, push and commit the active export
+		{{ .cmd }} export sync
+
+		# Sync the files of a particular source type
+		{{ .cmd }} export sync --source-type dataService
+`))
+)
+
+func NewCmdExportSync(rhmFlags *config.ConfigFlags, f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := exportSyncOptions{
+		rhmConfigFlags: rhmFlags,
+		IOStreams:      ioStreams,
+	}
+
+	cmd := &cobra.Command{
+		Use:                   "sync [(--source-type SOURCE_TYPE) (--source-name SOURCE_NAME)]",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Pulls, pushes and commits files in one step."),
+		Long:                  output.ReplaceCommandStrings(syncLong),
+		Example:               output.ReplaceCommandStrings(syncExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVar(&o.sourceType, "source-type", EMPTY, i18n.T("Source Type"))
+	cmd.Flags().StringVar(&o.sourceName, "source-name", EMPTY, i18n.T("Source Name"))
+	addSourcePullFlags(cmd.Flags())
+
+	return cmd
+}
+
+type exportSyncOptions struct {
+	rhmConfigFlags *config.ConfigFlags
+
+	// flags
+	sourceName, sourceType string
+
+	//internal
+	args  []string
+	flags *pflag.FlagSet
+
+	rhmRawConfig          *datactlapi.Config
+	currentMeteringExport *datactlapi.MeteringExport
+
+	// the stages of the sync
+	runPull, runPush, runCommit func() error
+
+	genericclioptions.IOStreams
+}
+
+func (o *exportSyncOptions) Complete(cmd *cobra.Command, args []string) error {
+	o.args = args
+	o.runPull = o.pull
+	o.runPush = o.push
+	o.runCommit = o.commit
+
+	if cmd != nil {
+		o.flags = cmd.Flags()
+	}
+
+	var err error
+	o.rhmRawConfig, err = o.rhmConfigFlags.RawPersistentConfigLoader().RawConfig()
+	if err != nil {
+		return err
+	}
+
+	o.currentMeteringExport, err = o.rhmConfigFlags.MeteringExport()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (o *exportSyncOptions) Validate() error {
+	switch o.currentMeteringExport.SyncStage {
+	case "", datactlapi.SyncStagePulled, datactlapi.SyncStagePushed:
+		return nil
+	default:
+		return errors.NewWithDetails("unknown sync stage", "stage", o.currentMeteringExport.SyncStage)
+	}
+}
+
+func (o *exportSyncOptions) Run() error {
+	p := output.NewHumanOutput()
+
+	if stage := o.currentMeteringExport.SyncStage; stage != "" {
+		p.WithDetails("stage", stage).Infof(i18n.T("continuing sync after stage"))
+	}
+
+	if o.currentMeteringExport.SyncStage == "" {
+		if err := o.runPull(); err != nil {
+			return err
+		}
+
+		if err := o.saveStage(datactlapi.SyncStagePulled); err != nil {
+			return err
+		}
+	}
+
+	if o.currentMeteringExport.SyncStage == datactlapi.SyncStagePulled {
+		if err := o.runPush(); err != nil {
+			return err
+		}
+
+		if err := o.saveStage(datactlapi.SyncStagePushed); err != nil {
+			return err
+		}
+	}
+
+	if err := o.runCommit(); err != nil {
+		return err
+	}
+
+	if err := o.saveStage(""); err != nil {
+		return err
+	}
+
+	p.WithDetails("exportFile", o.currentMeteringExport.FileName).Infof(i18n.T("sync complete"))
+	return nil
+}
+
+func (o *exportSyncOptions) pull() error {
+	pull := exportPullOptions{
+		rhmConfigFlags: o.rhmConfigFlags,
+		PrintFlags:     get.NewGetPrintFlags(),
+		IOStreams:      o.IOStreams,
+		sourceName:     o.sourceName,
+		sourceType:     o.sourceType,
+		flags:          o.flags,
+	}
+
+	if err := runOptionsOf(&pull); err != nil {
+		return err
+	}
+
+	if len(pull.errs) != 0 {
+		return errors.NewWithDetails("sources failed to pull, sync stopped", "sources", pull.failedSources())
+	}
+
+	return nil
+}
+
+func (o *exportSyncOptions) push() error {
+	push := exportPushOptions{
+		rhmConfigFlags: o.rhmConfigFlags,
+		PrintFlags:     get.NewGetPrintFlags(),
+		IOStreams:      o.IOStreams,
+		workers:        defaultPushWorkers,
+	}
+
+	if err := runOptionsOf(&push); err != nil {
+		return err
+	}
+
+	if len(push.errs) != 0 {
+		return errors.NewWithDetails("files failed to push, sync stopped", "files", len(push.errs))
+	}
+
+	return nil
+}
+
+func (o *exportSyncOptions) commit() error {
+	commit := exportCommitOptions{
+		rhmConfigFlags: o.rhmConfigFlags,
+		PrintFlags:     get.NewGetPrintFlags(),
+		IOStreams:      o.IOStreams,
+	}
+
+	if err := runOptionsOf(&commit); err != nil {
+		return err
+	}
+
+	if len(commit.errs) != 0 {
+		return errors.WrapIf(errors.Combine(commit.errs...), "files failed to commit, sync stopped")
+	}
+
+	return nil
+}
+
+// saveStage records the stage reached by the sync in the config.
+func (o *exportSyncOptions) saveStage(stage string) error {
+	o.currentMeteringExport.SyncStage = stage
+	return config.ModifyConfig(o.rhmConfigFlags.ConfigAccess(), *o.rhmRawConfig, true)
+}
@@ -0,0 +1,129 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metering
+
+import (
+	"os"
+	"path/filepath"
+
+	"emperror.dev/errors"
+	"github.com/gotidy/ptr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+var _ = Describe("export_sync", func() {
+	It("should reject an unknown sync stage", func() {
+		o := exportSyncOptions{currentMeteringExport: &datactlapi.MeteringExport{SyncStage: datactlapi.SyncStagePushed}}
+		Expect(o.Validate()).To(Succeed())
+
+		o.currentMeteringExport.SyncStage = "unknown"
+		Expect(o.Validate()).ToNot(Succeed())
+	})
+
+	Describe("stages", func() {
+		var (
+			configPath string
+			stages     []string
+			pushErr    error
+		)
+
+		// newSync returns a sync of the saved config with stages that record
+		// their runs
+		newSync := func() *exportSyncOptions {
+			rhmFlags := config.NewConfigFlags(genericclioptions.NewConfigFlags(true).WithDeprecatedPasswordFlag())
+			rhmFlags.DATACTLConfig = ptr.String(configPath)
+
+			o := &exportSyncOptions{
+				rhmConfigFlags: rhmFlags,
+				IOStreams:      genericclioptions.IOStreams{Out: GinkgoWriter, ErrOut: GinkgoWriter},
+			}
+			Expect(o.Complete(nil, nil)).To(Succeed())
+
+			o.runPull = func() error {
+				stages = append(stages, datactlapi.SyncStagePulled)
+				return nil
+			}
+			o.runPush = func() error {
+				stages = append(stages, datactlapi.SyncStagePushed)
+				return pushErr
+			}
+			o.runCommit = func() error {
+				stages = append(stages, "committed")
+				return nil
+			}
+
+			return o
+		}
+
+		savedStage := func() string {
+			cfg, err := config.LoadFromFile(configPath)
+			Expect(err).To(Succeed())
+			Expect(cfg.CurrentMeteringExport).ToNot(BeNil())
+			return cfg.CurrentMeteringExport.SyncStage
+		}
+
+		BeforeEach(func() {
+			dir := GinkgoT().TempDir()
+			configPath = filepath.Join(dir, "config")
+			Expect(os.WriteFile(configPath, nil, 0600)).To(Succeed())
+
+			rhmFlags := config.NewConfigFlags(genericclioptions.NewConfigFlags(true).WithDeprecatedPasswordFlag())
+			rhmFlags.DATACTLConfig = ptr.String(configPath)
+
+			cfg, err := rhmFlags.RawPersistentConfigLoader().RawConfig()
+			Expect(err).To(Succeed())
+			cfg.CurrentMeteringExport = &datactlapi.MeteringExport{FileName: filepath.Join(dir, "export.tar.gz")}
+			Expect(config.ModifyConfig(rhmFlags.ConfigAccess(), *cfg, true)).To(Succeed())
+
+			stages = nil
+			pushErr = nil
+		})
+
+		It("should stop after a failed push and continue with it", func() {
+			pushErr = errors.New("push failed")
+
+			o := newSync()
+			Expect(o.Validate()).To(Succeed())
+			Expect(o.Run()).To(MatchError("push failed"))
+
+			Expect(stages).To(Equal([]string{datactlapi.SyncStagePulled, datactlapi.SyncStagePushed}))
+			Expect(savedStage()).To(Equal(datactlapi.SyncStagePulled))
+
+			// the rerun skips the pull
+			pushErr = nil
+			stages = nil
+
+			o = newSync()
+			Expect(o.Validate()).To(Succeed())
+			Expect(o.Run()).To(Succeed())
+
+			Expect(stages).To(Equal([]string{datactlapi.SyncStagePushed, "committed"}))
+			Expect(savedStage()).To(BeEmpty())
+		})
+
+		It("should run every stage", func() {
+			o := newSync()
+			Expect(o.Run()).To(Succeed())
+
+			Expect(stages).To(Equal([]string{datactlapi.SyncStagePulled, datactlapi.SyncStagePushed, "committed"}))
+			Expect(savedStage()).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,145 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metering
+
+import (
+	"emperror.dev/errors"
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers/output"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var (
+	useLong = templates.LongDesc(i18n.T(`
+		Switches the active export to an export in the history.
+
+		The active export is archived into the history and the selected export
+		becomes the active export for the following pull, push and commit commands.
+		Use "{{ .cmd }} export list" to find the name of an export.`))
+
+	useExample = templates.Examples(i18n.T(`
+		# Make a previous export the active export
+		{{ .cmd }} export use export-20240101T000000Z
+`))
+)
+
+func NewCmdExportUse(rhmFlags *config.ConfigFlags, f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := exportUseOptions{
+		rhmConfigFlags: rhmFlags,
+		IOStreams:      ioStreams,
+	}
+
+	cmd := &cobra.Command{
+		Use:                   "use NAME",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Switches the active export."),
+		Long:                  output.ReplaceCommandStrings(useLong),
+		Example:               output.ReplaceCommandStrings(useExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	return cmd
+}
+
+type exportUseOptions struct {
+	rhmConfigFlags *config.ConfigFlags
+
+	//internal
+	args []string
+	name string
+
+	rhmRawConfig *datactlapi.Config
+
+	currentMeteringExport *datactlapi.MeteringExport
+
+	genericclioptions.IOStreams
+}
+
+func (e *exportUseOptions) Complete(cmd *cobra.Command, args []string) error {
+	e.args = args
+
+	if len(args) != 1 {
+		return helpErrorf(cmd, "export name is required")
+	}
+
+	e.name = args[0]
+
+	var err error
+	e.rhmRawConfig, err = e.rhmConfigFlags.RawPersistentConfigLoader().RawConfig()
+	if err != nil {
+		return err
+	}
+
+	e.currentMeteringExport, err = e.rhmConfigFlags.MeteringExport()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (e *exportUseOptions) Validate() error {
+	if _, ok := e.rhmRawConfig.MeteringExports[e.name]; !ok {
+		return errors.NewWithDetails("export not found in history", "name", e.name)
+	}
+
+	return nil
+}
+
+func (e *exportUseOptions) Run() error {
+	p := output.NewHumanOutput()
+
+	if err := useExport(e.rhmRawConfig, e.currentMeteringExport, e.name); err != nil {
+		return err
+	}
+
+	if err := config.ModifyConfig(e.rhmConfigFlags.ConfigAccess(), *e.rhmRawConfig, true); err != nil {
+		return err
+	}
+
+	p.WithDetails("name", e.name, "exportFile", e.rhmRawConfig.CurrentMeteringExport.FileName).Infof(i18n.T("active export switched"))
+	return nil
+}
+
+// useExport archives the current export and makes the export recorded under
+// name the current one.
+func useExport(cfg *datactlapi.Config, current *datactlapi.MeteringExport, name string) error {
+	selected, ok := cfg.MeteringExports[name]
+	if !ok {
+		return errors.NewWithDetails("export not found in history", "name", name)
+	}
+
+	if selected == current {
+		return nil
+	}
+
+	if _, err := archiveExport(cfg, current); err != nil {
+		return err
+	}
+
+	delete(cfg.MeteringExports, name)
+	cfg.CurrentMeteringExport = selected
+
+	return nil
+}
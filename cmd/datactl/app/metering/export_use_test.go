@@ -0,0 +1,81 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metering
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	dataservicev1 "github.com/redhat-marketplace/datactl/pkg/datactl/api/dataservice/v1"
+)
+
+var _ = Describe("export_use", func() {
+	var (
+		cfg     *datactlapi.Config
+		current *datactlapi.MeteringExport
+		old     *datactlapi.MeteringExport
+	)
+
+	BeforeEach(func() {
+		current = &datactlapi.MeteringExport{
+			FileName: "current.tar",
+			Files: []*dataservicev1.FileInfoCTLAction{
+				newPushedFile("a.tar.gz", "a"),
+			},
+		}
+
+		old = &datactlapi.MeteringExport{
+			Name:     "export-old",
+			FileName: "old.tar",
+		}
+
+		cfg = &datactlapi.Config{
+			CurrentMeteringExport: current,
+			MeteringExports: map[string]*datactlapi.MeteringExport{
+				"export-old": old,
+			},
+		}
+	})
+
+	It("should archive the current export under a timestamped name", func() {
+		name, err := archiveExport(cfg, current)
+		Expect(err).To(Succeed())
+		Expect(name).To(HavePrefix("export-"))
+		Expect(current.Name).To(Equal(name))
+		Expect(cfg.MeteringExports).To(HaveKeyWithValue(name, current))
+	})
+
+	It("should not archive an empty export", func() {
+		name, err := archiveExport(cfg, &datactlapi.MeteringExport{})
+		Expect(err).To(Succeed())
+		Expect(name).To(BeEmpty())
+		Expect(cfg.MeteringExports).To(HaveLen(1))
+	})
+
+	It("should switch the active export", func() {
+		Expect(useExport(cfg, current, "export-old")).To(Succeed())
+		Expect(cfg.CurrentMeteringExport).To(Equal(old))
+		Expect(cfg.MeteringExports).ToNot(HaveKey("export-old"))
+		Expect(cfg.MeteringExports).To(HaveKeyWithValue(current.Name, current))
+
+		summary := summarizeExport(current)
+		Expect(summary).To(Equal(exportSummary{files: 1, pushed: 1}))
+	})
+
+	It("should fail on an unknown export", func() {
+		Expect(useExport(cfg, current, "missing")).ToNot(Succeed())
+	})
+})
@@ -20,6 +20,9 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"k8s.io/klog/v2"
+
+	// registers the built in source types
+	_ "github.com/redhat-marketplace/datactl/cmd/datactl/app/sources/add"
 )
 
 func TestMetering(t *testing.T) {
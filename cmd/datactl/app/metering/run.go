@@ -0,0 +1,347 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metering
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers/output"
+	"github.com/redhat-marketplace/datactl/pkg/schedule"
+	"github.com/redhat-marketplace/datactl/pkg/sources"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/kubectl/pkg/cmd/get"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var (
+	runLong = templates.LongDesc(i18n.T(`
+		Runs the export cycle on a schedule until the process is stopped.
+
+		Each cycle pulls from all sources, pushes the active export when the upload
+		API is reachable and commits the pushed files. Files that are not pushed
+		are kept by their sources and pushed again by the next cycle. When all the
+		files of the active export are pushed and committed, the export is archived
+		and a new one is started.
+
+		A failed cycle is retried with an exponential backoff until the next
+		scheduled cycle. On SIGTERM or interrupt, the cycle in progress finishes
+		and the command exits.
+
+		Sources that never pulled must be pulled once with "{{ .cmd }} export pull"
+		to set the date their pulls start from, as the dates are not prompted for.
+		Sources that already pulled up to yesterday are skipped until the next day.`))
+
+	runExample = templates.Examples(i18n.T(`
+		# Run the export cycle every day at 2am
+		{{ .cmd }} run --schedule "0 2 * * *"
+
+		# Run the export cycle every 6 hours, starting now
+		{{ .cmd }} run --interval 6h
+
+		# Retry failed cycles after 5 minutes, doubling up to 2 hours
+		{{ .cmd }} run --interval 24h --backoff 5m --max-backoff 2h
+`))
+)
+
+func NewCmdRun(rhmFlags *config.ConfigFlags, f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := runOptions{
+		rhmConfigFlags: rhmFlags,
+		IOStreams:      ioStreams,
+	}
+
+	cmd := &cobra.Command{
+		Use:                   "run (--schedule SCHEDULE | --interval INTERVAL)",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Pulls, pushes and commits files on a schedule."),
+		Long:                  output.ReplaceCommandStrings(runLong),
+		Example:               output.ReplaceCommandStrings(runExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVar(&o.schedule, "schedule", "", i18n.T("cron schedule of the cycles, like \"0 2 * * *\""))
+	cmd.Flags().DurationVar(&o.interval, "interval", 0, i18n.T("time between the cycles, the first cycle runs immediately"))
+	cmd.Flags().DurationVar(&o.backoff, "backoff", time.Minute, i18n.T("time to wait before retrying a failed cycle, doubled on each failure"))
+	cmd.Flags().DurationVar(&o.maxBackoff, "max-backoff", time.Hour, i18n.T("maximum time to wait before retrying a failed cycle"))
+	addSourcePullFlags(cmd.Flags(), sources.StartDateFlag, sources.EndDateFlag)
+
+	return cmd
+}
+
+type runOptions struct {
+	rhmConfigFlags *config.ConfigFlags
+
+	// flags
+	schedule            string
+	interval            time.Duration
+	backoff, maxBackoff time.Duration
+
+	//internal
+	args     []string
+	flags    *pflag.FlagSet
+	sched    schedule.Schedule
+	runCycle func() error
+	now      func() time.Time
+
+	genericclioptions.IOStreams
+}
+
+func (o *runOptions) Complete(cmd *cobra.Command, args []string) error {
+	o.args = args
+	o.now = time.Now
+	o.runCycle = o.cycle
+
+	if cmd != nil {
+		o.flags = cmd.Flags()
+	}
+
+	if o.schedule == "" {
+		o.sched = schedule.Every(o.interval)
+		return nil
+	}
+
+	var err error
+	o.sched, err = schedule.ParseCron(o.schedule)
+	return err
+}
+
+func (o *runOptions) Validate() error {
+	if (o.schedule == "") == (o.interval == 0) {
+		return errors.New("one of --schedule or --interval is required")
+	}
+
+	if o.interval < 0 {
+		return errors.NewWithDetails("interval must be positive", "interval", o.interval)
+	}
+
+	if o.backoff <= 0 || o.maxBackoff < o.backoff {
+		return errors.NewWithDetails("backoff must be positive and not greater than max-backoff", "backoff", o.backoff, "maxBackoff", o.maxBackoff)
+	}
+
+	return nil
+}
+
+func (o *runOptions) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return o.loop(ctx)
+}
+
+// loop runs the cycles until ctx is done. A cycle in progress is not
+// interrupted, the loop stops before the next one.
+func (o *runOptions) loop(ctx context.Context) error {
+	p := output.NewHumanOutput()
+
+	next := o.now()
+	if o.interval == 0 {
+		next = o.sched.Next(next)
+	}
+
+	failures := 0
+
+	for {
+		if next.IsZero() {
+			return errors.NewWithDetails("schedule has no next cycle", "schedule", o.schedule)
+		}
+
+		p.WithDetails("next", next.Format(time.RFC3339)).Infof(i18n.T("waiting for next cycle"))
+
+		timer := time.NewTimer(next.Sub(o.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			p.Infof(i18n.T("stopped"))
+			return nil
+		case <-timer.C:
+		}
+
+		p.Titlef(i18n.T("cycle started"))
+		err := o.runCycle()
+
+		scheduled := o.sched.Next(o.now())
+
+		if err == nil {
+			failures = 0
+			next = scheduled
+			p.Infof(i18n.T("cycle complete"))
+			continue
+		}
+
+		failures = failures + 1
+		retry := o.now().Add(o.retryDelay(failures))
+		next = scheduled
+		if retry.Before(scheduled) || scheduled.IsZero() {
+			next = retry
+		}
+
+		p.WithDetails("failures", failures).Errorf(err, i18n.T("cycle failed"))
+	}
+}
+
+// retryDelay returns the backoff after a number of failed cycles in a row.
+func (o *runOptions) retryDelay(failures int) time.Duration {
+	delay := o.backoff
+	for i := 1; i < failures && delay < o.maxBackoff; i++ {
+		delay = delay * 2
+	}
+
+	if delay > o.maxBackoff {
+		delay = o.maxBackoff
+	}
+
+	return delay
+}
+
+// cycle pulls, pushes and commits the active export with the options of the
+// export commands, and starts a new export when the active one is complete.
+// The config is loaded again, so changes saved by other commands since the
+// last cycle are used.
+func (o *runOptions) cycle() error {
+	rhmFlags := o.rhmConfigFlags.WithoutCache()
+
+	// nothing can answer a prompt
+	ioStreams := genericclioptions.IOStreams{In: strings.NewReader(""), Out: o.Out, ErrOut: o.ErrOut}
+
+	var errs []error
+
+	// a cycle can run more than once a day, after the sources pulled up to
+	// yesterday
+	pull := exportPullOptions{
+		rhmConfigFlags: rhmFlags,
+		PrintFlags:     get.NewGetPrintFlags(),
+		IOStreams:      ioStreams,
+		flags:          o.flags,
+		skipUpToDate:   true,
+	}
+
+	// the sources that can't pull are reported by Run, so they don't stop the
+	// others
+	if err := pull.Complete(nil, nil); err != nil {
+		return err
+	}
+
+	if err := pull.Run(); err != nil {
+		return err
+	}
+
+	if len(pull.errs) != 0 {
+		errs = append(errs, errors.NewWithDetails("sources failed to pull", "sources", pull.failedSources()))
+	}
+
+	rhmRawConfig, err := rhmFlags.RawPersistentConfigLoader().RawConfig()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	check := sources.CheckUploadAPI(ctx, rhmFlags, rhmRawConfig)
+	if check.Passed() {
+		push := exportPushOptions{
+			rhmConfigFlags: rhmFlags,
+			PrintFlags:     get.NewGetPrintFlags(),
+			IOStreams:      ioStreams,
+			workers:        defaultPushWorkers,
+		}
+
+		if err := runOptionsOf(&push); err != nil {
+			return errors.Combine(append(errs, err)...)
+		}
+
+		if len(push.errs) != 0 {
+			errs = append(errs, errors.NewWithDetails("files failed to push", "files", len(push.errs)))
+		}
+	} else {
+		step, _ := check.FailedStep()
+		errs = append(errs, errors.NewWithDetails("upload api is not reachable, push skipped", "step", step.Name, "error", step.Error))
+	}
+
+	// the files that are not pushed are skipped by the commit, so one file
+	// that can't be pushed doesn't hold back the others
+	commit := exportCommitOptions{
+		rhmConfigFlags: rhmFlags,
+		PrintFlags:     get.NewGetPrintFlags(),
+		IOStreams:      ioStreams,
+	}
+
+	if err := runOptionsOf(&commit); err != nil {
+		return errors.Combine(append(errs, err)...)
+	}
+
+	if len(commit.errs) != 0 {
+		errs = append(errs, errors.Combine(commit.errs...))
+		return errors.Combine(errs...)
+	}
+
+	export, err := rhmFlags.MeteringExport()
+	if err != nil {
+		return errors.Combine(append(errs, err)...)
+	}
+
+	// the export is complete once all of its files are pushed and committed.
+	// The commit succeeded, so the pushed files of the sources that commit are
+	// committed.
+	summary := summarizeExport(export)
+	if summary.files == 0 || summary.pushed != summary.files {
+		return errors.Combine(errs...)
+	}
+
+	newExport := exportNewOptions{
+		rhmConfigFlags: rhmFlags,
+		IOStreams:      ioStreams,
+	}
+
+	if err := runOptionsOf(&newExport); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Combine(errs...)
+}
+
+type commandOptions interface {
+	Complete(cmd *cobra.Command, args []string) error
+	Validate() error
+	Run() error
+}
+
+// runOptionsOf completes, validates and runs the options of a command like
+// its cobra command does.
+func runOptionsOf(o commandOptions) error {
+	if err := o.Complete(nil, nil); err != nil {
+		return err
+	}
+
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	return o.Run()
+}
@@ -0,0 +1,192 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metering
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/gotidy/ptr"
+	"github.com/redhat-marketplace/datactl/pkg/bundle"
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers"
+	"github.com/redhat-marketplace/datactl/pkg/sources"
+	"github.com/spf13/pflag"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+const runSourceType datactlapi.SourceType = "RunTest"
+
+// runSourcePulls counts the pulls of the sources of runSourceType.
+var runSourcePulls int
+
+func init() {
+	sources.MustRegister(sources.Registration{
+		Type:   runSourceType,
+		Schema: []sources.Property{{Name: "last-pull-date"}},
+		New: func(rhmConfigFlags *config.ConfigFlags, printer printers.Printer, source datactlapi.Source) (sources.Source, error) {
+			return &runSource{}, nil
+		},
+		PullOptions: func(req *sources.PullRequest) (sources.GenericOptions, error) {
+			_, _, err := sources.DateRange(req, req.Source.Properties["last-pull-date"])
+			return nil, err
+		},
+	})
+}
+
+type runSource struct{}
+
+func (s *runSource) Pull(ctx context.Context, export *datactlapi.MeteringExport, bundleFile *bundle.BundleFile, options sources.GenericOptions) (int, error) {
+	runSourcePulls = runSourcePulls + 1
+	return 0, nil
+}
+
+func (s *runSource) GetResponse() string {
+	return ""
+}
+
+var _ = Describe("run", func() {
+	var o *runOptions
+
+	BeforeEach(func() {
+		o = &runOptions{
+			interval:   time.Hour,
+			backoff:    time.Minute,
+			maxBackoff: 5 * time.Minute,
+		}
+		Expect(o.Complete(nil, nil)).To(Succeed())
+	})
+
+	It("should require one of schedule or interval", func() {
+		Expect(o.Validate()).To(Succeed())
+
+		o.schedule = "@daily"
+		Expect(o.Validate()).ToNot(Succeed())
+
+		o.interval = 0
+		Expect(o.Validate()).To(Succeed())
+
+		o.schedule = ""
+		Expect(o.Validate()).ToNot(Succeed())
+	})
+
+	It("should double the backoff up to the max", func() {
+		Expect(o.retryDelay(1)).To(Equal(time.Minute))
+		Expect(o.retryDelay(2)).To(Equal(2 * time.Minute))
+		Expect(o.retryDelay(3)).To(Equal(4 * time.Minute))
+		Expect(o.retryDelay(4)).To(Equal(5 * time.Minute))
+		Expect(o.retryDelay(100)).To(Equal(5 * time.Minute))
+	})
+
+	It("should retry failed cycles before the next scheduled one and stop when cancelled", func() {
+		o.interval = time.Hour
+		o.backoff = time.Millisecond
+		o.maxBackoff = 4 * time.Millisecond
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cycles := 0
+		o.runCycle = func() error {
+			cycles = cycles + 1
+			if cycles == 3 {
+				cancel()
+				return nil
+			}
+			return errors.New("failed")
+		}
+
+		done := make(chan error)
+		go func() { done <- o.loop(ctx) }()
+
+		Eventually(done, 5*time.Second).Should(Receive(BeNil()))
+		Expect(cycles).To(Equal(3))
+	})
+})
+
+var _ = Describe("run cycle", func() {
+	var (
+		o          *runOptions
+		configPath string
+	)
+
+	BeforeEach(func() {
+		dir := GinkgoT().TempDir()
+		configPath = filepath.Join(dir, "config")
+		Expect(os.WriteFile(configPath, nil, 0600)).To(Succeed())
+
+		rhmFlags := config.NewConfigFlags(genericclioptions.NewConfigFlags(true).WithDeprecatedPasswordFlag())
+		rhmFlags.DATACTLConfig = ptr.String(configPath)
+
+		cfg, err := rhmFlags.RawPersistentConfigLoader().RawConfig()
+		Expect(err).To(Succeed())
+
+		cfg.CurrentMeteringExport = &datactlapi.MeteringExport{FileName: filepath.Join(dir, "export.tar.gz")}
+		cfg.Sources = map[string]*datactlapi.Source{
+			"run.test": {
+				Name:       "run.test",
+				Type:       runSourceType,
+				Properties: map[string]string{"last-pull-date": time.Now().Format("2006-01-02")},
+			},
+		}
+		Expect(config.ModifyConfig(rhmFlags.ConfigAccess(), *cfg, true)).To(Succeed())
+
+		flags := pflag.NewFlagSet("run", pflag.ContinueOnError)
+		addSourcePullFlags(flags, sources.StartDateFlag, sources.EndDateFlag)
+
+		o = &runOptions{
+			rhmConfigFlags: rhmFlags,
+			interval:       time.Hour,
+			flags:          flags,
+			IOStreams:      genericclioptions.IOStreams{Out: GinkgoWriter, ErrOut: GinkgoWriter},
+		}
+		Expect(o.Complete(nil, nil)).To(Succeed())
+
+		runSourcePulls = 0
+	})
+
+	It("should skip the sources that pulled up to yesterday", func() {
+		// there is no upload api in the config, so the push is skipped
+		err := o.cycle()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("upload api is not reachable"))
+		Expect(err.Error()).ToNot(ContainSubstring("sources failed to pull"))
+
+		Expect(runSourcePulls).To(Equal(0))
+	})
+
+	It("should pull the sources with days left to pull", func() {
+		cfg, err := o.rhmConfigFlags.RawPersistentConfigLoader().RawConfig()
+		Expect(err).To(Succeed())
+
+		_, s, ok := cfg.SourceByName("run.test")
+		Expect(ok).To(BeTrue())
+		s.Properties["last-pull-date"] = time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+		Expect(config.ModifyConfig(o.rhmConfigFlags.ConfigAccess(), *cfg, true)).To(Succeed())
+
+		err = o.cycle()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).ToNot(ContainSubstring("sources failed to pull"))
+
+		Expect(runSourcePulls).To(Equal(1))
+	})
+})
@@ -13,3 +13,424 @@
 // limitations under the License.
 
 package mustgather
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	goflags "flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/go-logr/logr"
+	"github.com/redhat-marketplace/datactl/pkg/bundle"
+	"github.com/redhat-marketplace/datactl/pkg/clients/shared"
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers/output"
+	"github.com/redhat-marketplace/datactl/pkg/sources"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/klog/v2"
+	"k8s.io/klog/v2/klogr"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var (
+	logger logr.Logger = klogr.New().V(5).WithName("mustgather")
+
+	mustGatherLong = templates.LongDesc(i18n.T(`
+		Collects diagnostic information for support into a single tar.gz file.
+
+		The archive contains the datactl config with all tokens and secrets redacted,
+		a listing of every bundle in the data directory, the result of a connectivity
+		check for each source and the upload API, the datactl version and the last
+		log lines.`))
+
+	mustGatherExample = templates.Examples(i18n.T(`
+		# Write the diagnostics archive to the current directory
+		{{ .cmd }} mustgather
+
+		# Write the diagnostics archive to a specific file
+		{{ .cmd }} mustgather --file=/tmp/datactl-mustgather.tar.gz
+
+		# Include the last 1000 lines of a previous run logged with --log-file
+		{{ .cmd }} mustgather --include-log=/tmp/datactl.log --log-lines=1000
+`))
+)
+
+const (
+	redacted = shared.Redacted
+
+	defaultLogLines = 500
+)
+
+func NewCmdMustGather(rhmFlags *config.ConfigFlags, f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
+	o := mustGatherOptions{
+		rhmConfigFlags: rhmFlags,
+		IOStreams:      streams,
+		logLines:       defaultLogLines,
+	}
+
+	cmd := &cobra.Command{
+		Use:                   "mustgather [(--file FILE)]",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Collects diagnostic information for support."),
+		Long:                  output.ReplaceCommandStrings(mustGatherLong),
+		Example:               output.ReplaceCommandStrings(mustGatherExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVar(&o.file, "file", "", i18n.T("file to write the archive to, defaults to datactl-mustgather-TIMESTAMP.tar.gz"))
+	cmd.Flags().IntVar(&o.logLines, "log-lines", o.logLines, i18n.T("number of log lines to include"))
+	cmd.Flags().StringVar(&o.includeLog, "include-log", "", i18n.T("log file of a previous run to include the last lines of"))
+
+	return cmd
+}
+
+type mustGatherOptions struct {
+	rhmConfigFlags *config.ConfigFlags
+
+	// flags
+	file       string
+	logLines   int
+	includeLog string
+
+	//internal
+	args         []string
+	version      string
+	rhmRawConfig *datactlapi.Config
+	logs         *logTail
+
+	genericclioptions.IOStreams
+}
+
+func (o *mustGatherOptions) Complete(cmd *cobra.Command, args []string) error {
+	o.args = args
+
+	if cmd != nil {
+		o.version = cmd.Root().Version
+	}
+
+	o.logs = captureLogs(o.logLines, o.ErrOut)
+
+	var err error
+	o.rhmRawConfig, err = o.rhmConfigFlags.RawPersistentConfigLoader().RawConfig()
+	if err != nil {
+		return err
+	}
+
+	if o.file == "" {
+		o.file = fmt.Sprintf("datactl-mustgather-%s.tar.gz", time.Now().UTC().Format("20060102T150405Z"))
+	}
+
+	return nil
+}
+
+func (o *mustGatherOptions) Validate() error {
+	if o.logLines < 0 {
+		return fmt.Errorf("log-lines must not be negative")
+	}
+
+	if o.includeLog != "" {
+		if _, err := os.Stat(o.includeLog); os.IsNotExist(err) {
+			return fmt.Errorf("file does not exist %s", o.includeLog)
+		}
+	}
+
+	return nil
+}
+
+func (o *mustGatherOptions) Run() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	p := output.NewHumanOutput()
+	p.WithDetails("file", o.file).Titlef(i18n.T("mustgather started"))
+	p = p.Sub()
+
+	registerSecrets(o.rhmRawConfig)
+
+	f, err := os.OpenFile(o.file, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gzw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gzw)
+
+	add := func(name string, data []byte) error {
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    0600,
+			Size:    int64(len(data)),
+			ModTime: time.Now(),
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return errors.Wrap(err, "failed to write header")
+		}
+
+		_, err := tw.Write(data)
+		return err
+	}
+
+	if err := add("version.txt", []byte(o.version+"\n")); err != nil {
+		return err
+	}
+	p.WithDetails("version", o.version).Infof(i18n.T("version collected"))
+
+	configData, err := config.Write(*RedactConfig(o.rhmRawConfig))
+	if err != nil {
+		return errors.Wrap(err, "failed to serialize config")
+	}
+
+	if err := add("config.yaml", configData); err != nil {
+		return err
+	}
+	p.Infof(i18n.T("redacted config collected"))
+
+	bundles, count := o.listBundles()
+	if err := add("bundles.txt", bundles); err != nil {
+		return err
+	}
+	p.WithDetails("dataDir", config.RecommendedDataDir, "bundles", count).Infof(i18n.T("bundle listing collected"))
+
+	results := o.checkConnectivity(ctx)
+	resultData, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := add("connectivity.json", resultData); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, result := range results {
+		if !result.Passed() {
+			failed = failed + 1
+		}
+	}
+	p.WithDetails("checks", len(results), "failed", failed).Infof(i18n.T("connectivity checks collected"))
+
+	if o.includeLog != "" {
+		data, err := tailFile(o.includeLog, o.logLines)
+		if err != nil {
+			return err
+		}
+
+		if err := add(filepath.Base(o.includeLog), []byte(shared.Redact(string(data)))); err != nil {
+			return err
+		}
+	}
+
+	klog.Flush()
+	if err := add("datactl.log", []byte(shared.Redact(string(o.logs.Bytes())))); err != nil {
+		return err
+	}
+	p.WithDetails("lines", len(o.logs.lines)).Infof(i18n.T("logs collected"))
+
+	if err := errors.Combine(tw.Close(), gzw.Close()); err != nil {
+		return err
+	}
+
+	p.WithDetails("file", o.file).Infof(i18n.T("mustgather finished"))
+	return nil
+}
+
+// RedactConfig returns a copy of the config with every token and secret
+// replaced, including the properties of sources that their type's schema
+// marks as secret.
+func RedactConfig(in *datactlapi.Config) *datactlapi.Config {
+	out := in.DeepCopy()
+
+	if out.MarketplaceEndpoint.PullSecretData != "" {
+		out.MarketplaceEndpoint.PullSecretData = redacted
+	}
+
+	for _, endpoint := range out.DataServiceEndpoints {
+		if endpoint.TokenData != "" {
+			endpoint.TokenData = redacted
+		}
+	}
+
+	for _, endpoint := range out.ILMTEndpoints {
+		if endpoint.Token != "" {
+			endpoint.Token = redacted
+		}
+	}
+
+	for _, s := range out.Sources {
+		for _, name := range sources.SecretProperties(s) {
+			if s.Properties[name] != "" {
+				s.Properties[name] = redacted
+			}
+		}
+	}
+
+	return out
+}
+
+// registerSecrets makes the tokens of the config known to the redaction of
+// the logs collected.
+func registerSecrets(cfg *datactlapi.Config) {
+	shared.RegisterSecret(cfg.MarketplaceEndpoint.PullSecretData)
+
+	for _, endpoint := range cfg.DataServiceEndpoints {
+		shared.RegisterSecret(endpoint.TokenData)
+	}
+
+	for _, endpoint := range cfg.ILMTEndpoints {
+		shared.RegisterSecret(endpoint.Token)
+	}
+
+	for _, s := range cfg.Sources {
+		for _, name := range sources.SecretProperties(s) {
+			shared.RegisterSecret(s.Properties[name])
+		}
+	}
+}
+
+// listBundles writes each tar file found in the data dir with the entries it
+// contains. Errors reading a tar are recorded instead of failing the gather.
+func (o *mustGatherOptions) listBundles() ([]byte, int) {
+	buf := &bytes.Buffer{}
+
+	matches, err := filepath.Glob(filepath.Join(config.RecommendedDataDir, "*.tar"))
+	if err != nil {
+		fmt.Fprintf(buf, "error: %s\n", err)
+		return buf.Bytes(), 0
+	}
+
+	sort.Strings(matches)
+
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil {
+			fmt.Fprintf(buf, "%s\n  error: %s\n", match, err)
+			continue
+		}
+
+		fmt.Fprintf(buf, "%s size=%d modified=%s\n", match, info.Size(), info.ModTime().Format(time.RFC3339))
+
+		err = bundle.WalkTar(match, func(header *tar.Header, r io.Reader) error {
+			fmt.Fprintf(buf, "  %s size=%d modified=%s\n", header.Name, header.Size, header.ModTime.Format(time.RFC3339))
+			return nil
+		})
+
+		if err != nil {
+			fmt.Fprintf(buf, "  error: %s\n", err)
+		}
+	}
+
+	return buf.Bytes(), len(matches)
+}
+
+// checkConnectivity checks each source and the upload API with the clients of
+// the config flags.
+func (o *mustGatherOptions) checkConnectivity(ctx context.Context) []sources.CheckResult {
+	results := []sources.CheckResult{}
+
+	names := make([]string, 0, len(o.rhmRawConfig.Sources))
+	for name := range o.rhmRawConfig.Sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s := o.rhmRawConfig.Sources[name]
+
+		result := sources.CheckSource(ctx, o.rhmConfigFlags, o.rhmRawConfig, *s)
+		logger.Info("source checked", "source", s.String(), "passed", result.Passed())
+		results = append(results, result)
+	}
+
+	result := sources.CheckUploadAPI(ctx, o.rhmConfigFlags, o.rhmRawConfig)
+	logger.Info("upload api checked", "host", o.rhmRawConfig.MarketplaceEndpoint.Host, "passed", result.Passed())
+	results = append(results, result)
+
+	return results
+}
+
+func tailFile(name string, lines int) ([]byte, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	tail := newLogTail(lines)
+	tail.Write(data)
+	return tail.Bytes(), nil
+}
+
+// captureLogs redirects klog into a tail of the last lines. If the user did not
+// raise the verbosity, it is raised for this run and the output is only kept in
+// the tail.
+func captureLogs(lines int, errOut io.Writer) *logTail {
+	tail := newLogTail(lines)
+
+	fs := goflags.NewFlagSet("mustgather", goflags.ContinueOnError)
+	klog.InitFlags(fs)
+
+	var w io.Writer = tail
+
+	if !klog.V(5).Enabled() {
+		fs.Set("v", "5")
+	} else {
+		w = io.MultiWriter(tail, errOut)
+	}
+
+	fs.Set("logtostderr", "false")
+	fs.Set("alsologtostderr", "false")
+	fs.Set("one_output", "true")
+	klog.SetOutput(w)
+
+	return tail
+}
+
+type logTail struct {
+	max   int
+	lines []string
+}
+
+var _ io.Writer = &logTail{}
+
+func newLogTail(max int) *logTail {
+	return &logTail{max: max}
+}
+
+func (l *logTail) Write(p []byte) (int, error) {
+	for _, line := range strings.SplitAfter(string(p), "\n") {
+		if line == "" {
+			continue
+		}
+
+		l.lines = append(l.lines, line)
+	}
+
+	if len(l.lines) > l.max {
+		l.lines = l.lines[len(l.lines)-l.max:]
+	}
+
+	return len(p), nil
+}
+
+func (l *logTail) Bytes() []byte {
+	return []byte(strings.Join(l.lines, ""))
+}
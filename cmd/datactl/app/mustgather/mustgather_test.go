@@ -0,0 +1,94 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mustgather
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers"
+	"github.com/redhat-marketplace/datactl/pkg/sources"
+)
+
+const secretSourceType datactlapi.SourceType = "MustgatherSecret"
+
+func init() {
+	sources.MustRegister(sources.Registration{
+		Type: secretSourceType,
+		Schema: []sources.Property{
+			{Name: "url"},
+			{Name: "token", Secret: true},
+		},
+		New: func(rhmConfigFlags *config.ConfigFlags, printer printers.Printer, source datactlapi.Source) (sources.Source, error) {
+			return nil, nil
+		},
+	})
+}
+
+var _ = Describe("mustgather", func() {
+	It("should redact tokens without changing the config", func() {
+		cfg := &datactlapi.Config{
+			MarketplaceEndpoint: datactlapi.UploadAPI{
+				Host:           "marketplace.redhat.com",
+				PullSecretData: "secret",
+			},
+			DataServiceEndpoints: map[string]*datactlapi.DataServiceEndpoint{
+				"cluster": {TokenData: "token"},
+			},
+			ILMTEndpoints: map[string]*datactlapi.ILMTEndpoint{
+				"ilmt": {Token: "token"},
+			},
+		}
+
+		out := RedactConfig(cfg)
+		Expect(out.MarketplaceEndpoint.PullSecretData).To(Equal(redacted))
+		Expect(out.MarketplaceEndpoint.Host).To(Equal("marketplace.redhat.com"))
+		Expect(out.DataServiceEndpoints["cluster"].TokenData).To(Equal(redacted))
+		Expect(out.ILMTEndpoints["ilmt"].Token).To(Equal(redacted))
+
+		Expect(cfg.MarketplaceEndpoint.PullSecretData).To(Equal("secret"))
+		Expect(cfg.DataServiceEndpoints["cluster"].TokenData).To(Equal("token"))
+		Expect(cfg.ILMTEndpoints["ilmt"].Token).To(Equal("token"))
+	})
+
+	It("should redact the secret properties of sources", func() {
+		cfg := &datactlapi.Config{
+			Sources: map[string]*datactlapi.Source{
+				"prom": {
+					Name: "prom",
+					Type: secretSourceType,
+					Properties: map[string]string{
+						"url":   "https://prometheus",
+						"token": "token",
+					},
+				},
+			},
+		}
+
+		out := RedactConfig(cfg)
+		Expect(out.Sources["prom"].Properties).To(HaveKeyWithValue("token", redacted))
+		Expect(out.Sources["prom"].Properties).To(HaveKeyWithValue("url", "https://prometheus"))
+		Expect(cfg.Sources["prom"].Properties).To(HaveKeyWithValue("token", "token"))
+	})
+
+	It("should keep the last lines", func() {
+		tail := newLogTail(2)
+		tail.Write([]byte("one\ntwo\n"))
+		tail.Write([]byte("three\n"))
+		Expect(string(tail.Bytes())).To(Equal("two\nthree\n"))
+	})
+})
@@ -5,6 +5,7 @@ import (
 
 	"github.com/go-logr/logr"
 	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/sources"
 	"github.com/spf13/cobra"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/klog/v2/klogr"
@@ -26,8 +27,12 @@ func NewCmdAdd(rhmFlags *config.ConfigFlags, f cmdutil.Factory, streams genericc
 		},
 	}
 
-	cmd.AddCommand(NewCmdAddDataService(rhmFlags, f, streams))
-	cmd.AddCommand(NewCmdAddIlmt(rhmFlags, f, streams))
+	for _, r := range sources.Registrations() {
+		if r.AddCommand != nil {
+			cmd.AddCommand(r.AddCommand(rhmFlags, f, streams))
+		}
+	}
+
 	return cmd
 }
 
@@ -0,0 +1,154 @@
+package add
+
+import (
+	"os"
+	"path/filepath"
+
+	"emperror.dev/errors"
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers/output"
+	"github.com/redhat-marketplace/datactl/pkg/sources"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var (
+	addFilesystemLong = templates.LongDesc(i18n.T(`
+		Adds a source of the filesystem type to the config. Products that can't run the
+		Dataservice or ILMT write their usage report archives to a directory, and the
+		files of the directory matching the pattern are pulled into the export.
+
+		Once pushed, committing the export moves the reports to the archive path, or
+		deletes them if no archive path is set.`))
+
+	addFilesystemExample = templates.Examples(i18n.T(`
+		# Add a directory of usage report archives.
+		{{ .cmd }} sources add filesystem --path /var/lib/product/reports
+
+		# Add a directory, pulling only the files matching a pattern and moving them to an archive once committed.
+		{{ .cmd }} sources add filesystem --path /var/lib/product/reports --pattern "usage-*.tar.gz" --archive-path /var/lib/product/archive
+`))
+)
+
+func NewCmdAddFilesystem(rhmFlags *config.ConfigFlags, f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
+	o := addFilesystemOptions{
+		rhmConfigFlags: rhmFlags,
+		IOStreams:      streams,
+	}
+
+	cmd := &cobra.Command{
+		Use:                   "filesystem --path PATH [(--pattern PATTERN) (--archive-path ARCHIVE_PATH) (--name NAME)]",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Adds a directory of usage report archives as a source"),
+		Long:                  output.ReplaceCommandStrings(addFilesystemLong),
+		Example:               output.ReplaceCommandStrings(addFilesystemExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVar(&o.path, "path", EMPTY, i18n.T("directory the usage reports are written to"))
+	cmd.Flags().StringVar(&o.pattern, "pattern", sources.DefaultFilesystemPattern, i18n.T("glob the names of the usage reports match"))
+	cmd.Flags().StringVar(&o.archivePath, "archive-path", EMPTY, i18n.T("directory committed reports are moved to, instead of being deleted"))
+	cmd.Flags().StringVar(&o.name, "name", EMPTY, i18n.T("name of the source, defaults to the path"))
+
+	return cmd
+}
+
+type addFilesystemOptions struct {
+	rhmConfigFlags  *config.ConfigFlags
+	rhmConfigAccess config.ConfigAccess
+
+	rhmRawConfig *datactlapi.Config
+
+	path, pattern, archivePath, name string
+
+	genericclioptions.IOStreams
+}
+
+func (o *addFilesystemOptions) Complete(cmd *cobra.Command, args []string) error {
+	var err error
+
+	o.rhmRawConfig, err = o.rhmConfigFlags.RawPersistentConfigLoader().RawConfig()
+	if err != nil {
+		return errors.Wrap(err, "error getting rhm config")
+	}
+
+	o.rhmConfigAccess = o.rhmConfigFlags.ConfigAccess()
+
+	if o.path != EMPTY {
+		if o.path, err = filepath.Abs(o.path); err != nil {
+			return err
+		}
+	}
+
+	if o.archivePath != EMPTY {
+		if o.archivePath, err = filepath.Abs(o.archivePath); err != nil {
+			return err
+		}
+	}
+
+	if o.name == EMPTY {
+		o.name = o.path
+	}
+
+	return nil
+}
+
+func (o *addFilesystemOptions) Validate() error {
+	if o.path == EMPTY {
+		return errors.New("path is required")
+	}
+
+	info, err := os.Stat(o.path)
+	if err != nil {
+		return errors.WithDetails(err, "path", o.path)
+	}
+
+	if !info.IsDir() {
+		return errors.NewWithDetails("path is not a directory", "path", o.path)
+	}
+
+	if o.archivePath == o.path {
+		return errors.New("archive path must not be the path of the source")
+	}
+
+	if s, ok := o.rhmRawConfig.Sources[o.name]; ok && s.Type != sources.Filesystem {
+		return errors.NewWithDetails("a source with the name already exists", "name", o.name, "sourceType", s.Type)
+	}
+
+	return sources.ValidateFilesystemPattern(o.pattern)
+}
+
+func (o *addFilesystemOptions) Run() error {
+	properties := map[string]string{
+		sources.PathProperty:    o.path,
+		sources.PatternProperty: o.pattern,
+	}
+
+	if o.archivePath != EMPTY {
+		properties[sources.ArchivePathProperty] = o.archivePath
+	}
+
+	if o.rhmRawConfig.Sources == nil {
+		o.rhmRawConfig.Sources = make(map[string]*datactlapi.Source)
+	}
+
+	o.rhmRawConfig.Sources[o.name] = &datactlapi.Source{
+		Name:       o.name,
+		Type:       sources.Filesystem,
+		Properties: properties,
+	}
+
+	if err := config.ModifyConfig(o.rhmConfigAccess, *o.rhmRawConfig, true); err != nil {
+		return errors.Wrap(err, "error modifying config")
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package add
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/sources"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+var _ = Describe("NewCmdAddFilesystem", func() {
+	var (
+		dir        string
+		configPath string
+		o          *addFilesystemOptions
+	)
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		configPath = filepath.Join(GinkgoT().TempDir(), "config")
+		Expect(os.WriteFile(configPath, nil, 0600)).To(Succeed())
+
+		rhmFlags := config.NewConfigFlags(genericclioptions.NewConfigFlags(true).WithDeprecatedPasswordFlag())
+		*rhmFlags.DATACTLConfig = configPath
+
+		o = &addFilesystemOptions{
+			rhmConfigFlags: rhmFlags,
+			path:           dir,
+			pattern:        sources.DefaultFilesystemPattern,
+		}
+	})
+
+	It("should save the source with its properties", func() {
+		Expect(o.Complete(nil, nil)).To(Succeed())
+		Expect(o.Validate()).To(Succeed())
+		Expect(o.Run()).To(Succeed())
+
+		data, err := os.ReadFile(configPath)
+		Expect(err).To(Succeed())
+		Expect(string(data)).To(ContainSubstring("source-type: Filesystem"))
+		Expect(string(data)).To(ContainSubstring("path: " + dir))
+		Expect(string(data)).To(ContainSubstring("pattern: '*.tar.gz'"))
+	})
+
+	It("should fail for a path that is not a directory", func() {
+		o.path = filepath.Join(dir, "missing")
+
+		Expect(o.Complete(nil, nil)).To(Succeed())
+		Expect(o.Validate()).ToNot(Succeed())
+	})
+})
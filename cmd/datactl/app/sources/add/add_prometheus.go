@@ -0,0 +1,171 @@
+package add
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers/output"
+	"github.com/redhat-marketplace/datactl/pkg/sources"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var (
+	addPrometheusLong = templates.LongDesc(i18n.T(`
+		Adds a source of the prometheus type to the config. Pulling the source runs each
+		query over the query_range API of Prometheus or Thanos for every day of the date
+		range, and writes the results to a usage report.
+
+		Each query is named by the metric id reported for its results. An event is reported
+		per series of a query and day, with the highest value of the series that day and
+		its labels as attributes.`))
+
+	addPrometheusExample = templates.Examples(i18n.T(`
+		# Add a Thanos querier with a query for the virtual cores of a product.
+		{{ .cmd }} sources add prometheus --url https://thanos-querier.example.com --token TOKEN --query vcpu='sum by (productId) (product_vcpu)'
+
+		# Add a Prometheus server with two queries and a resolution of 5 minutes.
+		{{ .cmd }} sources add prometheus --url http://prometheus:9090 --step 5m --query vcpu='sum(product_vcpu)' --query users='max(product_users)'
+`))
+)
+
+func NewCmdAddPrometheus(rhmFlags *config.ConfigFlags, f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
+	o := addPrometheusOptions{
+		rhmConfigFlags: rhmFlags,
+		IOStreams:      streams,
+	}
+
+	cmd := &cobra.Command{
+		Use:                   "prometheus --url URL --query METRIC_ID=QUERY [(--token TOKEN) (--step STEP) (--name NAME)]",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Adds a Prometheus or Thanos query API as a source"),
+		Long:                  output.ReplaceCommandStrings(addPrometheusLong),
+		Example:               output.ReplaceCommandStrings(addPrometheusExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVar(&o.url, "url", EMPTY, i18n.T("url of the Prometheus or Thanos query API"))
+	cmd.Flags().StringVar(&o.token, "token", EMPTY, i18n.T("bearer token of the query API"))
+	cmd.Flags().StringVar(&o.step, "step", EMPTY, i18n.T("resolution of the queries, defaults to 1h"))
+	cmd.Flags().StringArrayVar(&o.queries, "query", []string{}, i18n.T("query as METRIC_ID=QUERY, can be repeated"))
+	cmd.Flags().StringVar(&o.name, "name", EMPTY, i18n.T("name of the source, defaults to the host of the url"))
+
+	return cmd
+}
+
+type addPrometheusOptions struct {
+	rhmConfigFlags  *config.ConfigFlags
+	rhmConfigAccess config.ConfigAccess
+
+	rhmRawConfig *datactlapi.Config
+
+	url, token, step, name string
+	queries                []string
+
+	genericclioptions.IOStreams
+}
+
+func (o *addPrometheusOptions) Complete(cmd *cobra.Command, args []string) error {
+	var err error
+
+	o.rhmRawConfig, err = o.rhmConfigFlags.RawPersistentConfigLoader().RawConfig()
+	if err != nil {
+		return errors.Wrap(err, "error getting rhm config")
+	}
+
+	o.rhmConfigAccess = o.rhmConfigFlags.ConfigAccess()
+
+	if o.name == EMPTY {
+		if u, err := url.Parse(o.url); err == nil {
+			o.name = u.Host
+		}
+	}
+
+	return nil
+}
+
+func (o *addPrometheusOptions) Validate() error {
+	u, err := url.Parse(o.url)
+	if err != nil || u.Host == EMPTY || (u.Scheme != "http" && u.Scheme != "https") {
+		return errors.NewWithDetails("url must be an http or https url", "url", o.url)
+	}
+
+	if len(o.queries) == 0 {
+		return errors.New("at least one query is required")
+	}
+
+	for _, q := range o.queries {
+		metricId, query, ok := splitQuery(q)
+		if !ok || metricId == EMPTY || query == EMPTY {
+			return errors.NewWithDetails("query must be METRIC_ID=QUERY", "query", q)
+		}
+	}
+
+	if o.step != EMPTY {
+		if d, err := time.ParseDuration(o.step); err != nil || d <= 0 {
+			return errors.NewWithDetails("step must be a positive duration", "step", o.step)
+		}
+	}
+
+	if s, ok := o.rhmRawConfig.Sources[o.name]; ok && s.Type != sources.Prometheus {
+		return errors.NewWithDetails("a source with the name already exists", "name", o.name, "sourceType", s.Type)
+	}
+
+	return nil
+}
+
+func (o *addPrometheusOptions) Run() error {
+	properties := map[string]string{
+		sources.URLProperty: o.url,
+	}
+
+	if o.token != EMPTY {
+		properties[sources.TokenProperty] = o.token
+	}
+
+	if o.step != EMPTY {
+		properties[sources.StepProperty] = o.step
+	}
+
+	for _, q := range o.queries {
+		metricId, query, _ := splitQuery(q)
+		properties[sources.QueryPropertyPrefix+metricId] = query
+	}
+
+	if o.rhmRawConfig.Sources == nil {
+		o.rhmRawConfig.Sources = make(map[string]*datactlapi.Source)
+	}
+
+	o.rhmRawConfig.Sources[o.name] = &datactlapi.Source{
+		Name:       o.name,
+		Type:       sources.Prometheus,
+		Properties: properties,
+	}
+
+	if err := config.ModifyConfig(o.rhmConfigAccess, *o.rhmRawConfig, true); err != nil {
+		return errors.Wrap(err, "error modifying config")
+	}
+
+	return nil
+}
+
+// splitQuery splits a query flag on its first '=', as queries have their own.
+func splitQuery(q string) (string, string, bool) {
+	i := strings.Index(q, "=")
+	if i < 0 {
+		return EMPTY, EMPTY, false
+	}
+
+	return strings.TrimSpace(q[:i]), strings.TrimSpace(q[i+1:]), true
+}
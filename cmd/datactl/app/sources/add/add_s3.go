@@ -0,0 +1,161 @@
+package add
+
+import (
+	"net/url"
+	"strings"
+
+	"emperror.dev/errors"
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers/output"
+	"github.com/redhat-marketplace/datactl/pkg/sources"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var (
+	addS3Long = templates.LongDesc(i18n.T(`
+		Adds a bucket of an S3 compatible object storage, like MinIO, as a source. The
+		objects under the prefix are pulled into the export.
+
+		Once pushed, committing the export deletes the objects, or tags them with
+		datactl-committed when the commit action is tag. Tagged objects are not pulled
+		again.
+
+		The bucket is also a destination bundles can be staged to with
+		"{{ .cmd }} export push --destination NAME". Staged bundles are written under
+		the staging prefix, which is never pulled.`))
+
+	addS3Example = templates.Examples(i18n.T(`
+		# Add the usage prefix of a MinIO bucket.
+		{{ .cmd }} sources add s3 --endpoint https://minio.example.com:9000 --bucket usage --prefix reports/ --access-key-id KEY_ID --secret-access-key SECRET
+
+		# Add a bucket, tagging the objects instead of deleting them once committed.
+		{{ .cmd }} sources add s3 --endpoint https://minio.example.com:9000 --bucket usage --access-key-id KEY_ID --secret-access-key SECRET --commit-action tag
+`))
+)
+
+func NewCmdAddS3(rhmFlags *config.ConfigFlags, f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
+	o := addS3Options{
+		rhmConfigFlags: rhmFlags,
+		IOStreams:      streams,
+	}
+
+	cmd := &cobra.Command{
+		Use:                   "s3 --endpoint URL --bucket BUCKET --access-key-id KEY_ID --secret-access-key SECRET [(--prefix PREFIX) (--region REGION) (--commit-action delete|tag) (--staging-prefix PREFIX) (--name NAME)]",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Adds a bucket of an S3 compatible object storage as a source"),
+		Long:                  output.ReplaceCommandStrings(addS3Long),
+		Example:               output.ReplaceCommandStrings(addS3Example),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVar(&o.endpoint, "endpoint", EMPTY, i18n.T("url of the S3 API"))
+	cmd.Flags().StringVar(&o.region, "region", EMPTY, i18n.T("region requests are signed for, defaults to us-east-1"))
+	cmd.Flags().StringVar(&o.bucket, "bucket", EMPTY, i18n.T("bucket of the usage reports"))
+	cmd.Flags().StringVar(&o.prefix, "prefix", EMPTY, i18n.T("prefix of the keys of the usage reports"))
+	cmd.Flags().StringVar(&o.accessKeyID, "access-key-id", EMPTY, i18n.T("access key id of the bucket"))
+	cmd.Flags().StringVar(&o.secretAccessKey, "secret-access-key", EMPTY, i18n.T("secret access key of the bucket"))
+	cmd.Flags().StringVar(&o.commitAction, "commit-action", sources.S3CommitDelete, i18n.T("delete or tag the committed objects"))
+	cmd.Flags().StringVar(&o.stagingPrefix, "staging-prefix", sources.DefaultS3StagingPrefix, i18n.T("prefix of the keys bundles are staged to"))
+	cmd.Flags().StringVar(&o.name, "name", EMPTY, i18n.T("name of the source, defaults to the bucket and prefix"))
+
+	return cmd
+}
+
+type addS3Options struct {
+	rhmConfigFlags  *config.ConfigFlags
+	rhmConfigAccess config.ConfigAccess
+
+	rhmRawConfig *datactlapi.Config
+
+	endpoint, region, bucket, prefix  string
+	accessKeyID, secretAccessKey      string
+	commitAction, stagingPrefix, name string
+
+	genericclioptions.IOStreams
+}
+
+func (o *addS3Options) Complete(cmd *cobra.Command, args []string) error {
+	var err error
+
+	o.rhmRawConfig, err = o.rhmConfigFlags.RawPersistentConfigLoader().RawConfig()
+	if err != nil {
+		return errors.Wrap(err, "error getting rhm config")
+	}
+
+	o.rhmConfigAccess = o.rhmConfigFlags.ConfigAccess()
+
+	if o.name == EMPTY {
+		o.name = strings.TrimSuffix(o.bucket+"/"+o.prefix, "/")
+	}
+
+	return nil
+}
+
+func (o *addS3Options) Validate() error {
+	u, err := url.Parse(o.endpoint)
+	if err != nil || u.Host == EMPTY || (u.Scheme != "http" && u.Scheme != "https") {
+		return errors.NewWithDetails("endpoint must be an http or https url", "endpoint", o.endpoint)
+	}
+
+	if o.bucket == EMPTY {
+		return errors.New("bucket is required")
+	}
+
+	if o.accessKeyID == EMPTY || o.secretAccessKey == EMPTY {
+		return errors.New("access key id and secret access key are required")
+	}
+
+	if o.stagingPrefix == EMPTY {
+		return errors.New("staging prefix must not be empty")
+	}
+
+	if s, ok := o.rhmRawConfig.Sources[o.name]; ok && s.Type != sources.S3 {
+		return errors.NewWithDetails("a source with the name already exists", "name", o.name, "sourceType", s.Type)
+	}
+
+	return sources.ValidateS3CommitAction(o.commitAction)
+}
+
+func (o *addS3Options) Run() error {
+	properties := map[string]string{
+		sources.EndpointProperty:        o.endpoint,
+		sources.BucketProperty:          o.bucket,
+		sources.AccessKeyIDProperty:     o.accessKeyID,
+		sources.SecretAccessKeyProperty: o.secretAccessKey,
+		sources.CommitActionProperty:    o.commitAction,
+		sources.StagingPrefixProperty:   o.stagingPrefix,
+	}
+
+	if o.region != EMPTY {
+		properties[sources.RegionProperty] = o.region
+	}
+
+	if o.prefix != EMPTY {
+		properties[sources.PrefixProperty] = o.prefix
+	}
+
+	if o.rhmRawConfig.Sources == nil {
+		o.rhmRawConfig.Sources = make(map[string]*datactlapi.Source)
+	}
+
+	o.rhmRawConfig.Sources[o.name] = &datactlapi.Source{
+		Name:       o.name,
+		Type:       sources.S3,
+		Properties: properties,
+	}
+
+	if err := config.ModifyConfig(o.rhmConfigAccess, *o.rhmRawConfig, true); err != nil {
+		return errors.Wrap(err, "error modifying config")
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package add
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/sources"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+var _ = Describe("NewCmdAddS3", func() {
+	var (
+		configPath string
+		o          *addS3Options
+	)
+
+	BeforeEach(func() {
+		configPath = filepath.Join(GinkgoT().TempDir(), "config")
+		Expect(os.WriteFile(configPath, nil, 0600)).To(Succeed())
+
+		rhmFlags := config.NewConfigFlags(genericclioptions.NewConfigFlags(true).WithDeprecatedPasswordFlag())
+		*rhmFlags.DATACTLConfig = configPath
+
+		o = &addS3Options{
+			rhmConfigFlags:  rhmFlags,
+			endpoint:        "https://minio.example.com:9000",
+			bucket:          "usage",
+			prefix:          "reports/",
+			accessKeyID:     "key",
+			secretAccessKey: "secret",
+			commitAction:    sources.S3CommitDelete,
+			stagingPrefix:   sources.DefaultS3StagingPrefix,
+		}
+	})
+
+	It("should save the source with its properties", func() {
+		Expect(o.Complete(nil, nil)).To(Succeed())
+		Expect(o.Validate()).To(Succeed())
+		Expect(o.Run()).To(Succeed())
+
+		data, err := os.ReadFile(configPath)
+		Expect(err).To(Succeed())
+		Expect(string(data)).To(ContainSubstring("source-type: S3"))
+		Expect(string(data)).To(ContainSubstring("name: usage/reports"))
+		Expect(string(data)).To(ContainSubstring("bucket: usage"))
+		Expect(string(data)).To(ContainSubstring("secret-access-key: secret"))
+	})
+
+	It("should fail for an unknown commit action", func() {
+		o.commitAction = "move"
+
+		Expect(o.Complete(nil, nil)).To(Succeed())
+		Expect(o.Validate()).ToNot(Succeed())
+	})
+})
@@ -0,0 +1,51 @@
+package add
+
+import (
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	"github.com/redhat-marketplace/datactl/pkg/sources"
+)
+
+// The built in source types. Other source types register themselves the same
+// way from their own package, which is then imported by the datactl command.
+func init() {
+	sources.MustRegister(sources.Registration{
+		Type:        datactlapi.DataService,
+		New:         sources.NewDataServiceFromConfig,
+		AddCommand:  NewCmdAddDataService,
+		PullFlags:   sources.DataServicePullFlags,
+		PullOptions: sources.DataServicePullOptions,
+	})
+
+	sources.MustRegister(sources.Registration{
+		Type:        datactlapi.ILMT,
+		New:         sources.NewIlmtSourceFromConfig,
+		AddCommand:  NewCmdAddIlmt,
+		PullFlags:   sources.IlmtPullFlags,
+		PullOptions: sources.IlmtPullOptions,
+		Pulled:      sources.IlmtPulled,
+	})
+
+	sources.MustRegister(sources.Registration{
+		Type:       sources.Filesystem,
+		Schema:     sources.FilesystemSchema,
+		New:        sources.NewFilesystemSourceFromConfig,
+		AddCommand: NewCmdAddFilesystem,
+	})
+
+	sources.MustRegister(sources.Registration{
+		Type:        sources.Prometheus,
+		Schema:      sources.PrometheusSchema,
+		New:         sources.NewPrometheusSourceFromConfig,
+		AddCommand:  NewCmdAddPrometheus,
+		PullFlags:   sources.DateRangePullFlags,
+		PullOptions: sources.PrometheusPullOptions,
+		Pulled:      sources.PrometheusPulled,
+	})
+
+	sources.MustRegister(sources.Registration{
+		Type:       sources.S3,
+		Schema:     sources.S3Schema,
+		New:        sources.NewS3SourceFromConfig,
+		AddCommand: NewCmdAddS3,
+	})
+}
@@ -2,11 +2,16 @@ package sources
 
 import (
 	"fmt"
+	"net"
 	"path"
+	"sort"
 
 	"github.com/go-logr/logr"
 	"github.com/redhat-marketplace/datactl/cmd/datactl/app/sources/add"
+	"github.com/redhat-marketplace/datactl/pkg/clients/shared"
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
 	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/sources"
 	"github.com/spf13/cobra"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/klog/v2/klogr"
@@ -31,6 +36,11 @@ func NewCmdSources(rhmFlags *config.ConfigFlags, f cmdutil.Factory, streams gene
 	}
 
 	cmd.AddCommand(add.NewCmdAdd(rhmFlags, f, streams))
+	cmd.AddCommand(NewCmdSourcesList(rhmFlags, f, streams))
+	cmd.AddCommand(NewCmdSourcesDescribe(rhmFlags, f, streams))
+	cmd.AddCommand(NewCmdSourcesRemove(rhmFlags, f, streams))
+	cmd.AddCommand(NewCmdSourcesRename(rhmFlags, f, streams))
+	cmd.AddCommand(NewCmdSourcesTest(rhmFlags, f, streams))
 	return cmd
 }
 
@@ -39,3 +49,83 @@ func helpErrorf(cmd *cobra.Command, format string, args ...interface{}) error {
 	msg := fmt.Sprintf(format, args...)
 	return fmt.Errorf("%s", msg)
 }
+
+// endpointProperties are the properties of the registered source types that
+// hold the location a source is pulled from, in order of preference.
+var endpointProperties = []string{
+	sources.URLProperty,
+	sources.EndpointProperty,
+	sources.PathProperty,
+}
+
+// sourceDetails describes a source with its endpoint from the config. The
+// secret properties of the source are redacted.
+func sourceDetails(cfg *datactlapi.Config, s *datactlapi.Source) *datactlapi.SourceDetails {
+	details := &datactlapi.SourceDetails{
+		Name: s.Name,
+		Type: s.Type,
+	}
+
+	if !s.LastAccessTime.IsZero() {
+		details.LastAccessTime = s.LastAccessTime.DeepCopy()
+	}
+
+	switch s.Type {
+	case datactlapi.DataService:
+		if endpoint, ok := cfg.DataServiceEndpoints[s.Name]; ok {
+			details.Endpoint = endpoint.Host
+
+			if !endpoint.TokenExpiration.IsZero() {
+				details.TokenExpiration = endpoint.TokenExpiration.DeepCopy()
+			}
+		}
+	case datactlapi.ILMT:
+		if endpoint, ok := cfg.ILMTEndpoints[s.Name]; ok {
+			details.Endpoint = endpoint.Host
+			if endpoint.Port != "" {
+				details.Endpoint = net.JoinHostPort(endpoint.Host, endpoint.Port)
+			}
+
+			details.LastPullDate = endpoint.LastPulldate
+		}
+	default:
+		for _, name := range endpointProperties {
+			if value := s.Properties[name]; value != "" {
+				details.Endpoint = value
+				break
+			}
+		}
+
+		details.LastPullDate = s.Properties[sources.LastPullDateProperty]
+	}
+
+	if len(s.Properties) != 0 {
+		details.Properties = make(map[string]string, len(s.Properties))
+		for name, value := range s.Properties {
+			details.Properties[name] = value
+		}
+
+		for _, name := range sources.SecretProperties(s) {
+			details.Properties[name] = shared.Redacted
+		}
+	}
+
+	return details
+}
+
+// sortedSources returns the sources of the config sorted by name.
+func sortedSources(cfg *datactlapi.Config) []*datactlapi.Source {
+	out := make([]*datactlapi.Source, 0, len(cfg.Sources))
+	for _, s := range cfg.Sources {
+		out = append(out, s)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name == out[j].Name {
+			return out[i].Type < out[j].Type
+		}
+		return out[i].Name < out[j].Name
+	})
+
+	return out
+}
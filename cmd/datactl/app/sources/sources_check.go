@@ -0,0 +1,175 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"emperror.dev/errors"
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers/output"
+	"github.com/redhat-marketplace/datactl/pkg/sources"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var (
+	testLong = templates.LongDesc(i18n.T(`
+		Checks that the sources can be pulled and that the upload API accepts the
+		pull secret, and prints the result of every step of the checks.
+
+		Data service sources check that the route host is reachable, that its TLS
+		certificate verifies, that a service account token is minted and that a file
+		can be listed. ILMT sources check that the host is reachable, that the token
+		is accepted and that the usage of a single day can be queried. The upload API
+		checks that the host is reachable and that the pull secret is accepted.
+
+		A check stops at its first failing step.`))
+
+	testExample = templates.Examples(i18n.T(`
+		# Check every source and the upload API
+		{{ .cmd }} sources test
+
+		# Check a single source
+		{{ .cmd }} sources test my-cluster
+`))
+)
+
+func NewCmdSourcesTest(rhmFlags *config.ConfigFlags, f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := sourcesTestOptions{
+		rhmConfigFlags: rhmFlags,
+		IOStreams:      ioStreams,
+	}
+
+	cmd := &cobra.Command{
+		Use:                   "test [NAME]",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Checks the connectivity and authorization of the sources."),
+		Long:                  output.ReplaceCommandStrings(testLong),
+		Example:               output.ReplaceCommandStrings(testExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().DurationVar(&o.timeout, "timeout", 2*time.Minute, i18n.T("maximum time to wait for the checks"))
+
+	return cmd
+}
+
+type sourcesTestOptions struct {
+	rhmConfigFlags *config.ConfigFlags
+
+	// flags
+	timeout time.Duration
+
+	//internal
+	args []string
+	name string
+
+	rhmRawConfig *datactlapi.Config
+
+	genericclioptions.IOStreams
+}
+
+func (o *sourcesTestOptions) Complete(cmd *cobra.Command, args []string) error {
+	o.args = args
+
+	if len(args) > 1 {
+		return helpErrorf(cmd, "only one source name is allowed")
+	}
+
+	if len(args) == 1 {
+		o.name = args[0]
+	}
+
+	var err error
+	o.rhmRawConfig, err = o.rhmConfigFlags.RawPersistentConfigLoader().RawConfig()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (o *sourcesTestOptions) Validate() error {
+	if o.name == "" {
+		return nil
+	}
+
+	if _, _, ok := o.rhmRawConfig.SourceByName(o.name); !ok {
+		return errors.NewWithDetails("source not found", "name", o.name)
+	}
+
+	return nil
+}
+
+func (o *sourcesTestOptions) Run() error {
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+
+	results := []sources.CheckResult{}
+
+	if o.name != "" {
+		_, s, _ := o.rhmRawConfig.SourceByName(o.name)
+		results = append(results, sources.CheckSource(ctx, o.rhmConfigFlags, o.rhmRawConfig, *s))
+	} else {
+		for _, s := range sortedSources(o.rhmRawConfig) {
+			results = append(results, sources.CheckSource(ctx, o.rhmConfigFlags, o.rhmRawConfig, *s))
+		}
+
+		results = append(results, sources.CheckUploadAPI(ctx, o.rhmConfigFlags, o.rhmRawConfig))
+	}
+
+	printCheckResults(o.Out, results)
+
+	failed := 0
+	for _, result := range results {
+		if !result.Passed() {
+			failed = failed + 1
+		}
+	}
+
+	if failed != 0 {
+		return errors.NewWithDetails("checks failed", "failed", failed, "checks", len(results))
+	}
+
+	return nil
+}
+
+// printCheckResults writes a row for every step of the checks, with the name
+// and type on the first row of each check.
+func printCheckResults(out io.Writer, results []sources.CheckResult) {
+	w := printers.GetNewTabWriter(out)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "NAME\tTYPE\tSTEP\tRESULT\tERROR")
+
+	for _, result := range results {
+		name, checkType := result.Name, result.Type
+
+		for _, step := range result.Steps {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, checkType, step.Name, stepResult(step), step.Error)
+			name, checkType = "", ""
+		}
+	}
+}
+
+func stepResult(step sources.CheckStep) string {
+	switch {
+	case step.Skipped:
+		return "skipped"
+	case step.Passed:
+		return "pass"
+	default:
+		return "fail"
+	}
+}
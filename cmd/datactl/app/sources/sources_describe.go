@@ -0,0 +1,153 @@
+package sources
+
+import (
+	"sort"
+	"time"
+
+	"emperror.dev/errors"
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers/output"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
+	"k8s.io/kubectl/pkg/cmd/get"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var (
+	describeLong = templates.LongDesc(i18n.T(`
+		Shows a source with its endpoint, the expiration of its token, the last
+		time it was accessed and the date its next pull starts from.
+
+		Secret properties are redacted.`))
+
+	describeExample = templates.Examples(i18n.T(`
+		# Describe a source
+		{{ .cmd }} sources describe my-cluster
+
+		# Describe a source as json
+		{{ .cmd }} sources describe my-cluster -o json
+`))
+)
+
+func NewCmdSourcesDescribe(rhmFlags *config.ConfigFlags, f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := sourcesDescribeOptions{
+		rhmConfigFlags: rhmFlags,
+		PrintFlags:     get.NewGetPrintFlags(),
+		IOStreams:      ioStreams,
+	}
+
+	cmd := &cobra.Command{
+		Use:                   "describe NAME",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Shows the details of a source."),
+		Long:                  output.ReplaceCommandStrings(describeLong),
+		Example:               output.ReplaceCommandStrings(describeExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	addPrintFlags(cmd, o.PrintFlags)
+
+	return cmd
+}
+
+type sourcesDescribeOptions struct {
+	rhmConfigFlags *config.ConfigFlags
+	PrintFlags     *get.PrintFlags
+
+	//internal
+	args        []string
+	name        string
+	humanOutput bool
+
+	rhmRawConfig *datactlapi.Config
+
+	print printers.ResourcePrinter
+
+	genericclioptions.IOStreams
+}
+
+func (o *sourcesDescribeOptions) Complete(cmd *cobra.Command, args []string) error {
+	o.args = args
+
+	if len(args) != 1 {
+		return helpErrorf(cmd, "source name is required")
+	}
+
+	o.name = args[0]
+
+	var err error
+	o.rhmRawConfig, err = o.rhmConfigFlags.RawPersistentConfigLoader().RawConfig()
+	if err != nil {
+		return err
+	}
+
+	o.humanOutput = o.PrintFlags.OutputFormat == nil || *o.PrintFlags.OutputFormat == "" || *o.PrintFlags.OutputFormat == "wide"
+
+	o.print, err = toSourcePrinter(o.PrintFlags)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (o *sourcesDescribeOptions) Validate() error {
+	if _, _, ok := o.rhmRawConfig.SourceByName(o.name); !ok {
+		return errors.NewWithDetails("source not found", "name", o.name)
+	}
+
+	return nil
+}
+
+func (o *sourcesDescribeOptions) Run() error {
+	_, s, _ := o.rhmRawConfig.SourceByName(o.name)
+	details := sourceDetails(o.rhmRawConfig, s)
+
+	if !o.humanOutput {
+		return o.print.PrintObj(details, o.Out)
+	}
+
+	p := output.NewHumanOutput()
+	p.WithDetails(
+		"type", details.Type,
+		"endpoint", details.Endpoint,
+		"tokenExpiration", formatTime(details.TokenExpiration),
+		"lastAccess", formatTime(details.LastAccessTime),
+		"lastPullDate", details.LastPullDate,
+	).Titlef("%s", details.Name)
+
+	if len(details.Properties) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(details.Properties))
+	for name := range details.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	p2 := p.Sub()
+	for _, name := range names {
+		p2.WithDetails("value", details.Properties[name]).Infof("%s", name)
+	}
+
+	return nil
+}
+
+// formatTime returns t in RFC 3339, or an empty string if it's not set.
+func formatTime(t *metav1.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
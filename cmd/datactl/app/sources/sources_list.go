@@ -0,0 +1,130 @@
+package sources
+
+import (
+	"github.com/gotidy/ptr"
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers/output"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
+	"k8s.io/kubectl/pkg/cmd/get"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var (
+	listLong = templates.LongDesc(i18n.T(`
+		Lists the sources in the config with their type, endpoint and the last
+		time they were accessed.`))
+
+	listExample = templates.Examples(i18n.T(`
+		# List the sources
+		{{ .cmd }} sources list
+
+		# List the sources as yaml
+		{{ .cmd }} sources list -o yaml
+`))
+)
+
+func NewCmdSourcesList(rhmFlags *config.ConfigFlags, f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := sourcesListOptions{
+		rhmConfigFlags: rhmFlags,
+		PrintFlags:     get.NewGetPrintFlags(),
+		IOStreams:      ioStreams,
+	}
+
+	cmd := &cobra.Command{
+		Use:                   "list",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Lists the sources."),
+		Long:                  output.ReplaceCommandStrings(listLong),
+		Example:               output.ReplaceCommandStrings(listExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	addPrintFlags(cmd, o.PrintFlags)
+
+	return cmd
+}
+
+type sourcesListOptions struct {
+	rhmConfigFlags *config.ConfigFlags
+	PrintFlags     *get.PrintFlags
+
+	//internal
+	args []string
+
+	rhmRawConfig *datactlapi.Config
+
+	print printers.ResourcePrinter
+
+	genericclioptions.IOStreams
+}
+
+func (o *sourcesListOptions) Complete(cmd *cobra.Command, args []string) error {
+	o.args = args
+
+	var err error
+	o.rhmRawConfig, err = o.rhmConfigFlags.RawPersistentConfigLoader().RawConfig()
+	if err != nil {
+		return err
+	}
+
+	o.print, err = toSourcePrinter(o.PrintFlags)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (o *sourcesListOptions) Validate() error {
+	return nil
+}
+
+func (o *sourcesListOptions) Run() error {
+	writer := printers.GetNewTabWriter(o.Out)
+	defer writer.Flush()
+
+	for _, s := range sortedSources(o.rhmRawConfig) {
+		if err := o.print.PrintObj(sourceDetails(o.rhmRawConfig, s), writer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addPrintFlags adds the output flags of the sources commands, hiding the
+// ones that only apply to kubernetes resources.
+func addPrintFlags(cmd *cobra.Command, printFlags *get.PrintFlags) {
+	printFlags.AddFlags(cmd)
+	cmd.Flags().MarkHidden("label-columns")
+	cmd.Flags().MarkHidden("sort-by")
+	cmd.Flags().MarkHidden("show-kind")
+	cmd.Flags().MarkHidden("show-managed-fields")
+	cmd.Flags().MarkHidden("show-labels")
+}
+
+// toSourcePrinter returns the printer of source details, a table unless
+// another output format is requested.
+func toSourcePrinter(printFlags *get.PrintFlags) (printers.ResourcePrinter, error) {
+	if printFlags.OutputFormat == nil || *printFlags.OutputFormat == "" {
+		printFlags.OutputFormat = ptr.String("wide")
+	} else if *printFlags.OutputFormat != "wide" {
+		output.DisableColor()
+	}
+
+	print, err := printFlags.ToPrinter()
+	if err != nil {
+		return nil, err
+	}
+
+	return output.NewSourceCLITableOrStruct(printFlags, print), nil
+}
@@ -0,0 +1,122 @@
+package sources
+
+import (
+	"emperror.dev/errors"
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers/output"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var (
+	removeLong = templates.LongDesc(i18n.T(`
+		Removes a source from the config, with the data service or ILMT endpoint
+		of the source.
+
+		Files already pulled from the source stay in their exports.`))
+
+	removeExample = templates.Examples(i18n.T(`
+		# Remove a source
+		{{ .cmd }} sources remove my-cluster
+`))
+)
+
+func NewCmdSourcesRemove(rhmFlags *config.ConfigFlags, f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := sourcesRemoveOptions{
+		rhmConfigFlags: rhmFlags,
+		IOStreams:      ioStreams,
+	}
+
+	cmd := &cobra.Command{
+		Use:                   "remove NAME",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Removes a source."),
+		Long:                  output.ReplaceCommandStrings(removeLong),
+		Example:               output.ReplaceCommandStrings(removeExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	return cmd
+}
+
+type sourcesRemoveOptions struct {
+	rhmConfigFlags *config.ConfigFlags
+
+	//internal
+	args []string
+	name string
+
+	rhmRawConfig *datactlapi.Config
+
+	genericclioptions.IOStreams
+}
+
+func (o *sourcesRemoveOptions) Complete(cmd *cobra.Command, args []string) error {
+	o.args = args
+
+	if len(args) != 1 {
+		return helpErrorf(cmd, "source name is required")
+	}
+
+	o.name = args[0]
+
+	var err error
+	o.rhmRawConfig, err = o.rhmConfigFlags.RawPersistentConfigLoader().RawConfig()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (o *sourcesRemoveOptions) Validate() error {
+	if _, _, ok := o.rhmRawConfig.SourceByName(o.name); !ok {
+		return errors.NewWithDetails("source not found", "name", o.name)
+	}
+
+	return nil
+}
+
+func (o *sourcesRemoveOptions) Run() error {
+	p := output.NewHumanOutput()
+
+	s, err := removeSource(o.rhmRawConfig, o.name)
+	if err != nil {
+		return err
+	}
+
+	if err := config.ModifyConfig(o.rhmConfigFlags.ConfigAccess(), *o.rhmRawConfig, true); err != nil {
+		return err
+	}
+
+	p.WithDetails("name", s.Name, "type", s.Type).Infof(i18n.T("source removed"))
+	return nil
+}
+
+// removeSource removes the source named name from the config, and the
+// endpoint of the source for the types that keep theirs in their own section.
+func removeSource(cfg *datactlapi.Config, name string) (*datactlapi.Source, error) {
+	key, s, ok := cfg.SourceByName(name)
+	if !ok {
+		return nil, errors.NewWithDetails("source not found", "name", name)
+	}
+
+	delete(cfg.Sources, key)
+
+	switch s.Type {
+	case datactlapi.DataService:
+		delete(cfg.DataServiceEndpoints, s.Name)
+	case datactlapi.ILMT:
+		delete(cfg.ILMTEndpoints, s.Name)
+	}
+
+	return s, nil
+}
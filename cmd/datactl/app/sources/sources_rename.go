@@ -0,0 +1,161 @@
+package sources
+
+import (
+	"emperror.dev/errors"
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers/output"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var (
+	renameLong = templates.LongDesc(i18n.T(`
+		Renames a source in the config.
+
+		The data service endpoint of the source, its pull checkpoints and the files
+		already pulled from it in the exports are moved to the new name. ILMT sources
+		are named after their host and can't be renamed.`))
+
+	renameExample = templates.Examples(i18n.T(`
+		# Rename a source
+		{{ .cmd }} sources rename my-cluster production
+`))
+)
+
+func NewCmdSourcesRename(rhmFlags *config.ConfigFlags, f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	o := sourcesRenameOptions{
+		rhmConfigFlags: rhmFlags,
+		IOStreams:      ioStreams,
+	}
+
+	cmd := &cobra.Command{
+		Use:                   "rename NAME NEW_NAME",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Renames a source."),
+		Long:                  output.ReplaceCommandStrings(renameLong),
+		Example:               output.ReplaceCommandStrings(renameExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	return cmd
+}
+
+type sourcesRenameOptions struct {
+	rhmConfigFlags *config.ConfigFlags
+
+	//internal
+	args    []string
+	name    string
+	newName string
+
+	rhmRawConfig *datactlapi.Config
+
+	genericclioptions.IOStreams
+}
+
+func (o *sourcesRenameOptions) Complete(cmd *cobra.Command, args []string) error {
+	o.args = args
+
+	if len(args) != 2 {
+		return helpErrorf(cmd, "source name and new name are required")
+	}
+
+	o.name, o.newName = args[0], args[1]
+
+	var err error
+	o.rhmRawConfig, err = o.rhmConfigFlags.RawPersistentConfigLoader().RawConfig()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (o *sourcesRenameOptions) Validate() error {
+	_, s, ok := o.rhmRawConfig.SourceByName(o.name)
+	if !ok {
+		return errors.NewWithDetails("source not found", "name", o.name)
+	}
+
+	if s.Type == datactlapi.ILMT {
+		return errors.NewWithDetails("ILMT sources are named after their host and can't be renamed", "name", o.name)
+	}
+
+	if o.newName == "" {
+		return errors.New("new name is required")
+	}
+
+	if _, _, ok := o.rhmRawConfig.SourceByName(o.newName); ok {
+		return errors.NewWithDetails("source already exists", "name", o.newName)
+	}
+
+	return nil
+}
+
+func (o *sourcesRenameOptions) Run() error {
+	p := output.NewHumanOutput()
+
+	if err := renameSource(o.rhmRawConfig, o.name, o.newName); err != nil {
+		return err
+	}
+
+	if err := config.ModifyConfig(o.rhmConfigFlags.ConfigAccess(), *o.rhmRawConfig, true); err != nil {
+		return err
+	}
+
+	p.WithDetails("name", o.name, "newName", o.newName).Infof(i18n.T("source renamed"))
+	return nil
+}
+
+// renameSource renames the source named name to newName, moving its data
+// service endpoint, pull checkpoints and pulled files with it.
+func renameSource(cfg *datactlapi.Config, name, newName string) error {
+	key, s, ok := cfg.SourceByName(name)
+	if !ok {
+		return errors.NewWithDetails("source not found", "name", name)
+	}
+
+	delete(cfg.Sources, key)
+	s.Name = newName
+	cfg.Sources[s.String()] = s
+
+	if s.Type == datactlapi.DataService {
+		if endpoint, ok := cfg.DataServiceEndpoints[name]; ok {
+			delete(cfg.DataServiceEndpoints, name)
+			endpoint.ClusterName = newName
+			cfg.DataServiceEndpoints[newName] = endpoint
+		}
+	}
+
+	exports := []*datactlapi.MeteringExport{cfg.CurrentMeteringExport}
+	for _, export := range cfg.MeteringExports {
+		exports = append(exports, export)
+	}
+
+	for _, export := range exports {
+		if export == nil {
+			continue
+		}
+
+		if checkpoint, ok := export.PullCheckpoints[name]; ok {
+			delete(export.PullCheckpoints, name)
+			export.PullCheckpoints[newName] = checkpoint
+		}
+
+		for _, file := range export.Files {
+			if file.FileInfo != nil && file.Source == name {
+				file.Source = newName
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package sources
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/redhat-marketplace/datactl/pkg/clients/shared"
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	dataservicev1 "github.com/redhat-marketplace/datactl/pkg/datactl/api/dataservice/v1"
+	"github.com/redhat-marketplace/datactl/pkg/sources"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("sources", func() {
+	var (
+		cfg        *datactlapi.Config
+		expiration metav1.Time
+	)
+
+	BeforeEach(func() {
+		expiration = metav1.NewTime(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+
+		cfg = &datactlapi.Config{
+			DataServiceEndpoints: map[string]*datactlapi.DataServiceEndpoint{
+				"my-cluster": {ClusterName: "my-cluster", Host: "dataservice.example.com", TokenExpiration: expiration},
+			},
+			ILMTEndpoints: map[string]*datactlapi.ILMTEndpoint{
+				"ilmt.example.com": {Host: "ilmt.example.com", Port: "443", LastPulldate: "2024-01-01"},
+			},
+			Sources: map[string]*datactlapi.Source{
+				"DataService:my-cluster": {Name: "my-cluster", Type: datactlapi.DataService},
+				"ILMT:ilmt.example.com":  {Name: "ilmt.example.com", Type: datactlapi.ILMT},
+				"Prometheus:thanos": {
+					Name: "thanos",
+					Type: sources.Prometheus,
+					Properties: map[string]string{
+						sources.URLProperty:          "https://thanos.example.com",
+						sources.TokenProperty:        "secret-token",
+						sources.LastPullDateProperty: "2024-01-03",
+					},
+				},
+			},
+			CurrentMeteringExport: &datactlapi.MeteringExport{
+				PullCheckpoints: map[string]*dataservicev1.PullCheckpoint{
+					"my-cluster": {},
+				},
+				Files: []*dataservicev1.FileInfoCTLAction{
+					{FileInfo: &dataservicev1.FileInfo{Id: "a", Source: "my-cluster"}},
+					{FileInfo: &dataservicev1.FileInfo{Id: "b", Source: "thanos"}},
+				},
+			},
+		}
+	})
+
+	It("should describe the endpoint of each source type", func() {
+		details := sourceDetails(cfg, cfg.Sources["DataService:my-cluster"])
+		Expect(details.Endpoint).To(Equal("dataservice.example.com"))
+		Expect(details.TokenExpiration).To(Equal(&expiration))
+
+		details = sourceDetails(cfg, cfg.Sources["ILMT:ilmt.example.com"])
+		Expect(details.Endpoint).To(Equal("ilmt.example.com:443"))
+		Expect(details.LastPullDate).To(Equal("2024-01-01"))
+
+		details = sourceDetails(cfg, cfg.Sources["Prometheus:thanos"])
+		Expect(details.Endpoint).To(Equal("https://thanos.example.com"))
+		Expect(details.LastPullDate).To(Equal("2024-01-03"))
+		Expect(details.Properties).To(HaveKeyWithValue(sources.TokenProperty, shared.Redacted))
+		Expect(cfg.Sources["Prometheus:thanos"].Properties).To(HaveKeyWithValue(sources.TokenProperty, "secret-token"))
+	})
+
+	It("should list the sources by name", func() {
+		names := []string{}
+		for _, s := range sortedSources(cfg) {
+			names = append(names, s.Name)
+		}
+		Expect(names).To(Equal([]string{"ilmt.example.com", "my-cluster", "thanos"}))
+	})
+
+	It("should remove a source with its endpoint", func() {
+		s, err := removeSource(cfg, "my-cluster")
+		Expect(err).To(Succeed())
+		Expect(s.Type).To(Equal(datactlapi.DataService))
+		Expect(cfg.Sources).ToNot(HaveKey("DataService:my-cluster"))
+		Expect(cfg.DataServiceEndpoints).To(BeEmpty())
+
+		_, err = removeSource(cfg, "ilmt.example.com")
+		Expect(err).To(Succeed())
+		Expect(cfg.ILMTEndpoints).To(BeEmpty())
+
+		_, err = removeSource(cfg, "missing")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should rename a source with its endpoint, checkpoints and files", func() {
+		Expect(renameSource(cfg, "my-cluster", "production")).To(Succeed())
+
+		Expect(cfg.Sources).ToNot(HaveKey("DataService:my-cluster"))
+		Expect(cfg.Sources).To(HaveKey("DataService:production"))
+		Expect(cfg.Sources["DataService:production"].Name).To(Equal("production"))
+
+		Expect(cfg.DataServiceEndpoints).ToNot(HaveKey("my-cluster"))
+		Expect(cfg.DataServiceEndpoints).To(HaveKey("production"))
+		Expect(cfg.DataServiceEndpoints["production"].ClusterName).To(Equal("production"))
+
+		export := cfg.CurrentMeteringExport
+		Expect(export.PullCheckpoints).To(HaveKey("production"))
+		Expect(export.PullCheckpoints).ToNot(HaveKey("my-cluster"))
+		Expect(export.Files[0].Source).To(Equal("production"))
+		Expect(export.Files[1].Source).To(Equal("thanos"))
+	})
+	It("should print the failing step of each check", func() {
+		out := &bytes.Buffer{}
+		printCheckResults(out, []sources.CheckResult{
+			{Name: "my-cluster", Type: "DataService", Steps: []sources.CheckStep{
+				{Name: sources.StepReachable, Passed: true},
+				{Name: sources.StepTLS, Error: "x509: unknown authority"},
+				{Name: sources.StepToken, Skipped: true},
+			}},
+		})
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		Expect(lines).To(HaveLen(4))
+		Expect(strings.Fields(lines[1])).To(Equal([]string{"my-cluster", "DataService", "host", "reachable", "pass"}))
+		Expect(lines[2]).To(ContainSubstring("tls verified"))
+		Expect(lines[2]).To(ContainSubstring("fail"))
+		Expect(lines[2]).To(ContainSubstring("x509: unknown authority"))
+		Expect(lines[3]).To(ContainSubstring("skipped"))
+	})
+})
@@ -23,18 +23,70 @@ import (
 	"time"
 
 	"emperror.dev/errors"
+	"github.com/go-logr/logr"
 	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
 	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"k8s.io/klog/v2/klogr"
 )
 
 type BundleFile struct {
 	file      *os.File
 	tar       *tar.Writer
 	tarReader *tar.Reader
+
+	pending  *pendingEntry
+	recovery *Recovery
+}
+
+// Recovery reports the data removed from a bundle that was not closed
+// properly, for example when a pull was killed while writing a file.
+type Recovery struct {
+	// Lost are the names of the entries that were only partially written.
+	Lost []string
+
+	// Truncated is the number of bytes removed from the end of the bundle.
+	Truncated int64
+}
+
+// pendingEntry is a file being written to the bundle. The data is written to
+// a temporary file first and only appended to the tar once it is complete, so
+// a crash never leaves a partial entry behind.
+type pendingEntry struct {
+	header *tar.Header
+	spool  *os.File
+
+	// written is the size of the data written to the spool
+	written int64
+}
+
+// entryWriter writes the data of a pending entry to its spool. A write over
+// the size of the entry fails and drops the entry, so the data of an entry
+// never goes past its header.
+type entryWriter struct {
+	bundle *BundleFile
+	entry  *pendingEntry
+}
+
+func (w *entryWriter) Write(p []byte) (int, error) {
+	if w.bundle.pending != w.entry {
+		return 0, errors.NewWithDetails("file is already written to the bundle", "file", w.entry.header.Name)
+	}
+
+	if w.entry.written+int64(len(p)) > w.entry.header.Size {
+		w.bundle.discard()
+		return 0, errors.WithDetails(tar.ErrWriteTooLong,
+			"file", w.entry.header.Name, "size", w.entry.header.Size, "written", w.entry.written+int64(len(p)))
+	}
+
+	n, err := w.entry.spool.Write(p)
+	w.entry.written = w.entry.written + int64(n)
+	return n, err
 }
 
 var (
 	_ io.Closer = &BundleFile{}
+
+	logger logr.Logger = klogr.New().V(5).WithName("bundle")
 )
 
 func NewBundle(filepath string) (b *BundleFile, err error) {
@@ -60,20 +112,35 @@ func (f *BundleFile) open(fileName string) error {
 		return err
 	}
 
-	info, err := file.Stat()
+	// find the end of the last complete entry, new files are appended from
+	// there which overwrites the tar trailer
+	end, recovery, err := findEnd(file)
 	if err != nil {
+		file.Close()
 		return err
 	}
 
-	// if the tar file has been written to previously, we need to remove the last
-	// 1024 bytes to append new files
-	if info.Size() > 1024 {
-		if _, err = file.Seek(-1024, os.SEEK_END); err != nil {
+	if recovery != nil {
+		if err := file.Truncate(end); err != nil {
+			file.Close()
+			return err
+		}
+
+		if err := file.Sync(); err != nil {
+			file.Close()
 			return err
 		}
+
+		logger.Info("recovered bundle", "file", fileName, "lost", recovery.Lost, "truncated", recovery.Truncated)
+	}
+
+	if _, err = file.Seek(end, io.SeekStart); err != nil {
+		file.Close()
+		return err
 	}
 
 	f.file = file
+	f.recovery = recovery
 	f.tar = tar.NewWriter(file)
 	f.tarReader = tar.NewReader(file)
 
@@ -84,6 +151,12 @@ func (f *BundleFile) Name() string {
 	return f.file.Name()
 }
 
+// Recovery returns what was removed from the bundle when it was opened, or
+// nil if the bundle was complete.
+func (f *BundleFile) Recovery() *Recovery {
+	return f.recovery
+}
+
 func (f *BundleFile) NewFile(filename string, size int64) (io.Writer, error) {
 	hdr := &tar.Header{
 		Name: filename,
@@ -91,14 +164,162 @@ func (f *BundleFile) NewFile(filename string, size int64) (io.Writer, error) {
 		Size: size,
 	}
 
-	if err := f.tar.WriteHeader(hdr); err != nil {
+	return f.newEntry(hdr)
+}
+
+// newEntry commits the previous entry and returns a writer for the data of
+// the new one.
+func (f *BundleFile) newEntry(hdr *tar.Header) (io.Writer, error) {
+	if err := f.commit(); err != nil {
 		return nil, err
 	}
-	return f.tar, nil
+
+	spool, err := os.CreateTemp("", "datactl-entry-*")
+	if err != nil {
+		return nil, err
+	}
+
+	f.pending = &pendingEntry{header: hdr, spool: spool}
+	return &entryWriter{bundle: f, entry: f.pending}, nil
+}
+
+// discard drops the pending entry.
+func (f *BundleFile) discard() {
+	pending := f.pending
+	if pending == nil {
+		return
+	}
+
+	f.pending = nil
+	pending.spool.Close()
+	os.Remove(pending.spool.Name())
+}
+
+// commit appends the pending entry to the tar and syncs it to disk. An entry
+// with less data than the size of its header is dropped.
+func (f *BundleFile) commit() error {
+	pending := f.pending
+	if pending == nil {
+		return nil
+	}
+
+	f.pending = nil
+	defer os.Remove(pending.spool.Name())
+	defer pending.spool.Close()
+
+	size, err := pending.spool.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	if size != pending.header.Size {
+		return errors.NewWithDetails("file size does not match the size written",
+			"file", pending.header.Name, "size", pending.header.Size, "written", size)
+	}
+
+	if _, err := pending.spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if err := f.tar.WriteHeader(pending.header); err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f.tar, pending.spool); err != nil {
+		return err
+	}
+
+	if err := f.tar.Flush(); err != nil {
+		return err
+	}
+
+	return f.file.Sync()
+}
+
+// newFileFromHeader adds an entry with the name, size and metadata of an
+// entry of another bundle.
+func (f *BundleFile) newFileFromHeader(header *tar.Header) (io.Writer, error) {
+	if m, ok := MetadataFromHeader(header); ok {
+		return f.NewFileWithMetadata(header.Name, header.Size, m)
+	}
+
+	return f.NewFile(header.Name, header.Size)
+}
+
+// Flush writes the last entry added to the bundle to disk. Entries are
+// otherwise written when the next entry is added or the bundle is closed, so
+// sources flush an entry before they record the file as pulled.
+func (f *BundleFile) Flush() error {
+	return f.commit()
 }
 
 func (f *BundleFile) Close() error {
-	return errors.Combine(f.tar.Close(), f.file.Close())
+	if err := f.commit(); err != nil {
+		return errors.Combine(err, f.tar.Close(), f.file.Close())
+	}
+
+	return errors.Combine(f.tar.Close(), f.file.Sync(), f.file.Close())
+}
+
+// findEnd returns the offset after the last complete entry of the tar. If the
+// tar is truncated or has data after its last entry that can't be read, the
+// recovery describes what has to be removed.
+func findEnd(file *os.File) (int64, *Recovery, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	counter := &countingReader{r: file}
+	tarReader := tar.NewReader(counter)
+
+	var end int64
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			// the trailer, or an empty file
+			return end, nil, nil
+		}
+
+		if err != nil {
+			if end == 0 && errors.Is(err, tar.ErrHeader) {
+				return 0, nil, errors.WrapWithDetails(err, "file is not a tar", "file", file.Name())
+			}
+
+			return end, &Recovery{Truncated: info.Size() - end}, nil
+		}
+
+		// the tar reader consumes exactly the header blocks on Next, the data
+		// follows padded to the block size
+		entryEnd := counter.n + blockPadded(header.Size)
+
+		if entryEnd > info.Size() {
+			return end, &Recovery{
+				Lost:      []string{header.Name},
+				Truncated: info.Size() - end,
+			}, nil
+		}
+
+		end = entryEnd
+	}
+}
+
+const blockSize = 512
+
+func blockPadded(size int64) int64 {
+	return (size + blockSize - 1) / blockSize * blockSize
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n = c.n + int64(n)
+	return n, err
 }
 
 func (f *BundleFile) Walk(walk func(header *tar.Header, r io.Reader)) error {
@@ -121,8 +342,6 @@ func (f *BundleFile) Compact(fileNames map[string]interface{}) error {
 	headers := map[string]int{}
 	os.Remove(f.Name() + "compact")
 	newBundle, err := NewBundle(f.Name() + "compact")
-	defer newBundle.Close()
-
 	if err != nil {
 		return err
 	}
@@ -135,6 +354,8 @@ func (f *BundleFile) Compact(fileNames map[string]interface{}) error {
 	})
 
 	if err != nil {
+		newBundle.Close()
+		os.Remove(newBundle.Name())
 		return err
 	}
 
@@ -161,7 +382,7 @@ func (f *BundleFile) Compact(fileNames map[string]interface{}) error {
 		}
 
 		var w io.Writer
-		w, err = newBundle.NewFile(header.Name, header.Size)
+		w, err = newBundle.newFileFromHeader(header)
 		if err != nil {
 			return err
 		}
@@ -174,8 +395,15 @@ func (f *BundleFile) Compact(fileNames map[string]interface{}) error {
 		return nil
 	})
 
+	if err != nil {
+		newBundle.Close()
+		os.Remove(newBundle.Name())
+		return err
+	}
+
 	err = newBundle.Close()
 	if err != nil {
+		os.Remove(newBundle.Name())
 		return err
 	}
 
@@ -28,7 +28,7 @@ import (
 
 var _ = Describe("export_file", func() {
 	It("should create a tar", func() {
-		tmpdir := os.TempDir()
+		tmpdir := GinkgoT().TempDir()
 		file, err := ioutil.TempFile(tmpdir, "export_file_*.tar")
 		Expect(err).To(Succeed())
 
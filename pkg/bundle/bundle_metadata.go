@@ -0,0 +1,140 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bundle
+
+import (
+	"archive/tar"
+	"io"
+	"time"
+
+	dataservicev1 "github.com/redhat-marketplace/datactl/pkg/datactl/api/dataservice/v1"
+)
+
+// MetadataVersion is the version of the metadata format written to the PAX
+// headers of bundle entries.
+const MetadataVersion = "1"
+
+// PAX record keys. Vendor specific keys are namespaced by an uppercase prefix
+// as recommended by the PAX format.
+const (
+	paxVersion    = "DATACTL.version"
+	paxSource     = "DATACTL.source"
+	paxSourceType = "DATACTL.sourceType"
+	paxID         = "DATACTL.id"
+	paxChecksum   = "DATACTL.checksum"
+	paxPulledAt   = "DATACTL.pulledAt"
+	paxExportName = "DATACTL.exportName"
+)
+
+// FileMetadata describes a bundle entry. It is stored in the PAX header of the
+// entry so a bundle can be pushed and inspected without the datactl config.
+type FileMetadata struct {
+	Source     string
+	SourceType string
+	ID         string
+	Checksum   string
+	PulledAt   time.Time
+	ExportName string
+}
+
+// NewFileMetadata returns the metadata of a file pulled into export.
+func NewFileMetadata(file *dataservicev1.FileInfoCTLAction, exportName string) FileMetadata {
+	return FileMetadata{
+		Source:     file.Source,
+		SourceType: file.SourceType,
+		ID:         file.Id,
+		Checksum:   file.VerifiedChecksum,
+		PulledAt:   time.Now().UTC(),
+		ExportName: exportName,
+	}
+}
+
+func (m FileMetadata) paxRecords() map[string]string {
+	records := map[string]string{
+		paxVersion: MetadataVersion,
+	}
+
+	set := func(key, value string) {
+		if value != "" {
+			records[key] = value
+		}
+	}
+
+	set(paxSource, m.Source)
+	set(paxSourceType, m.SourceType)
+	set(paxID, m.ID)
+	set(paxChecksum, m.Checksum)
+	set(paxExportName, m.ExportName)
+
+	if !m.PulledAt.IsZero() {
+		records[paxPulledAt] = m.PulledAt.Format(time.RFC3339)
+	}
+
+	return records
+}
+
+// MetadataFromHeader reads the metadata of a bundle entry. It returns false if
+// the entry was written without metadata.
+func MetadataFromHeader(header *tar.Header) (FileMetadata, bool) {
+	if _, ok := header.PAXRecords[paxVersion]; !ok {
+		return FileMetadata{}, false
+	}
+
+	m := FileMetadata{
+		Source:     header.PAXRecords[paxSource],
+		SourceType: header.PAXRecords[paxSourceType],
+		ID:         header.PAXRecords[paxID],
+		Checksum:   header.PAXRecords[paxChecksum],
+		ExportName: header.PAXRecords[paxExportName],
+	}
+
+	if pulledAt, ok := header.PAXRecords[paxPulledAt]; ok {
+		m.PulledAt, _ = time.Parse(time.RFC3339, pulledAt)
+	}
+
+	return m, true
+}
+
+// FileInfoFromHeader builds the file record of a bundle entry from its
+// metadata, for bundles used without the datactl config.
+func FileInfoFromHeader(header *tar.Header) *dataservicev1.FileInfoCTLAction {
+	file := dataservicev1.NewFileInfoCTLAction(&dataservicev1.FileInfo{})
+	file.Name = header.Name
+	file.Size = uint32(header.Size)
+
+	if m, ok := MetadataFromHeader(header); ok {
+		file.Id = m.ID
+		file.Source = m.Source
+		file.SourceType = m.SourceType
+		file.Checksum = m.Checksum
+		file.VerifiedChecksum = m.Checksum
+	}
+
+	return file
+}
+
+// NewFileWithMetadata adds an entry to the bundle with its metadata recorded
+// in the PAX header.
+func (f *BundleFile) NewFileWithMetadata(filename string, size int64, metadata FileMetadata) (io.Writer, error) {
+	hdr := &tar.Header{
+		Name:       filename,
+		Mode:       int64(fileMode),
+		Size:       size,
+		Format:     tar.FormatPAX,
+		PAXRecords: metadata.paxRecords(),
+	}
+
+	return f.newEntry(hdr)
+}
@@ -0,0 +1,78 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bundle
+
+import (
+	"archive/tar"
+	"io"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("bundle metadata", func() {
+	It("should record metadata in the entries and keep it on compact", func() {
+		name := filepath.Join(GinkgoT().TempDir(), "bundle.tar")
+
+		bundle, err := NewBundle(name)
+		Expect(err).To(Succeed())
+
+		pulledAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		metadata := FileMetadata{
+			Source:     "cluster",
+			SourceType: "dataservice",
+			ID:         "id-1",
+			Checksum:   "abc",
+			PulledAt:   pulledAt,
+			ExportName: "export-1",
+		}
+
+		w, err := bundle.NewFileWithMetadata("a", 3, metadata)
+		Expect(err).To(Succeed())
+		_, err = w.Write([]byte("foo"))
+		Expect(err).To(Succeed())
+
+		w, err = bundle.NewFile("b", 3)
+		Expect(err).To(Succeed())
+		_, err = w.Write([]byte("bar"))
+		Expect(err).To(Succeed())
+
+		Expect(bundle.Close()).To(Succeed())
+		Expect(bundle.Compact(nil)).To(Succeed())
+
+		headers := []*tar.Header{}
+		Expect(WalkTar(name, func(header *tar.Header, r io.Reader) error {
+			headers = append(headers, header)
+			return nil
+		})).To(Succeed())
+		Expect(headers).To(HaveLen(2))
+
+		m, ok := MetadataFromHeader(headers[0])
+		Expect(ok).To(BeTrue())
+		Expect(m).To(Equal(metadata))
+
+		_, ok = MetadataFromHeader(headers[1])
+		Expect(ok).To(BeFalse())
+
+		file := FileInfoFromHeader(headers[0])
+		Expect(file.Name).To(Equal("a"))
+		Expect(file.Id).To(Equal("id-1"))
+		Expect(file.Source).To(Equal("cluster"))
+		Expect(file.SourceType).To(Equal("dataservice"))
+		Expect(file.VerifiedChecksum).To(Equal("abc"))
+	})
+})
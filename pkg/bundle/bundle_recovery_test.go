@@ -0,0 +1,179 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bundle
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+
+	"emperror.dev/errors"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func writeEntries(name string, entries ...string) {
+	b, err := NewBundle(name)
+	Expect(err).To(Succeed())
+
+	for _, entry := range entries {
+		w, err := b.NewFile(entry, int64(len(entry)))
+		Expect(err).To(Succeed())
+		_, err = w.Write([]byte(entry))
+		Expect(err).To(Succeed())
+	}
+
+	Expect(b.Close()).To(Succeed())
+}
+
+func entryNames(name string) []string {
+	names := []string{}
+	Expect(WalkTar(name, func(header *tar.Header, r io.Reader) error {
+		names = append(names, header.Name)
+		return nil
+	})).To(Succeed())
+	return names
+}
+
+var _ = Describe("bundle recovery", func() {
+	var name string
+
+	BeforeEach(func() {
+		name = filepath.Join(GinkgoT().TempDir(), "bundle.tar")
+	})
+
+	It("should append to a complete bundle without recovery", func() {
+		writeEntries(name, "a", "b")
+		writeEntries(name, "c")
+
+		b, err := NewBundle(name)
+		Expect(err).To(Succeed())
+		Expect(b.Recovery()).To(BeNil())
+		Expect(b.Close()).To(Succeed())
+
+		Expect(entryNames(name)).To(Equal([]string{"a", "b", "c"}))
+	})
+
+	It("should truncate a partially written entry", func() {
+		writeEntries(name, "a", "b")
+
+		info, err := os.Stat(name)
+		Expect(err).To(Succeed())
+
+		// a header of a file of 2 blocks followed by a single data block,
+		// as left by a pull killed while writing
+		f, err := os.OpenFile(name, os.O_RDWR, fileMode)
+		Expect(err).To(Succeed())
+		_, err = f.Seek(info.Size()-1024, io.SeekStart)
+		Expect(err).To(Succeed())
+		w := tar.NewWriter(f)
+		Expect(w.WriteHeader(&tar.Header{Name: "partial", Mode: 0640, Size: 1000})).To(Succeed())
+		_, err = w.Write(make([]byte, 512))
+		Expect(err).To(Succeed())
+		w.Flush()
+		Expect(f.Close()).To(Succeed())
+
+		Expect(WalkTar(name, func(*tar.Header, io.Reader) error { return nil })).ToNot(Succeed())
+
+		b, err := NewBundle(name)
+		Expect(err).To(Succeed())
+		Expect(b.Recovery()).ToNot(BeNil())
+		Expect(b.Recovery().Lost).To(Equal([]string{"partial"}))
+		Expect(b.Recovery().Truncated).To(Equal(int64(1024)))
+
+		w2, err := b.NewFile("c", 1)
+		Expect(err).To(Succeed())
+		_, err = w2.Write([]byte("c"))
+		Expect(err).To(Succeed())
+		Expect(b.Close()).To(Succeed())
+
+		Expect(entryNames(name)).To(Equal([]string{"a", "b", "c"}))
+	})
+
+	It("should truncate data after the last entry", func() {
+		writeEntries(name, "a")
+
+		f, err := os.OpenFile(name, os.O_RDWR, fileMode)
+		Expect(err).To(Succeed())
+		info, err := f.Stat()
+		Expect(err).To(Succeed())
+		_, err = f.WriteAt([]byte("garbage"), info.Size()-1024)
+		Expect(err).To(Succeed())
+		Expect(f.Close()).To(Succeed())
+
+		b, err := NewBundle(name)
+		Expect(err).To(Succeed())
+		Expect(b.Recovery()).ToNot(BeNil())
+		Expect(b.Recovery().Lost).To(BeEmpty())
+		Expect(b.Close()).To(Succeed())
+
+		Expect(entryNames(name)).To(Equal([]string{"a"}))
+	})
+
+	It("should drop an entry with less data than its size", func() {
+		b, err := NewBundle(name)
+		Expect(err).To(Succeed())
+
+		w, err := b.NewFile("short", 10)
+		Expect(err).To(Succeed())
+		_, err = w.Write([]byte("foo"))
+		Expect(err).To(Succeed())
+
+		_, err = b.NewFile("next", 1)
+		Expect(err).To(MatchError(ContainSubstring("file size does not match")))
+
+		w, err = b.NewFile("next", 1)
+		Expect(err).To(Succeed())
+		_, err = w.Write([]byte("n"))
+		Expect(err).To(Succeed())
+		Expect(b.Close()).To(Succeed())
+
+		Expect(entryNames(name)).To(Equal([]string{"next"}))
+	})
+
+	It("should fail a write over the size of an entry", func() {
+		b, err := NewBundle(name)
+		Expect(err).To(Succeed())
+
+		w, err := b.NewFile("long", 3)
+		Expect(err).To(Succeed())
+		_, err = w.Write([]byte("fo"))
+		Expect(err).To(Succeed())
+		_, err = w.Write([]byte("oo"))
+		Expect(errors.Is(err, tar.ErrWriteTooLong)).To(BeTrue())
+
+		// the entry is dropped, so the next one is not held back by it
+		w, err = b.NewFile("next", 1)
+		Expect(err).To(Succeed())
+		_, err = w.Write([]byte("n"))
+		Expect(err).To(Succeed())
+		Expect(b.Flush()).To(Succeed())
+
+		Expect(entryNames(name)).To(Equal([]string{"next"}))
+		Expect(b.Close()).To(Succeed())
+	})
+
+	It("should not truncate a file that is not a tar", func() {
+		Expect(os.WriteFile(name, []byte("this is not a tar file"+string(make([]byte, 1024))), fileMode)).To(Succeed())
+
+		_, err := NewBundle(name)
+		Expect(err).To(HaveOccurred())
+
+		info, err := os.Stat(name)
+		Expect(err).To(Succeed())
+		Expect(info.Size()).To(Equal(int64(22 + 1024)))
+	})
+})
@@ -107,7 +107,7 @@ func (d *dataServiceClient) ListFiles(ctx context.Context, opts ListOptions, fil
 	}
 
 	if opts.PageSize != nil {
-		q.Add(queryPageSize, fmt.Sprintf("%d", opts.PageSize))
+		q.Add(queryPageSize, fmt.Sprintf("%d", *opts.PageSize))
 	}
 
 	if opts.IncludeDeleted {
@@ -298,11 +298,12 @@ func (d *dataServiceClient) DeleteFile(ctx context.Context, id string) error {
 func (d *dataServiceClient) checkResponse(function string, resp *http.Response) error {
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(resp.Body)
+		redactedBody := shared.Redact(string(body))
 		logger.Info("response",
 			"func", function,
 			"statusCode", resp.StatusCode,
-			"body", string(body))
-		return errors.NewWithDetails("failed request", "status", http.StatusText(resp.StatusCode), "body", strings.TrimSpace(string(body)))
+			"body", redactedBody)
+		return errors.NewWithDetails("failed request", "status", http.StatusText(resp.StatusCode), "body", strings.TrimSpace(redactedBody))
 	}
 
 	return nil
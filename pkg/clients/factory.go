@@ -33,40 +33,8 @@ func ProvideDataService(
 	dsConfig *datactlapi.DataServiceEndpoint,
 ) (*dataservice.DataServiceConfig, error) {
 	errs := []error{}
-	tlsConfig := &tls.Config{}
-
-	err := func() error {
-		if dsConfig.InsecureSkipTLSVerify {
-			tlsConfig.InsecureSkipVerify = true
-			return nil
-		}
-
-		rootCAs, _ := x509.SystemCertPool()
-		if rootCAs == nil {
-			rootCAs = x509.NewCertPool()
-		}
-		tlsConfig.RootCAs = rootCAs
-
-		if dsConfig.CertificateAuthority != "" {
-			data, err := ioutil.ReadFile(dsConfig.CertificateAuthority)
-			if err != nil {
-				return fmt.Errorf("failed to read certificate authority file data from datactl config %s", err.Error())
-			}
-			ok := tlsConfig.RootCAs.AppendCertsFromPEM(data)
-			if !ok {
-				return fmt.Errorf("failed to append certificate authority file data from datactl config")
-			}
-		} else if len(dsConfig.CertificateAuthorityData) != 0 {
-			cert, err := x509.ParseCertificate(dsConfig.CertificateAuthorityData)
-			if err != nil {
-				return fmt.Errorf("failed to read certificate authority file data from datactl config %s", err.Error())
-			}
-			tlsConfig.RootCAs.AddCert(cert)
-		}
-
-		return nil
-	}()
 
+	tlsConfig, err := DataServiceTLSConfig(dsConfig)
 	if err != nil {
 		errs = append(errs, err)
 	}
@@ -115,6 +83,42 @@ func ProvideDataService(
 	}, nil
 }
 
+// DataServiceTLSConfig returns the TLS config verifying the route of a data
+// service endpoint, with the system root CAs and the CA of the endpoint.
+func DataServiceTLSConfig(dsConfig *datactlapi.DataServiceEndpoint) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+
+	if dsConfig.InsecureSkipTLSVerify {
+		tlsConfig.InsecureSkipVerify = true
+		return tlsConfig, nil
+	}
+
+	rootCAs, _ := x509.SystemCertPool()
+	if rootCAs == nil {
+		rootCAs = x509.NewCertPool()
+	}
+	tlsConfig.RootCAs = rootCAs
+
+	if dsConfig.CertificateAuthority != "" {
+		data, err := ioutil.ReadFile(dsConfig.CertificateAuthority)
+		if err != nil {
+			return tlsConfig, fmt.Errorf("failed to read certificate authority file data from datactl config %s", err.Error())
+		}
+		ok := tlsConfig.RootCAs.AppendCertsFromPEM(data)
+		if !ok {
+			return tlsConfig, fmt.Errorf("failed to append certificate authority file data from datactl config")
+		}
+	} else if len(dsConfig.CertificateAuthorityData) != 0 {
+		cert, err := x509.ParseCertificate(dsConfig.CertificateAuthorityData)
+		if err != nil {
+			return tlsConfig, fmt.Errorf("failed to read certificate authority file data from datactl config %s", err.Error())
+		}
+		tlsConfig.RootCAs.AddCert(cert)
+	}
+
+	return tlsConfig, nil
+}
+
 func ProvideIlmtSource(
 	ilmtConfig *datactlapi.ILMTEndpoint,
 ) (*ilmt.IlmtConfig, error) {
@@ -188,5 +192,21 @@ func ProvideMarketplaceUpload(
 		TlsConfig: &tls.Config{
 			RootCAs: rootCAs,
 		},
+		RetryPolicy: marketplaceRetryPolicy(mktplConfig.Retry),
 	}, nil
 }
+
+// marketplaceRetryPolicy returns the retry policy of the upload API config, or
+// nil to use the default one.
+func marketplaceRetryPolicy(retry *datactlapi.UploadRetryPolicy) *marketplace.RetryPolicy {
+	if retry == nil {
+		return nil
+	}
+
+	return &marketplace.RetryPolicy{
+		MaxAttempts:          retry.MaxAttempts,
+		BaseDelay:            retry.BaseDelay.Duration,
+		MaxDelay:             retry.MaxDelay.Duration,
+		RetryableStatusCodes: retry.RetryableStatusCodes,
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ilmt
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"emperror.dev/errors"
+)
+
+// Errors returned by the ILMT client. Use errors.Is to check for them.
+const (
+	// AuthError is returned when ILMT rejects the token.
+	AuthError = errors.Sentinel("ilmt authentication failed")
+
+	// NotFoundError is returned when the ILMT API is not found on the host.
+	NotFoundError = errors.Sentinel("ilmt api not found")
+
+	// ServerError is returned when ILMT fails to serve a request or asks the
+	// client to slow down. It is retried.
+	ServerError = errors.Sentinel("ilmt server error")
+
+	// DecodeError is returned when a response of ILMT can't be read.
+	DecodeError = errors.Sentinel("ilmt response can't be decoded")
+
+	// TotalMismatchError is returned when the rows of a response don't add up
+	// to the total reported by ILMT.
+	TotalMismatchError = errors.Sentinel("ilmt rows don't match the reported total")
+
+	// RetryableError is returned when a request fails before ILMT responds.
+	RetryableError = errors.Sentinel("retryable")
+)
+
+func isRetryable(err error) bool {
+	return errors.Is(err, ServerError) || errors.Is(err, RetryableError)
+}
+
+// isFatal returns true for errors that fail every day of a range, so the
+// remaining days are not fetched.
+func isFatal(err error) bool {
+	return errors.Is(err, AuthError) || errors.Is(err, NotFoundError)
+}
+
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode < 300 && resp.StatusCode >= 200 {
+		return nil
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return errors.WithDetails(AuthError, "code", resp.StatusCode)
+	case resp.StatusCode == http.StatusNotFound:
+		return errors.WithDetails(NotFoundError, "code", resp.StatusCode)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return errors.WithDetails(ServerError, "code", resp.StatusCode)
+	}
+
+	return errors.NewWithDetails("ilmt request failed", "code", resp.StatusCode)
+}
+
+// requestError hides the url of a failed request, as it holds the token.
+func requestError(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+
+	return errors.WithDetails(RetryableError, "message", err.Error())
+}
+
+// DayError is the error of fetching the usage of a single day.
+type DayError struct {
+	Date string
+	Err  error
+}
+
+// PartialError is returned by FetchUsageData and FetchDailyUsageData when the
+// usage of some days could not be fetched. The usage returned with it covers
+// the days before the first failed day, or every other day for the daily usage.
+type PartialError struct {
+	// Failed are the days that failed, in date order.
+	Failed []DayError
+
+	// Stopped is set if an error that would fail every day stopped the fetch.
+	// The days after the last failed day were not fetched.
+	Stopped bool
+}
+
+func (e *PartialError) Error() string {
+	dates := make([]string, 0, len(e.Failed))
+	for _, day := range e.Failed {
+		dates = append(dates, day.Date)
+	}
+
+	msg := fmt.Sprintf("failed to fetch usage for %s", strings.Join(dates, ", "))
+	if e.Stopped {
+		msg = msg + " and the following days"
+	}
+
+	return msg
+}
+
+// FirstFailedDate is the date to start the next fetch from.
+func (e *PartialError) FirstFailedDate() string {
+	if len(e.Failed) == 0 {
+		return ""
+	}
+
+	return e.Failed[0].Date
+}
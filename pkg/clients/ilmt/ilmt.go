@@ -20,18 +20,19 @@ import (
 	"crypto/tls"
 	b64 "encoding/base64"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
+	"emperror.dev/errors"
 	"github.com/go-logr/logr"
 	"github.com/redhat-marketplace/datactl/pkg/clients/shared"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2/klogr"
 )
 
@@ -47,7 +48,7 @@ var (
 type IlmtConfig struct {
 	Host  string `json:"host"`
 	Port  string `json:"port"`
-	Token string `json:"token"`
+	Token string `json:"-"`
 
 	TlsConfig *tls.Config
 }
@@ -59,12 +60,25 @@ type ilmtClient struct {
 }
 
 type Client interface {
+	// FetchUsageData fetches the usage of the range as a single report and
+	// returns the count of days it holds. If some days fail, the report holds
+	// the days before the first failed day and is returned with a
+	// *PartialError, so the range can be fetched again from that day.
 	FetchUsageData(ctx context.Context, dateRange DateRange) (int, string, error)
+
+	// FetchDailyUsageData fetches the usage of each day of the range as a
+	// separate report. If some days fail, the other days are returned with a
+	// *PartialError.
+	FetchDailyUsageData(ctx context.Context, dateRange DateRange) ([]DailyUsage, error)
 }
 
 func NewClient(config *IlmtConfig) (Client, error) {
+	shared.RegisterSecret(config.Token)
+
+	// the token is sent in a header to keep it out of urls and proxy logs
 	client, err := shared.NewHttpClient(
 		config.TlsConfig,
+		shared.WithHeaders("token", config.Token),
 	)
 	if err != nil {
 		return nil, err
@@ -78,192 +92,318 @@ func NewClient(config *IlmtConfig) (Client, error) {
 }
 
 func (ilmtC *ilmtClient) FetchUsageData(ctx context.Context, dateRange DateRange) (int, string, error) {
-	startDate, _ := time.Parse(REQUIRED_FORMAT, dateRange.StartDate)
-	endDate, _ := time.Parse(REQUIRED_FORMAT, dateRange.EndDate)
-	fileCounter := 0
+	days, err := ilmtC.FetchDailyUsageData(ctx, dateRange)
+
+	var partial *PartialError
+	if err != nil && !errors.As(err, &partial) {
+		return 0, EMPTY, err
+	}
 
 	productUsageTransformedEventJson := make([]ProductUsageTransformedEventData, 0)
+	count := 0
+	for _, day := range days {
+		// the days after a failed day are fetched again with it
+		if partial != nil && day.Date > partial.FirstFailedDate() {
+			break
+		}
 
-	for selectedDate := startDate; !selectedDate.After(endDate); selectedDate = selectedDate.AddDate(0, 0, 1) {
+		productUsageTransformedEventJson = append(productUsageTransformedEventJson, day.events...)
+		count = count + 1
+	}
 
-		startDateMillis := selectedDate.UnixMilli()
-		endDateMillis := selectedDate.AddDate(0, 0, 1).UnixMilli() - 1
+	return count, marshalEvents(productUsageTransformedEventJson), err
+}
 
-		urlForStandaloneProductUsage, err := ilmtC.req.FetchUsageData(ctx, ilmtC.IlmtConfig.Host, ilmtC.IlmtConfig.Token, CRITERIA_STANDALONE, strings.Split(selectedDate.String(), BLANK_VALUE)[0], strings.Split(selectedDate.String(), BLANK_VALUE)[0])
-		if err != nil {
-			log.Fatal(err.Error())
-		}
+func (ilmtC *ilmtClient) FetchDailyUsageData(ctx context.Context, dateRange DateRange) ([]DailyUsage, error) {
+	startDate, err := time.Parse(REQUIRED_FORMAT, dateRange.StartDate)
+	if err != nil {
+		return nil, errors.WrapWithDetails(err, "invalid start date", "startDate", dateRange.StartDate)
+	}
 
-		urlForProductPartOfBndlUsage, err := ilmtC.req.FetchUsageData(ctx, ilmtC.IlmtConfig.Host, ilmtC.IlmtConfig.Token, CRITEIRA_PRODUCTPARTOFBNDL, strings.Split(selectedDate.String(), BLANK_VALUE)[0], strings.Split(selectedDate.String(), BLANK_VALUE)[0])
-		if err != nil {
-			log.Fatal(err.Error())
-		}
+	endDate, err := time.Parse(REQUIRED_FORMAT, dateRange.EndDate)
+	if err != nil {
+		return nil, errors.WrapWithDetails(err, "invalid end date", "endDate", dateRange.EndDate)
+	}
+
+	days := []DailyUsage{}
+	partial := &PartialError{}
+
+	for selectedDate := startDate; !selectedDate.After(endDate); selectedDate = selectedDate.AddDate(0, 0, 1) {
+		date := selectedDate.Format(REQUIRED_FORMAT)
 
-		urlForParentProductUsage, err := ilmtC.req.FetchUsageData(ctx, ilmtC.IlmtConfig.Host, ilmtC.IlmtConfig.Token, CRITERIA_PARENTPRODUCT, strings.Split(selectedDate.String(), BLANK_VALUE)[0], strings.Split(selectedDate.String(), BLANK_VALUE)[0])
+		events, err := ilmtC.fetchDay(ctx, selectedDate)
 		if err != nil {
-			log.Fatal(err.Error())
+			logger.Info("failed to fetch usage", "date", date, "err", err)
+			partial.Failed = append(partial.Failed, DayError{Date: date, Err: err})
+
+			if isFatal(err) || ctx.Err() != nil {
+				partial.Stopped = !selectedDate.Equal(endDate)
+				break
+			}
+
+			continue
 		}
 
-		// licence usage for product
-		standaloneProductResp, err := ilmtC.Do(urlForStandaloneProductUsage)
+		days = append(days, DailyUsage{
+			Date:   date,
+			Data:   marshalEvents(events),
+			events: events,
+		})
+	}
 
-		if err != nil {
-			log.Fatal(err.Error())
+	if len(partial.Failed) != 0 {
+		return days, partial
+	}
+
+	return days, nil
+}
+
+// marshalEvents returns the events in the format of a report.
+func marshalEvents(events []ProductUsageTransformedEventData) string {
+	productUsageTransformedEvent := ProductUsageTransformedEvent{
+		ProductUsageTransformedEventData: events,
+	}
+	productUsageTransformedEventNewJson, _ := json.Marshal(productUsageTransformedEvent)
+	productUsageTransformedEventJsonStr := string(productUsageTransformedEventNewJson)
+
+	// fix to adjust types returned by ILMT and required by RHM
+	measuredValue := regexp.MustCompile(`"measuredValue":\s?(\d*),`)
+	parentProductId := regexp.MustCompile(`"parentProductId":\s?(\d*),`)
+	productId := regexp.MustCompile(`"productId":\s?(\d*),`)
+
+	productUsageTransformedEventJsonStr = measuredValue.ReplaceAllString(productUsageTransformedEventJsonStr, "\"measuredValue\":\"$1\",")
+	productUsageTransformedEventJsonStr = parentProductId.ReplaceAllString(productUsageTransformedEventJsonStr, "\"parentProductId\":\"$1\",")
+	productUsageTransformedEventJsonStr = productId.ReplaceAllString(productUsageTransformedEventJsonStr, "\"productId\":\"$1\",")
+
+	return productUsageTransformedEventJsonStr
+}
+
+// fetchDay fetches the license usage of a day and transforms it into events.
+func (ilmtC *ilmtClient) fetchDay(ctx context.Context, selectedDate time.Time) ([]ProductUsageTransformedEventData, error) {
+	startDateMillis := selectedDate.UnixMilli()
+	endDateMillis := selectedDate.AddDate(0, 0, 1).UnixMilli() - 1
+
+	var standaloneProductRespObj StandaloneProductResp
+	if err := ilmtC.get(ctx, CRITERIA_STANDALONE, selectedDate, &standaloneProductRespObj); err != nil {
+		return nil, err
+	}
+
+	// licence usage for product that are part of bundle
+	var productPartOfBndlRespObj ProductPartOfBndlResp
+	if err := ilmtC.get(ctx, CRITEIRA_PRODUCTPARTOFBNDL, selectedDate, &productPartOfBndlRespObj); err != nil {
+		return nil, err
+	}
+
+	// licence usage for parent products of bundles
+	var parentProductRespObj ParentProductResp
+	if err := ilmtC.get(ctx, CRITERIA_PARENTPRODUCT, selectedDate, &parentProductRespObj); err != nil {
+		return nil, err
+	}
+
+	events := make([]ProductUsageTransformedEventData, 0)
+
+	for _, productResp := range standaloneProductRespObj.StandaloneProductLicenceUsage {
+
+		productId := productResp.ProductId
+		measuredMetricId := productResp.MetricCodeName
+		metricId := productResp.MetricCodeName
+		host := eventHost(ilmtC.IlmtConfig.Host)
+		BELL := '\a'
+
+		eventId := fmt.Sprintf("%d%U%d%U%s%U%s%U%s", startDateMillis, BELL, productId, BELL, measuredMetricId, BELL, EMPTY, BELL, host)
+		h := sha256.New()
+		h.Write([]byte(eventId))
+		bs := h.Sum(nil)
+		sEnc := b64.StdEncoding.EncodeToString(bs)
+		eventIdFinal := "ILMT-" + sEnc
+
+		measuredValue := productResp.HwmQuantity
+		productName := productResp.ProductName
+
+		measuredUsage := []MeasuredUsage{
+			{
+				MetricId: metricId,
+				Value:    1,
+			},
 		}
 
-		standaloneProductRespData, err := ioutil.ReadAll(standaloneProductResp.Body)
-		if err != nil {
-			log.Fatal(err.Error())
+		additionalAttributes := AdditionalAttributes{
+			HostName:         host,
+			MeasuredMetricId: measuredMetricId,
+			MeasuredValue:    measuredValue,
+			MetricType:       "license",
+			ProductId:        productId,
+			ProductName:      productName,
+			Source:           "ILMT",
 		}
 
-		var standaloneProductRespObj StandaloneProductResp
-		json.Unmarshal(standaloneProductRespData, &standaloneProductRespObj)
+		productUsageTransformedEventData := ProductUsageTransformedEventData{
+			StartDate:            startDateMillis,
+			EndDate:              endDateMillis,
+			EventId:              eventIdFinal,
+			MeasuredUsage:        measuredUsage,
+			AdditionalAttributes: additionalAttributes,
+		}
+
+		events = append(events, productUsageTransformedEventData)
+	}
+
+	for _, prodPartOfBundle := range productPartOfBndlRespObj.ProductPartOfBndlLicenceUsage {
 
-		// licence usage for product that are part of bundle
-		productPartOfBndlResp, err := ilmtC.Do(urlForProductPartOfBndlUsage)
+		productId := prodPartOfBundle.ProductId
+		measuredMetricId := prodPartOfBundle.MetricCodeName
+		parentProductId, parentProductName, metricId, err := GetParentProduct(prodPartOfBundle, parentProductRespObj)
 		if err != nil {
-			log.Fatal(err.Error())
+			return nil, err
+		}
+		host := eventHost(ilmtC.IlmtConfig.Host)
+		BELL := '\a'
+
+		eventId := fmt.Sprintf("%d%U%d%U%s%U%d%U%s", startDateMillis, BELL, productId, BELL, measuredMetricId, BELL, parentProductId, BELL, host)
+		h := sha256.New()
+		h.Write([]byte(eventId))
+		bs := h.Sum(nil)
+		sEnc := b64.StdEncoding.EncodeToString(bs)
+		eventIdFinal := "ILMT-" + sEnc
+
+		measuredValue := prodPartOfBundle.HwmQuantity
+		productConversionRatio := GetProductConversionRatio(prodPartOfBundle.ProdBndlRatioDivider, prodPartOfBundle.ProdBndlRatioFactor)
+		productName := prodPartOfBundle.ProductName
+
+		measuredUsage := []MeasuredUsage{
+			{
+				MetricId: metricId,
+				Value:    1,
+			},
 		}
 
-		productPartOfBndlRespData, err := ioutil.ReadAll(productPartOfBndlResp.Body)
-		if err != nil {
-			log.Fatal(err.Error())
+		additionalAttributes := AdditionalAttributes{
+			HostName:               host,
+			MeasuredMetricId:       measuredMetricId,
+			MeasuredValue:          measuredValue,
+			MetricType:             "license",
+			ParentProductId:        parentProductId,
+			ParentProductName:      parentProductName,
+			ProductConversionRatio: productConversionRatio,
+			ProductId:              productId,
+			ProductName:            productName,
+			Source:                 "ILMT",
 		}
 
-		var productPartOfBndlRespObj ProductPartOfBndlResp
-		json.Unmarshal(productPartOfBndlRespData, &productPartOfBndlRespObj)
+		productUsageTransformedEventData := ProductUsageTransformedEventData{
+			StartDate:            startDateMillis,
+			EndDate:              endDateMillis,
+			EventId:              eventIdFinal,
+			MeasuredUsage:        measuredUsage,
+			AdditionalAttributes: additionalAttributes,
+		}
+		events = append(events, productUsageTransformedEventData)
+	}
 
-		// licence usage for product that are part of bundlE
-		parentProductResp, err := ilmtC.Do(urlForParentProductUsage)
-		if err != nil {
-			log.Fatal(err.Error())
+	return events, nil
+}
+
+var DefaultBackoff = wait.Backoff{
+	Steps:    4,
+	Duration: 50 * time.Millisecond,
+	Factor:   5.0,
+	Jitter:   0.1,
+}
+
+// PageSize is the number of license_usage rows requested at once.
+var PageSize = 500
+
+// get requests all the pages of the license usage of a day matching criteria
+// and decodes them into out.
+func (ilmtC *ilmtClient) get(ctx context.Context, criteria string, selectedDate time.Time, out interface{}) error {
+	rows := []json.RawMessage{}
+	total := -1
+
+	for {
+		var page licenseUsagePage
+		if err := ilmtC.getPage(ctx, criteria, selectedDate, len(rows), &page); err != nil {
+			return err
 		}
 
-		parentProductRespData, err := ioutil.ReadAll(parentProductResp.Body)
-		if err != nil {
-			log.Fatal(err.Error())
+		if total == -1 {
+			total = page.TotalRows
 		}
 
-		var parentProductRespObj ParentProductResp
-		json.Unmarshal(parentProductRespData, &parentProductRespObj)
+		if page.TotalRows != total {
+			return errors.WithDetails(TotalMismatchError, "date", selectedDate.Format(REQUIRED_FORMAT), "total", total, "pageTotal", page.TotalRows)
+		}
 
-		for _, productResp := range standaloneProductRespObj.StandaloneProductLicenceUsage {
+		rows = append(rows, page.Rows...)
 
-			productId := productResp.ProductId
-			measuredMetricId := productResp.MetricCodeName
-			metricId := productResp.MetricCodeName
-			host := ilmtC.IlmtConfig.Host[8:38]
-			BELL := '\a'
+		if len(page.Rows) == 0 || len(rows) >= total {
+			break
+		}
+	}
 
-			eventId := fmt.Sprintf("%d%U%d%U%s%U%s%U%s", startDateMillis, BELL, productId, BELL, measuredMetricId, BELL, EMPTY, BELL, host)
-			h := sha256.New()
-			h.Write([]byte(eventId))
-			bs := h.Sum(nil)
-			sEnc := b64.StdEncoding.EncodeToString(bs)
-			eventIdFinal := "ILMT-" + sEnc
+	if len(rows) != total {
+		return errors.WithDetails(TotalMismatchError, "date", selectedDate.Format(REQUIRED_FORMAT), "total", total, "rows", len(rows))
+	}
 
-			measuredValue := productResp.HwmQuantity
-			productName := productResp.ProductName
+	data, err := json.Marshal(licenseUsagePage{TotalRows: total, Rows: rows})
+	if err != nil {
+		return errors.WithDetails(DecodeError, "message", err.Error())
+	}
 
-			measuredUsage := []MeasuredUsage{
-				{
-					MetricId: metricId,
-					Value:    1,
-				},
-			}
+	if err := json.Unmarshal(data, out); err != nil {
+		return errors.WithDetails(DecodeError, "message", err.Error())
+	}
 
-			additionalAttributes := AdditionalAttributes{
-				HostName:         host,
-				MeasuredMetricId: measuredMetricId,
-				MeasuredValue:    measuredValue,
-				MetricType:       "license",
-				ProductId:        productId,
-				ProductName:      productName,
-				Source:           "ILMT",
-			}
+	return nil
+}
 
-			productUsageTransformedEventData := ProductUsageTransformedEventData{
-				StartDate:            startDateMillis,
-				EndDate:              endDateMillis,
-				EventId:              eventIdFinal,
-				MeasuredUsage:        measuredUsage,
-				AdditionalAttributes: additionalAttributes,
-			}
+// getPage requests a page of the license usage of a day. Server and
+// connection errors are retried.
+func (ilmtC *ilmtClient) getPage(ctx context.Context, criteria string, selectedDate time.Time, offset int, page *licenseUsagePage) error {
+	date := selectedDate.Format(REQUIRED_FORMAT)
 
-			productUsageTransformedEventJson = append(productUsageTransformedEventJson, productUsageTransformedEventData)
+	return retry.OnError(DefaultBackoff, isRetryable, func() error {
+		req, err := ilmtC.req.FetchUsageData(ctx, ilmtC.IlmtConfig.Host, criteria, date, date, offset, PageSize)
+		if err != nil {
+			return errors.Wrap(err, "failed to build request")
 		}
 
-		for _, prodPartOfBundle := range productPartOfBndlRespObj.ProductPartOfBndlLicenceUsage {
-
-			productId := prodPartOfBundle.ProductId
-			measuredMetricId := prodPartOfBundle.MetricCodeName
-			parentProductId, parentProductName, metricId, err := GetParentProduct(prodPartOfBundle, parentProductRespObj)
-			if err != nil {
-				log.Fatal(err.Error())
-			}
-			host := ilmtC.IlmtConfig.Host[8:38]
-			BELL := '\a'
-
-			eventId := fmt.Sprintf("%d%U%d%U%s%U%d%U%s", startDateMillis, BELL, productId, BELL, measuredMetricId, BELL, parentProductId, BELL, host)
-			h := sha256.New()
-			h.Write([]byte(eventId))
-			bs := h.Sum(nil)
-			sEnc := b64.StdEncoding.EncodeToString(bs)
-			eventIdFinal := "ILMT-" + sEnc
-
-			measuredValue := prodPartOfBundle.HwmQuantity
-			productConversionRatio := GetProductConversionRatio(prodPartOfBundle.ProdBndlRatioDivider, prodPartOfBundle.ProdBndlRatioFactor)
-			productName := prodPartOfBundle.ProductName
-
-			measuredUsage := []MeasuredUsage{
-				{
-					MetricId: metricId,
-					Value:    1,
-				},
+		resp, err := ilmtC.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
 			}
 
-			additionalAttributes := AdditionalAttributes{
-				HostName:               host,
-				MeasuredMetricId:       measuredMetricId,
-				MeasuredValue:          measuredValue,
-				MetricType:             "license",
-				ParentProductId:        parentProductId,
-				ParentProductName:      parentProductName,
-				ProductConversionRatio: productConversionRatio,
-				ProductId:              productId,
-				ProductName:            productName,
-				Source:                 "ILMT",
-			}
+			return requestError(err)
+		}
 
-			productUsageTransformedEventData := ProductUsageTransformedEventData{
-				StartDate:            startDateMillis,
-				EndDate:              endDateMillis,
-				EventId:              eventIdFinal,
-				MeasuredUsage:        measuredUsage,
-				AdditionalAttributes: additionalAttributes,
-			}
-			productUsageTransformedEventJson = append(productUsageTransformedEventJson, productUsageTransformedEventData)
+		defer resp.Body.Close()
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return requestError(err)
 		}
 
-		fileCounter++
-	}
+		if err := checkStatus(resp); err != nil {
+			return err
+		}
 
-	productUsageTransformedEvent := ProductUsageTransformedEvent{
-		ProductUsageTransformedEventData: productUsageTransformedEventJson,
-	}
-	productUsageTransformedEventNewJson, _ := json.Marshal(productUsageTransformedEvent)
-	productUsageTransformedEventJsonStr := string(productUsageTransformedEventNewJson)
+		if err := json.Unmarshal(data, page); err != nil {
+			return errors.WithDetails(DecodeError, "message", err.Error())
+		}
 
-	// fix to adjust types returned by ILMT and required by RHM
-	measuredValue := regexp.MustCompile(`"measuredValue":\s?(\d*),`)
-	parentProductId := regexp.MustCompile(`"parentProductId":\s?(\d*),`)
-	productId := regexp.MustCompile(`"productId":\s?(\d*),`)
+		return nil
+	})
+}
 
-	productUsageTransformedEventJsonStr = measuredValue.ReplaceAllString(productUsageTransformedEventJsonStr, "\"measuredValue\":\"$1\",")
-	productUsageTransformedEventJsonStr = parentProductId.ReplaceAllString(productUsageTransformedEventJsonStr, "\"parentProductId\":\"$1\",")
-	productUsageTransformedEventJsonStr = productId.ReplaceAllString(productUsageTransformedEventJsonStr, "\"productId\":\"$1\",")
+// eventHost is the part of the ILMT url recorded in events.
+func eventHost(host string) string {
+	host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+
+	if len(host) > 30 {
+		return host[:30]
+	}
 
-	return fileCounter, productUsageTransformedEventJsonStr, nil
+	return host
 }
 
 func GetParentProduct(prodPartOfbndl ProductPartOfBndlLicenceUsage, parentProdResp ParentProductResp) (int64, string, string, error) {
@@ -290,8 +430,8 @@ type reqBuilder struct {
 	Host string
 }
 
-func (u *reqBuilder) FetchUsageData(ctx context.Context, host string, token string, criteria string, startdate string, enddate string) (*http.Request, error) {
-	u.Host = fmt.Sprintf("%s/api/sam/v2/license_usage?token=%s%s%s%s%s%s%s", host, token, COLUMN_NAMES_APPEND, criteria, START_DATE_FLD_APPEND, startdate, END_DATE_FLD_APPEND, enddate)
+func (u *reqBuilder) FetchUsageData(ctx context.Context, host string, criteria string, startdate string, enddate string, offset int, limit int) (*http.Request, error) {
+	u.Host = fmt.Sprintf("%s/api/sam/v2/license_usage?%s%s%s%s%s%s%s%d%s%d", host, strings.TrimPrefix(COLUMN_NAMES_APPEND, "&"), criteria, START_DATE_FLD_APPEND, startdate, END_DATE_FLD_APPEND, enddate, OFFSET_FLD_APPEND, offset, LIMIT_FLD_APPEND, limit)
 	return http.NewRequestWithContext(ctx, http.MethodGet, u.Host, nil)
 }
 
@@ -0,0 +1,279 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ilmt
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	standalone = "standalone"
+	bundled    = "bundled"
+	parent     = "parent"
+)
+
+func criteriaKind(r *http.Request) string {
+	criteria := strings.Join(r.URL.Query()["criteria"], "")
+
+	switch {
+	case strings.Contains(criteria, "'bundle_type','=','-1'"):
+		return standalone
+	case strings.Contains(criteria, "'bundle_id','>','0'"):
+		return bundled
+	default:
+		return parent
+	}
+}
+
+var _ = Describe("ilmt client", func() {
+	var (
+		server *ghttp.Server
+		sut    Client
+
+		mu       sync.Mutex
+		requests map[string]int
+		respond  func(kind, date string, attempt, offset int) (int, string)
+
+		backoff  wait.Backoff
+		pageSize int
+	)
+
+	BeforeEach(func() {
+		backoff, pageSize = DefaultBackoff, PageSize
+		DefaultBackoff = wait.Backoff{Steps: 3, Duration: time.Millisecond, Factor: 1}
+
+		requests = map[string]int{}
+		respond = func(kind, date string, attempt, offset int) (int, string) {
+			switch kind {
+			case standalone:
+				return http.StatusOK, `{"total":1,"rows":[{"product_id":1,"product_name":"product","metric_code_name":"PVU","hwm_quantity":10}]}`
+			case bundled:
+				return http.StatusOK, `{"total":1,"rows":[{"product_id":2,"product_name":"child","metric_code_name":"PVU","hwm_quantity":5,"bundle_id":7}]}`
+			default:
+				return http.StatusOK, `{"total":1,"rows":[{"product_id":3,"product_name":"parent","metric_code_name":"VPC","flex_id":7}]}`
+			}
+		}
+
+		server = ghttp.NewTLSServer()
+		server.RouteToHandler("GET", "/api/sam/v2/license_usage", func(w http.ResponseWriter, r *http.Request) {
+			kind, date := criteriaKind(r), r.URL.Query().Get("startdate")
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+			Expect(r.Header.Get("token")).To(Equal("ilmt-secret-token"))
+			Expect(r.URL.RawQuery).ToNot(ContainSubstring("token"))
+			Expect(r.URL.Query().Get("limit")).To(Equal(strconv.Itoa(PageSize)))
+
+			mu.Lock()
+			requests[kind+date] = requests[kind+date] + 1
+			attempt := requests[kind+date]
+			mu.Unlock()
+
+			code, body := respond(kind, date, attempt, offset)
+			w.WriteHeader(code)
+			fmt.Fprint(w, body)
+		})
+
+		caCertPool, _ := x509.SystemCertPool()
+		caCertPool.AddCert(server.HTTPTestServer.Certificate())
+
+		var err error
+		sut, err = NewClient(&IlmtConfig{
+			Host:      server.URL(),
+			Token:     "ilmt-secret-token",
+			TlsConfig: &tls.Config{RootCAs: caCertPool},
+		})
+		Expect(err).To(Succeed())
+	})
+
+	AfterEach(func() {
+		DefaultBackoff, PageSize = backoff, pageSize
+		server.Close()
+	})
+
+	It("should transform the usage of each day", func() {
+		count, data, err := sut.FetchUsageData(context.Background(), DateRange{StartDate: "2022-06-17", EndDate: "2022-06-18"})
+		Expect(err).To(Succeed())
+		Expect(count).To(Equal(2))
+		Expect(strings.Count(data, `"eventId"`)).To(Equal(4))
+		Expect(data).To(ContainSubstring(`"parentProductName":"parent"`))
+		Expect(data).To(ContainSubstring(`"productId":"1"`))
+	})
+
+	It("should return a report per day", func() {
+		respond = func(kind, date string, attempt, offset int) (int, string) {
+			if date == "2022-06-18" {
+				return http.StatusBadRequest, "bad request"
+			}
+
+			if kind == standalone {
+				return http.StatusOK, `{"total":1,"rows":[{"product_id":1,"product_name":"product","metric_code_name":"PVU","hwm_quantity":10}]}`
+			}
+
+			return http.StatusOK, `{"total":0,"rows":[]}`
+		}
+
+		days, err := sut.FetchDailyUsageData(context.Background(), DateRange{StartDate: "2022-06-17", EndDate: "2022-06-19"})
+
+		var partial *PartialError
+		Expect(errors.As(err, &partial)).To(BeTrue())
+		Expect(partial.FirstFailedDate()).To(Equal("2022-06-18"))
+
+		Expect(days).To(HaveLen(2))
+		Expect(days[0].Date).To(Equal("2022-06-17"))
+		Expect(days[1].Date).To(Equal("2022-06-19"))
+		Expect(strings.Count(days[0].Data, `"eventId"`)).To(Equal(1))
+		Expect(days[0].Data).To(ContainSubstring(`"productId":"1"`))
+	})
+
+	It("should retry server errors", func() {
+		respond = func(kind, date string, attempt, offset int) (int, string) {
+			if attempt == 1 {
+				return http.StatusServiceUnavailable, "unavailable"
+			}
+
+			return http.StatusOK, `{"total":0,"rows":[]}`
+		}
+
+		count, _, err := sut.FetchUsageData(context.Background(), DateRange{StartDate: "2022-06-17", EndDate: "2022-06-17"})
+		Expect(err).To(Succeed())
+		Expect(count).To(Equal(1))
+		Expect(requests[standalone+"2022-06-17"]).To(Equal(2))
+	})
+
+	It("should report the days that failed and keep the days before them", func() {
+		respond = func(kind, date string, attempt, offset int) (int, string) {
+			if date == "2022-06-18" && kind == standalone {
+				return http.StatusOK, `{"total":`
+			}
+
+			if kind == standalone {
+				return http.StatusOK, `{"total":1,"rows":[{"product_id":1,"product_name":"product","metric_code_name":"PVU","hwm_quantity":10}]}`
+			}
+
+			return http.StatusOK, `{"total":0,"rows":[]}`
+		}
+
+		count, data, err := sut.FetchUsageData(context.Background(), DateRange{StartDate: "2022-06-17", EndDate: "2022-06-19"})
+		Expect(count).To(Equal(1))
+		Expect(strings.Count(data, `"eventId"`)).To(Equal(1))
+
+		var partial *PartialError
+		Expect(errors.As(err, &partial)).To(BeTrue())
+		Expect(partial.Stopped).To(BeFalse())
+		Expect(partial.FirstFailedDate()).To(Equal("2022-06-18"))
+		Expect(partial.Failed).To(HaveLen(1))
+		Expect(errors.Is(partial.Failed[0].Err, DecodeError)).To(BeTrue())
+		Expect(requests[standalone+"2022-06-18"]).To(Equal(1))
+	})
+
+	It("should report a missing parent product as a failed day", func() {
+		respond = func(kind, date string, attempt, offset int) (int, string) {
+			if kind == bundled {
+				return http.StatusOK, `{"total":1,"rows":[{"product_id":2,"bundle_id":8}]}`
+			}
+
+			return http.StatusOK, `{"total":0,"rows":[]}`
+		}
+
+		count, _, err := sut.FetchUsageData(context.Background(), DateRange{StartDate: "2022-06-17", EndDate: "2022-06-17"})
+		Expect(count).To(Equal(0))
+
+		var partial *PartialError
+		Expect(errors.As(err, &partial)).To(BeTrue())
+		Expect(partial.Failed[0].Err).To(MatchError(ContainSubstring("Parent product not found")))
+	})
+
+	It("should stop on authentication errors", func() {
+		respond = func(kind, date string, attempt, offset int) (int, string) {
+			return http.StatusUnauthorized, "unauthorized"
+		}
+
+		count, _, err := sut.FetchUsageData(context.Background(), DateRange{StartDate: "2022-06-17", EndDate: "2022-06-19"})
+		Expect(count).To(Equal(0))
+
+		var partial *PartialError
+		Expect(errors.As(err, &partial)).To(BeTrue())
+		Expect(partial.Stopped).To(BeTrue())
+		Expect(partial.Failed).To(HaveLen(1))
+		Expect(errors.Is(partial.Failed[0].Err, AuthError)).To(BeTrue())
+		Expect(requests).To(HaveLen(1))
+		Expect(err.Error()).ToNot(ContainSubstring("ilmt-secret-token"))
+	})
+
+	Describe("paging", func() {
+		rows := func(offset, count int) string {
+			r := []string{}
+			for i := offset; i < offset+count; i++ {
+				r = append(r, fmt.Sprintf(`{"product_id":%d,"product_name":"product","metric_code_name":"PVU","hwm_quantity":1}`, i))
+			}
+			return strings.Join(r, ",")
+		}
+
+		BeforeEach(func() {
+			PageSize = 2
+		})
+
+		It("should read every page of a day", func() {
+			respond = func(kind, date string, attempt, offset int) (int, string) {
+				if kind != standalone {
+					return http.StatusOK, `{"total":0,"rows":[]}`
+				}
+
+				count := 5 - offset
+				if count > PageSize {
+					count = PageSize
+				}
+
+				return http.StatusOK, fmt.Sprintf(`{"total":5,"rows":[%s]}`, rows(offset, count))
+			}
+
+			count, data, err := sut.FetchUsageData(context.Background(), DateRange{StartDate: "2022-06-17", EndDate: "2022-06-17"})
+			Expect(err).To(Succeed())
+			Expect(count).To(Equal(1))
+			Expect(requests[standalone+"2022-06-17"]).To(Equal(3))
+			Expect(strings.Count(data, `"eventId"`)).To(Equal(5))
+			Expect(data).To(ContainSubstring(`"productId":"4"`))
+		})
+
+		It("should fail a day with fewer rows than its total", func() {
+			respond = func(kind, date string, attempt, offset int) (int, string) {
+				if kind != standalone || offset > 0 {
+					return http.StatusOK, `{"total":3,"rows":[]}`
+				}
+
+				return http.StatusOK, fmt.Sprintf(`{"total":3,"rows":[%s]}`, rows(0, 2))
+			}
+
+			_, _, err := sut.FetchUsageData(context.Background(), DateRange{StartDate: "2022-06-17", EndDate: "2022-06-17"})
+
+			var partial *PartialError
+			Expect(errors.As(err, &partial)).To(BeTrue())
+			Expect(errors.Is(partial.Failed[0].Err, TotalMismatchError)).To(BeTrue())
+		})
+	})
+})
@@ -14,13 +14,17 @@
 
 package ilmt
 
+import "encoding/json"
+
 const (
 	COLUMN_NAMES_APPEND        string = "&columns[]=product_id&columns[]=product_name&columns[]=metric_code_name&columns[]=bundle_id&columns[]=flex_id&columns[]=bundle_type&columns[]=bundle_name&columns[]=hwm_quantity&columns[]=bundle_metric_contribution&columns[]=product_bundle_ratio_factor&columns[]=product_bundle_ratio_divider"
-	CRITERIA_STANDALONE        string = "&limit=2&criteria={'and':[['bundle_id','<=','0']]}&criteria={'and':[['bundle_type','=','-1']]}"
+	CRITERIA_STANDALONE        string = "&criteria={'and':[['bundle_id','<=','0']]}&criteria={'and':[['bundle_type','=','-1']]}"
 	CRITEIRA_PRODUCTPARTOFBNDL string = "&criteria={'and':[['bundle_id','>','0']]}"
 	CRITERIA_PARENTPRODUCT     string = "&criteria={'and':[['bundle_type','>','-1']]}"
 	START_DATE_FLD_APPEND      string = "&startdate="
 	END_DATE_FLD_APPEND        string = "&enddate="
+	LIMIT_FLD_APPEND           string = "&limit="
+	OFFSET_FLD_APPEND          string = "&offset="
 	EMPTY                      string = ""
 )
 
@@ -29,6 +33,21 @@ type DateRange struct {
 	EndDate   string
 }
 
+// licenseUsagePage is a page of a license_usage response. Its rows are
+// decoded once all pages are read.
+type licenseUsagePage struct {
+	TotalRows int               `json:"total"`
+	Rows      []json.RawMessage `json:"rows"`
+}
+
+// DailyUsage is the report of the usage of a single day.
+type DailyUsage struct {
+	Date string
+	Data string
+
+	events []ProductUsageTransformedEventData
+}
+
 type StandaloneProductResp struct {
 	TotalRows                     int                             `json:"total"`
 	StandaloneProductLicenceUsage []StandaloneProductLicenceUsage `json:"rows"`
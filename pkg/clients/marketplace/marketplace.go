@@ -28,6 +28,10 @@ type MarketplaceConfig struct {
 
 	TlsConfig *tls.Config
 
+	// RetryPolicy is how the requests are retried. DefaultRetryPolicy is used
+	// for the fields that are not set.
+	RetryPolicy *RetryPolicy `json:"-"`
+
 	polling time.Duration `json:"-"`
 	timeout time.Duration `json:"-"`
 }
@@ -40,6 +44,8 @@ type marketplaceClient struct {
 	RoundTripperConfig *shared.RoundTripperConfig
 
 	metricClient *marketplaceMetricClient
+
+	retryPolicy RetryPolicy
 }
 
 type Client interface {
@@ -65,6 +71,7 @@ func NewClient(config *MarketplaceConfig) (Client, error) {
 	cli := &marketplaceClient{
 		Client:            client,
 		MarketplaceConfig: config,
+		retryPolicy:       config.RetryPolicy.withDefaults(),
 	}
 
 	cli.metricClient = &marketplaceMetricClient{client: cli}
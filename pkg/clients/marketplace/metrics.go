@@ -24,13 +24,13 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
+	"os"
 	"path/filepath"
 	"time"
 
 	"emperror.dev/errors"
 	"github.com/go-logr/logr"
-	"k8s.io/apimachinery/pkg/util/wait"
-	"k8s.io/client-go/util/retry"
+	"github.com/redhat-marketplace/datactl/pkg/clients/shared"
 	"k8s.io/klog/v2/klogr"
 )
 
@@ -65,6 +65,11 @@ const (
 	marketplaceMetricsStatus = marketplaceMetricsPath + "/%s"
 )
 
+// IsFinal returns true if the upload will not change status anymore.
+func (s MktplStatus) IsFinal() bool {
+	return s == MktplStatusSuccess || s == MktplStatusFailed
+}
+
 func (s *marketplaceClient) Metrics() MarketplaceMetrics {
 	return s.metricClient
 }
@@ -72,6 +77,7 @@ func (s *marketplaceClient) Metrics() MarketplaceMetrics {
 type MarketplaceMetrics interface {
 	Status(ctx context.Context, id string) (*MarketplaceUsageResponse, error)
 	Upload(ctx context.Context, fileName string, reader io.Reader) (id string, err error)
+	Wait(ctx context.Context, id string) (*MarketplaceUsageResponse, error)
 }
 
 type marketplaceMetricClient struct {
@@ -79,11 +85,65 @@ type marketplaceMetricClient struct {
 }
 
 func (r *marketplaceMetricClient) Status(ctx context.Context, id string) (*MarketplaceUsageResponse, error) {
+	var status *MarketplaceUsageResponse
+
+	err := r.client.retryPolicy.retry(ctx, "status", func() error {
+		var err error
+		status, err = r.status(ctx, id)
+		return err
+	})
+
+	return status, err
+}
+
+// Wait polls the status of an upload at the polling interval of the client
+// until it is final. It gives up after the timeout of the client and returns
+// the last status.
+func (r *marketplaceMetricClient) Wait(ctx context.Context, id string) (*MarketplaceUsageResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.client.timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(r.client.polling)
+	defer ticker.Stop()
+
+	last := &MarketplaceUsageResponse{Status: MktplStatusInProgress}
+
+	for {
+		status, err := r.Status(ctx, id)
+		if err != nil {
+			// the request in flight when the wait times out may fail first
+			if ctx.Err() != nil || errors.Is(err, context.DeadlineExceeded) {
+				return last, errors.WrapWithDetails(context.DeadlineExceeded, "upload still processing", "id", id, "status", last.Status)
+			}
+			return status, err
+		}
+
+		if status.Status.IsFinal() {
+			return status, nil
+		}
+
+		last = status
+		logger.Info("waiting for upload to be processed", "id", id, "status", status.Status)
+
+		select {
+		case <-ctx.Done():
+			return last, errors.WrapWithDetails(ctx.Err(), "upload still processing", "id", id, "status", last.Status)
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *marketplaceMetricClient) status(ctx context.Context, id string) (*MarketplaceUsageResponse, error) {
 	status := MarketplaceUsageResponse{}
 	status.Details = &MarketplaceUsageResponseDetails{}
 
 	url := fmt.Sprintf(marketplaceMetricsStatus, r.client.URL, id)
-	resp, err := r.client.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return &status, err
+	}
+
+	resp, err := r.client.Do(req)
 	if err != nil {
 		return &status, err
 	}
@@ -113,20 +173,13 @@ const RetryableError = errors.Sentinel("retryable")
 
 const DuplicateError = errors.Sentinel("duplicate")
 
-func isRetryable(err error) bool {
-	if errors.Is(err, RetryableError) {
-		return true
-	}
-	return false
-}
-
 func checkError(resp *http.Response, body string, status MarketplaceUsageResponse, message string) error {
 	logger.Info("retrieved response",
 		"statusCode", resp.StatusCode,
 		"proto", resp.Proto,
 		"status", status,
-		"headers", resp.Header,
-		"body", body,
+		"headers", shared.RedactHeader(resp.Header),
+		"body", shared.Redact(body),
 	)
 
 	/*
@@ -147,16 +200,21 @@ func checkError(resp *http.Response, body string, status MarketplaceUsageRespons
 	}
 	// return status says retrybale
 	if status.Details != nil && status.Details.Retryable {
-		return errors.WrapWithDetails(RetryableError, "retryable error", append(errors.GetDetails(err), "message", err.Error())...)
+		err = errors.WrapWithDetails(RetryableError, "retryable error", append(errors.GetDetails(err), "message", err.Error())...)
 	}
 
-	return err
+	return &StatusError{
+		error:      err,
+		StatusCode: resp.StatusCode,
+		RetryAfter: retryAfter(resp.Header, time.Now()),
+	}
 }
 
-func (r *marketplaceMetricClient) makeFormFile(fileName string, file []byte) (form []byte, formContent string, err error) {
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-
+// multipartBody returns a multipart body with the file as its only part, and
+// its length. The file is read as the request is sent, so it's never held in
+// memory, and the length lets the request go out with a Content-Length
+// instead of a chunked body.
+func multipartBody(fileName string, file io.Reader, size int64) (body io.Reader, length int64, formContent string, err error) {
 	fileBase := filepath.Base(fileName)
 	fileExt := filepath.Ext(fileBase)
 	fileWithoutExt := fileBase[:len(fileBase)-len(fileExt)]
@@ -166,36 +224,43 @@ func (r *marketplaceMetricClient) makeFormFile(fileName string, file []byte) (fo
 		fmt.Sprintf(`form-data; name="%s"; filename="%s"`, fileWithoutExt, fileBase))
 	h.Set("Content-Type", "application/gzip")
 
-	var part io.Writer
-	part, err = writer.CreatePart(h)
+	// the writer only writes the boundaries and part headers to the buffer,
+	// the file is read between them
+	buf := &bytes.Buffer{}
+	writer := multipart.NewWriter(buf)
 
-	if err != nil {
-		return
+	if _, err := writer.CreatePart(h); err != nil {
+		return nil, 0, "", err
 	}
 
-	_, err = io.Copy(part, bytes.NewReader(file))
-	if err != nil {
-		return
-	}
+	head := append([]byte{}, buf.Bytes()...)
+	buf.Reset()
 
-	err = writer.Close()
-	if err != nil {
-		return
+	if err := writer.Close(); err != nil {
+		return nil, 0, "", err
 	}
 
-	form = body.Bytes()
-	formContent = writer.FormDataContentType()
-	err = nil
-	return
+	tail := buf.Bytes()
+
+	body = io.MultiReader(bytes.NewReader(head), io.LimitReader(file, size), bytes.NewReader(tail))
+	length = int64(len(head)) + size + int64(len(tail))
+
+	return body, length, writer.FormDataContentType(), nil
 }
 
-func (r *marketplaceMetricClient) uploadFile(ctx context.Context, form []byte, formContent string) (id string, err error) {
+func (r *marketplaceMetricClient) uploadFile(ctx context.Context, fileName string, file io.Reader, size int64) (id string, err error) {
+	form, length, formContent, err := multipartBody(fileName, file, size)
+	if err != nil {
+		return "", err
+	}
+
 	var req *http.Request
-	req, err = http.NewRequestWithContext(ctx, "POST", fmt.Sprintf(marketplaceMetricsPath, r.client.URL), bytes.NewReader(form))
+	req, err = http.NewRequestWithContext(ctx, "POST", fmt.Sprintf(marketplaceMetricsPath, r.client.URL), form)
 	if err != nil {
 		return "", err
 	}
 	req.Header.Set("Content-Type", formContent)
+	req.ContentLength = length
 
 	// Perform the request
 	resp, err := r.client.Do(req)
@@ -225,61 +290,104 @@ func (r *marketplaceMetricClient) uploadFile(ctx context.Context, form []byte, f
 	return status.RequestID, err
 }
 
-var DefaultBackoff = wait.Backoff{
-	Steps:    4,
-	Duration: 50 * time.Millisecond,
-	Factor:   5.0,
-	Jitter:   0.1,
-}
-
+// Upload uploads a file, retrying with the retry policy of the client. The
+// timeout of the client applies to each attempt.
 func (r *marketplaceMetricClient) Upload(ctx context.Context, fileName string, reader io.Reader) (id string, err error) {
-	file, ferr := io.ReadAll(reader)
-	if ferr != nil {
-		err = ferr
-		return
-	}
+	file := newRewindableReader(reader)
+	defer file.Close()
 
-	body, content, berr := r.makeFormFile(fileName, file)
-	if berr != nil {
-		err = ferr
-		return
-	}
+	err = r.client.retryPolicy.retry(ctx, "upload", func() error {
+		body, size, localErr := file.Rewind()
+		if localErr != nil {
+			return errors.Wrap(localErr, "failed to rewind file")
+		}
 
-	if err != nil {
-		return "", err
+		attemptCtx, cancel := context.WithTimeout(ctx, r.client.timeout)
+		defer cancel()
+
+		localID, localErr := r.uploadFile(attemptCtx, fileName, body, size)
+		if localErr != nil {
+			return errors.Wrap(localErr, "failed to get upload file req")
+		}
+
+		id = localID
+		return nil
+	})
+
+	if errors.Is(err, DuplicateError) {
+		return "", nil
 	}
-	done := make(chan struct{})
 
-	ctx, cancel := context.WithTimeout(ctx, r.client.timeout)
-	defer cancel()
+	return id, err
+}
 
-	ticker := time.NewTicker(r.client.polling)
-	defer ticker.Stop()
+// rewindableReader lets an upload be retried without holding the file in
+// memory. A seekable reader is rewound in place. Any other reader is copied
+// into a spool file on the first attempt, so its size is known before it's
+// sent, and every attempt reads from the spool.
+type rewindableReader struct {
+	reader io.Reader
+	seeker io.ReadSeeker
+	start  int64
+	spool  *os.File
+	size   int64
+}
 
-	go func() {
-		defer close(done)
+func newRewindableReader(reader io.Reader) *rewindableReader {
+	r := &rewindableReader{reader: reader}
 
-		err = retry.OnError(DefaultBackoff, isRetryable, func() error {
-			localID, localErr := r.uploadFile(ctx, body, content)
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err == nil {
+			r.seeker = seeker
+			r.start = start
+		}
+	}
 
-			if localErr != nil {
-				return errors.Wrap(localErr, "failed to get upload file req")
-			}
+	return r
+}
 
-			id = localID
-			return nil
-		})
+// Rewind returns a reader positioned at the start of the file and the size of
+// the file.
+func (r *rewindableReader) Rewind() (io.Reader, int64, error) {
+	if r.seeker != nil {
+		end, err := r.seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, 0, err
+		}
 
+		_, err = r.seeker.Seek(r.start, io.SeekStart)
+		return r.seeker, end - r.start, err
+	}
+
+	if r.spool == nil {
+		spool, err := os.CreateTemp("", "datactl-upload-*")
 		if err != nil {
-			if errors.Is(err, DuplicateError) {
-				err = nil
-				id = ""
-			}
+			return nil, 0, err
+		}
+
+		r.spool = spool
 
-			return
+		r.size, err = io.Copy(r.spool, r.reader)
+		if err != nil {
+			return nil, 0, err
 		}
-	}()
+	}
+
+	if _, err := r.spool.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+
+	return r.spool, r.size, nil
+}
 
-	<-done
-	return
+func (r *rewindableReader) Close() error {
+	if r.spool == nil {
+		return nil
+	}
+
+	err := r.spool.Close()
+	os.Remove(r.spool.Name())
+	r.spool = nil
+	return err
 }
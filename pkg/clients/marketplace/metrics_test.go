@@ -19,6 +19,7 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"io"
 	"net/http"
 	"time"
@@ -58,6 +59,11 @@ var _ = Describe("marketplace uploaders", func() {
 			TlsConfig: &tls.Config{
 				RootCAs: caCertPool,
 			},
+			RetryPolicy: &RetryPolicy{
+				MaxAttempts: 4,
+				BaseDelay:   time.Millisecond,
+				MaxDelay:    10 * time.Millisecond,
+			},
 			polling: 1 * time.Second,
 			timeout: 4 * time.Second,
 		}
@@ -110,6 +116,36 @@ var _ = Describe("marketplace uploaders", func() {
 			Expect(err).ToNot(HaveOccurred())
 			Expect(id).To(Equal(testId))
 		})
+
+		It("should wait for the upload to be processed", func() {
+			ctx := context.Background()
+			id, err := sut.Metrics().Upload(ctx, fileName, bytes.NewReader(testBody))
+			Expect(err).ToNot(HaveOccurred())
+
+			status, err := sut.Metrics().Wait(ctx, id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(status.Status).To(Equal(MktplStatusSuccess))
+			Expect(server.ReceivedRequests()).To(HaveLen(3))
+		})
+	})
+
+	Describe("waiting for uploads", func() {
+		BeforeEach(func() {
+			config.polling = 10 * time.Millisecond
+			config.timeout = 500 * time.Millisecond
+			sut, err = NewClient(config)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			server.RouteToHandler("GET", "/metering/api/v2/metrics/"+testId,
+				ghttp.RespondWithJSONEncoded(http.StatusOK, &getResponse),
+			)
+		})
+
+		It("should give up after the timeout", func() {
+			status, err := sut.Metrics().Wait(context.Background(), testId)
+			Expect(err).To(MatchError(ContainSubstring("upload still processing")))
+			Expect(status.Status).To(Equal(MktplStatusInProgress))
+		})
 	})
 
 	Describe("uploading files", func() {
@@ -145,6 +181,14 @@ var _ = Describe("marketplace uploaders", func() {
 			Expect(err).ToNot(HaveOccurred())
 			Expect(id).To(Equal(testId))
 		})
+
+		It("should upload a stream and retry from the spool", func() {
+			ctx := context.Background()
+			stream := struct{ io.Reader }{bytes.NewReader(testBody)}
+			id, err := sut.Metrics().Upload(ctx, fileName, stream)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(id).To(Equal(testId))
+		})
 	})
 
 	Describe("uploading files", func() {
@@ -172,6 +216,51 @@ var _ = Describe("marketplace uploaders", func() {
 		})
 	})
 
+	Describe("handling throttling", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/metering/api/v2/metrics"),
+					ghttp.RespondWith(http.StatusTooManyRequests, `{"message":"too many requests"}`, http.Header{"Retry-After": []string{"1"}}),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/metering/api/v2/metrics"),
+					ghttp.RespondWith(http.StatusServiceUnavailable, `{"message":"unavailable"}`),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/metering/api/v2/metrics"),
+					verifyFileUpload(fileName, testBody),
+					ghttp.RespondWithJSONEncoded(http.StatusOK, &postReponse),
+				),
+			)
+		})
+
+		It("should retry throttled and unavailable responses", func() {
+			// the Retry-After of a second is capped by the max delay
+			start := time.Now()
+			id, err := sut.Metrics().Upload(context.Background(), fileName, bytes.NewReader(testBody))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(id).To(Equal(testId))
+			Expect(server.ReceivedRequests()).To(HaveLen(3))
+			Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		})
+
+		It("should not retry status codes that are not retryable", func() {
+			config.RetryPolicy.RetryableStatusCodes = []int{http.StatusServiceUnavailable}
+			sut, err = NewClient(config)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err := sut.Metrics().Upload(context.Background(), fileName, bytes.NewReader(testBody))
+			Expect(err).To(HaveOccurred())
+
+			var statusErr *StatusError
+			Expect(errors.As(err, &statusErr)).To(BeTrue())
+			Expect(statusErr.StatusCode).To(Equal(http.StatusTooManyRequests))
+			Expect(statusErr.RetryAfter).To(Equal(time.Second))
+			Expect(server.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
+
 	Describe("handling conflict", func() {
 		BeforeEach(func() {
 			sut, err = NewClient(config)
@@ -217,12 +306,79 @@ var _ = Describe("marketplace uploaders", func() {
 	})
 })
 
+var _ = Describe("rewindableReader", func() {
+	It("should replay a partially read stream", func() {
+		r := newRewindableReader(struct{ io.Reader }{bytes.NewReader([]byte("foobar"))})
+		defer r.Close()
+
+		first, size, err := r.Rewind()
+		Expect(size).To(Equal(int64(6)))
+		Expect(err).To(Succeed())
+
+		buf := make([]byte, 2)
+		_, err = io.ReadFull(first, buf)
+		Expect(err).To(Succeed())
+		Expect(string(buf)).To(Equal("fo"))
+
+		for i := 0; i < 2; i++ {
+			again, size, err := r.Rewind()
+			Expect(err).To(Succeed())
+			Expect(size).To(Equal(int64(6)))
+
+			data, err := io.ReadAll(again)
+			Expect(err).To(Succeed())
+			Expect(string(data)).To(Equal("foobar"))
+		}
+	})
+
+	It("should rewind after a partial read of a seekable reader", func() {
+		r := newRewindableReader(bytes.NewReader([]byte("foobar")))
+		defer r.Close()
+
+		first, _, err := r.Rewind()
+		Expect(err).To(Succeed())
+		io.ReadFull(first, make([]byte, 4))
+
+		again, size, err := r.Rewind()
+		Expect(err).To(Succeed())
+		Expect(size).To(Equal(int64(6)))
+
+		data, err := io.ReadAll(again)
+		Expect(err).To(Succeed())
+		Expect(string(data)).To(Equal("foobar"))
+	})
+
+	It("should seek a seekable reader back to where it started", func() {
+		reader := bytes.NewReader([]byte("foobar"))
+		reader.Seek(3, io.SeekStart)
+
+		r := newRewindableReader(reader)
+		defer r.Close()
+
+		for i := 0; i < 2; i++ {
+			again, size, err := r.Rewind()
+			Expect(err).To(Succeed())
+			Expect(size).To(Equal(int64(3)))
+
+			data, err := io.ReadAll(again)
+			Expect(err).To(Succeed())
+			Expect(string(data)).To(Equal("bar"))
+		}
+	})
+})
+
 func verifyFileUpload(fileName string, testBody []byte) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		Expect(req.Header.Get("Content-Type")).To(ContainSubstring("multipart/form-data"))
 		Expect(req.Header.Get("Authorization")).To(Equal("Bearer foo"))
+		Expect(req.TransferEncoding).To(BeEmpty())
+
+		body, err := io.ReadAll(req.Body)
+		Expect(err).To(Succeed())
+		Expect(req.ContentLength).To(Equal(int64(len(body))))
+		req.Body = io.NopCloser(bytes.NewReader(body))
 
-		err := req.ParseMultipartForm(32 << 20) // maxMemory 32 MB
+		err = req.ParseMultipartForm(32 << 20) // maxMemory 32 MB
 		Expect(err).To(Succeed())
 
 		file, _, err := req.FormFile(fileName)
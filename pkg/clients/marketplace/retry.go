@@ -0,0 +1,185 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package marketplace
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"emperror.dev/errors"
+)
+
+// RetryPolicy is how the requests to the marketplace are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts of a request, including the first.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry. It doubles with each
+	// retry.
+	BaseDelay time.Duration
+
+	// MaxDelay caps the delay between two attempts, including the delay asked
+	// for by a Retry-After header.
+	MaxDelay time.Duration
+
+	// RetryableStatusCodes are the response status codes that are retried.
+	// Responses that say they are retryable are retried whatever their code.
+	RetryableStatusCodes []int
+}
+
+// DefaultRetryPolicy retries throttled and unavailable responses for about
+// two minutes.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 6,
+	BaseDelay:   2 * time.Second,
+	MaxDelay:    time.Minute,
+	RetryableStatusCodes: []int{
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+}
+
+// withDefaults returns the policy with the fields that are not set taken from
+// DefaultRetryPolicy.
+func (p *RetryPolicy) withDefaults() RetryPolicy {
+	policy := DefaultRetryPolicy
+	if p == nil {
+		return policy
+	}
+
+	if p.MaxAttempts > 0 {
+		policy.MaxAttempts = p.MaxAttempts
+	}
+
+	if p.BaseDelay > 0 {
+		policy.BaseDelay = p.BaseDelay
+	}
+
+	if p.MaxDelay > 0 {
+		policy.MaxDelay = p.MaxDelay
+	}
+
+	if p.RetryableStatusCodes != nil {
+		policy.RetryableStatusCodes = p.RetryableStatusCodes
+	}
+
+	return policy
+}
+
+// delay returns how long to wait before retrying a request that failed with
+// err on the attempt given, and false if it must not be retried.
+func (p RetryPolicy) delay(attempt int, err error) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts || !p.retryable(err) {
+		return 0, false
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay = delay * 2
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		delay = statusErr.RetryAfter
+	}
+
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	return delay, true
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if errors.Is(err, RetryableError) {
+		return true
+	}
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+
+	for _, code := range p.RetryableStatusCodes {
+		if statusErr.StatusCode == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// retry calls f until it succeeds, fails with an error the policy doesn't
+// retry, or the attempts run out. Each retry is logged with its delay.
+func (p RetryPolicy) retry(ctx context.Context, request string, f func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil {
+			return nil
+		}
+
+		delay, ok := p.delay(attempt, err)
+		if !ok {
+			return err
+		}
+
+		logger.Info("retrying request", "request", request, "attempt", attempt, "delay", delay.String(), "err", err.Error())
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Combine(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// StatusError is the error of a response that is not successful.
+type StatusError struct {
+	error
+
+	StatusCode int
+
+	// RetryAfter is the delay asked for by the Retry-After header of the
+	// response, if any.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.error
+}
+
+// retryAfter parses a Retry-After header, given in seconds or as an HTTP date.
+func retryAfter(header http.Header, now time.Time) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
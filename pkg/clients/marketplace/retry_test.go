@@ -0,0 +1,78 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package marketplace
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RetryPolicy", func() {
+	policy := RetryPolicy{
+		MaxAttempts:          4,
+		BaseDelay:            time.Second,
+		MaxDelay:             3 * time.Second,
+		RetryableStatusCodes: []int{http.StatusTooManyRequests},
+	}
+
+	throttled := &StatusError{error: errors.New("throttled"), StatusCode: http.StatusTooManyRequests}
+
+	It("should double the delay up to the max", func() {
+		for attempt, expected := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 3 * time.Second} {
+			delay, ok := policy.delay(attempt, throttled)
+			Expect(ok).To(BeTrue())
+			Expect(delay).To(Equal(expected))
+		}
+
+		_, ok := policy.delay(4, throttled)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should wait for the Retry-After of the response", func() {
+		delay, ok := policy.delay(1, &StatusError{error: throttled, StatusCode: http.StatusTooManyRequests, RetryAfter: 2 * time.Second})
+		Expect(ok).To(BeTrue())
+		Expect(delay).To(Equal(2 * time.Second))
+	})
+
+	It("should retry the responses that say they are retryable", func() {
+		_, ok := policy.delay(1, &StatusError{error: RetryableError, StatusCode: http.StatusInternalServerError})
+		Expect(ok).To(BeTrue())
+
+		_, ok = policy.delay(1, &StatusError{error: errors.New("failed"), StatusCode: http.StatusInternalServerError})
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should fill the fields that are not set with the defaults", func() {
+		p := (&RetryPolicy{MaxAttempts: 2}).withDefaults()
+		Expect(p.MaxAttempts).To(Equal(2))
+		Expect(p.BaseDelay).To(Equal(DefaultRetryPolicy.BaseDelay))
+		Expect(p.RetryableStatusCodes).To(Equal(DefaultRetryPolicy.RetryableStatusCodes))
+
+		Expect((*RetryPolicy)(nil).withDefaults()).To(Equal(DefaultRetryPolicy))
+	})
+
+	It("should parse the Retry-After header", func() {
+		now := time.Date(2021, time.October, 15, 10, 0, 0, 0, time.UTC)
+
+		Expect(retryAfter(http.Header{"Retry-After": []string{"120"}}, now)).To(Equal(2 * time.Minute))
+		Expect(retryAfter(http.Header{"Retry-After": []string{now.Add(time.Minute).Format(http.TimeFormat)}}, now)).To(Equal(time.Minute))
+		Expect(retryAfter(http.Header{"Retry-After": []string{"soon"}}, now)).To(BeZero())
+		Expect(retryAfter(http.Header{}, now)).To(BeZero())
+	})
+})
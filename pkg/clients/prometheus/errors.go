@@ -0,0 +1,60 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"net/http"
+
+	"emperror.dev/errors"
+)
+
+// Errors returned by the Prometheus client. Use errors.Is to check for them.
+const (
+	// AuthError is returned when the token is rejected.
+	AuthError = errors.Sentinel("prometheus authentication failed")
+
+	// QueryError is returned when a query is rejected or fails to run.
+	QueryError = errors.Sentinel("prometheus query failed")
+
+	// ServerError is returned when the server fails to serve a request or
+	// asks the client to slow down. It is retried.
+	ServerError = errors.Sentinel("prometheus server error")
+
+	// DecodeError is returned when a response can't be read.
+	DecodeError = errors.Sentinel("prometheus response can't be decoded")
+
+	// RetryableError is returned when a request fails before the server
+	// responds.
+	RetryableError = errors.Sentinel("retryable")
+)
+
+func isRetryable(err error) bool {
+	return errors.Is(err, ServerError) || errors.Is(err, RetryableError)
+}
+
+// checkStatus returns the error of a response. Bad queries are answered with
+// 400 or 422 and the error in the body.
+func checkStatus(resp *http.Response, body queryResponse) error {
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return errors.WithDetails(AuthError, "code", resp.StatusCode)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 && body.ErrorType != "execution":
+		return errors.WithDetails(ServerError, "code", resp.StatusCode)
+	case body.Status == "error" || resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return errors.WithDetails(QueryError, "code", resp.StatusCode, "errorType", body.ErrorType, "message", body.Error)
+	}
+
+	return nil
+}
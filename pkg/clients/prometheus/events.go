@@ -0,0 +1,140 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"crypto/sha256"
+	b64 "encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UsageReport is the marketplace report of the events, in the format of the
+// ILMT reports.
+type UsageReport struct {
+	Data []Event `json:"data"`
+}
+
+type Event struct {
+	AdditionalAttributes map[string]string `json:"additionalAttributes"`
+	EndDate              int64             `json:"end"`
+	StartDate            int64             `json:"start"`
+	EventId              string            `json:"eventId"`
+	MeasuredUsage        []MeasuredUsage   `json:"measuredUsage"`
+}
+
+type MeasuredUsage struct {
+	MetricId string  `json:"metricId"`
+	Value    float64 `json:"value"`
+}
+
+// NewEvents returns an event per series of a query for a day. The value of an
+// event is the highest sample of the series during the day, and its
+// attributes are the labels of the series.
+func NewEvents(host, metricId string, day time.Time, series []Series) []Event {
+	startMillis := day.UnixMilli()
+	endMillis := day.AddDate(0, 0, 1).UnixMilli() - 1
+
+	events := make([]Event, 0, len(series))
+
+	for _, s := range series {
+		value, ok := highWaterMark(s.Values)
+		if !ok {
+			continue
+		}
+
+		labels := labelString(s.Metric)
+
+		BELL := '\a'
+		eventId := fmt.Sprintf("%d%U%s%U%s%U%s", startMillis, BELL, metricId, BELL, labels, BELL, host)
+		h := sha256.Sum256([]byte(eventId))
+
+		attributes := map[string]string{}
+		for k, v := range s.Metric {
+			if k == "__name__" {
+				continue
+			}
+			attributes[k] = v
+		}
+
+		attributes["hostname"] = host
+		attributes["measuredMetricId"] = metricId
+		attributes["measuredValue"] = strconv.FormatFloat(value, 'f', -1, 64)
+		attributes["metricType"] = "usage"
+		attributes["source"] = "Prometheus"
+
+		events = append(events, Event{
+			StartDate:            startMillis,
+			EndDate:              endMillis,
+			EventId:              "PROMETHEUS-" + b64.StdEncoding.EncodeToString(h[:]),
+			MeasuredUsage:        []MeasuredUsage{{MetricId: metricId, Value: value}},
+			AdditionalAttributes: attributes,
+		})
+	}
+
+	return events
+}
+
+// MarshalReport returns the events in the format of a report.
+func MarshalReport(events []Event) (string, error) {
+	data, err := json.Marshal(UsageReport{Data: events})
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// highWaterMark returns the highest value of the samples, skipping the ones
+// that are not numbers.
+func highWaterMark(samples []Sample) (float64, bool) {
+	found := false
+	max := 0.0
+
+	for _, s := range samples {
+		if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+			continue
+		}
+
+		if !found || s.Value > max {
+			max = s.Value
+			found = true
+		}
+	}
+
+	return max, found
+}
+
+// labelString returns the labels sorted by name, so the id of an event does
+// not depend on the order of the labels in the response.
+func labelString(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+"="+labels[name])
+	}
+
+	return strings.Join(pairs, ",")
+}
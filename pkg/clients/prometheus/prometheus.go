@@ -0,0 +1,294 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/go-logr/logr"
+	"github.com/redhat-marketplace/datactl/pkg/clients/shared"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2/klogr"
+)
+
+const (
+	REQUIRED_FORMAT string = "2006-01-02"
+
+	queryRangePath = "/api/v1/query_range"
+)
+
+var (
+	logger logr.Logger = klogr.New().V(5).WithName("pkg/clients/prometheus")
+)
+
+// DefaultStep is the resolution of the queries when the config has none.
+const DefaultStep = time.Hour
+
+type PrometheusConfig struct {
+	// URL of the Prometheus or Thanos query API.
+	URL   string `json:"url"`
+	Token string `json:"-"`
+
+	// Queries are the PromQL queries run for each day, by the metric id
+	// reported for their results.
+	Queries map[string]string `json:"queries"`
+
+	// Step is the resolution of the queries.
+	Step time.Duration `json:"step,omitempty"`
+
+	TlsConfig *tls.Config
+}
+
+type DateRange struct {
+	StartDate string
+	EndDate   string
+}
+
+type Client interface {
+	// FetchUsageData runs the queries for each day of the range and returns
+	// the count of events and the report of the events.
+	FetchUsageData(ctx context.Context, dateRange DateRange) (int, string, error)
+}
+
+type prometheusClient struct {
+	*http.Client
+	PrometheusConfig
+}
+
+func NewClient(config *PrometheusConfig) (Client, error) {
+	if len(config.Queries) == 0 {
+		return nil, errors.New("no queries configured")
+	}
+
+	if _, err := url.Parse(config.URL); err != nil {
+		return nil, errors.WrapWithDetails(err, "invalid url", "url", config.URL)
+	}
+
+	opts := []shared.RoundTripperOptions{}
+	if config.Token != "" {
+		opts = append(opts, shared.WithBearerAuth(config.Token))
+	}
+
+	client, err := shared.NewHttpClient(config.TlsConfig, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	cli := &prometheusClient{
+		Client:           client,
+		PrometheusConfig: *config,
+	}
+
+	if cli.Step <= 0 {
+		cli.Step = DefaultStep
+	}
+
+	return cli, nil
+}
+
+func (p *prometheusClient) FetchUsageData(ctx context.Context, dateRange DateRange) (int, string, error) {
+	startDate, err := time.Parse(REQUIRED_FORMAT, dateRange.StartDate)
+	if err != nil {
+		return 0, "", errors.WrapWithDetails(err, "invalid start date", "startDate", dateRange.StartDate)
+	}
+
+	endDate, err := time.Parse(REQUIRED_FORMAT, dateRange.EndDate)
+	if err != nil {
+		return 0, "", errors.WrapWithDetails(err, "invalid end date", "endDate", dateRange.EndDate)
+	}
+
+	names := make([]string, 0, len(p.Queries))
+	for name := range p.Queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	host := eventHost(p.URL)
+	events := []Event{}
+
+	for day := startDate; !day.After(endDate); day = day.AddDate(0, 0, 1) {
+		for _, name := range names {
+			series, err := p.queryRange(ctx, p.Queries[name], day, day.AddDate(0, 0, 1).Add(-time.Second))
+			if err != nil {
+				logger.Info("failed to query usage", "date", day.Format(REQUIRED_FORMAT), "query", name, "err", err)
+				return 0, "", errors.WithDetails(err, "date", day.Format(REQUIRED_FORMAT), "query", name)
+			}
+
+			events = append(events, NewEvents(host, name, day, series)...)
+		}
+	}
+
+	report, err := MarshalReport(events)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return len(events), report, nil
+}
+
+var DefaultBackoff = wait.Backoff{
+	Steps:    4,
+	Duration: 50 * time.Millisecond,
+	Factor:   5.0,
+	Jitter:   0.1,
+}
+
+// queryResponse is the response of the query_range API.
+type queryResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Data      struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string    `json:"metric"`
+			Values [][2]json.RawMessage `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// Series is the samples of a query result with the same labels.
+type Series struct {
+	Metric map[string]string
+	Values []Sample
+}
+
+type Sample struct {
+	Time  time.Time
+	Value float64
+}
+
+// queryRange runs a query over a time range. Server and connection errors are
+// retried.
+func (p *prometheusClient) queryRange(ctx context.Context, query string, start, end time.Time) ([]Series, error) {
+	var out []Series
+
+	err := retry.OnError(DefaultBackoff, isRetryable, func() error {
+		req, err := p.newQueryRangeRequest(ctx, query, start, end)
+		if err != nil {
+			return errors.Wrap(err, "failed to build request")
+		}
+
+		resp, err := p.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			return errors.WithDetails(RetryableError, "message", err.Error())
+		}
+
+		defer resp.Body.Close()
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return errors.WithDetails(RetryableError, "message", err.Error())
+		}
+
+		var body queryResponse
+		decodeErr := json.Unmarshal(data, &body)
+
+		if err := checkStatus(resp, body); err != nil {
+			return err
+		}
+
+		if decodeErr != nil {
+			return errors.WithDetails(DecodeError, "message", decodeErr.Error())
+		}
+
+		out, err = toSeries(body)
+		return err
+	})
+
+	return out, err
+}
+
+func (p *prometheusClient) newQueryRangeRequest(ctx context.Context, query string, start, end time.Time) (*http.Request, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", strconv.FormatInt(start.Unix(), 10))
+	params.Set("end", strconv.FormatInt(end.Unix(), 10))
+	params.Set("step", strconv.FormatFloat(p.Step.Seconds(), 'f', -1, 64))
+
+	u := strings.TrimSuffix(p.URL, "/") + queryRangePath
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
+
+func toSeries(body queryResponse) ([]Series, error) {
+	if body.Data.ResultType != "matrix" {
+		return nil, errors.WithDetails(DecodeError, "message", fmt.Sprintf("unexpected result type %q", body.Data.ResultType))
+	}
+
+	out := make([]Series, 0, len(body.Data.Result))
+
+	for _, result := range body.Data.Result {
+		series := Series{Metric: result.Metric, Values: make([]Sample, 0, len(result.Values))}
+
+		for _, value := range result.Values {
+			var ts float64
+			if err := json.Unmarshal(value[0], &ts); err != nil {
+				return nil, errors.WithDetails(DecodeError, "message", err.Error())
+			}
+
+			var s string
+			if err := json.Unmarshal(value[1], &s); err != nil {
+				return nil, errors.WithDetails(DecodeError, "message", err.Error())
+			}
+
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, errors.WithDetails(DecodeError, "message", err.Error())
+			}
+
+			sec := int64(ts)
+			series.Values = append(series.Values, Sample{
+				Time:  time.Unix(sec, int64((ts-float64(sec))*1e9)).UTC(),
+				Value: v,
+			})
+		}
+
+		out = append(out, series)
+	}
+
+	return out, nil
+}
+
+// eventHost is the part of the url recorded in events.
+func eventHost(u string) string {
+	if parsed, err := url.Parse(u); err == nil && parsed.Host != "" {
+		return parsed.Host
+	}
+
+	return strings.TrimPrefix(strings.TrimPrefix(u, "https://"), "http://")
+}
@@ -0,0 +1,143 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// recorded is a query_range response of Prometheus with two series.
+const recorded = `{"status":"success","data":{"resultType":"matrix","result":[
+{"metric":{"__name__":"product_vcpu","productId":"a"},"values":[[1655424000,"2"],[1655427600,"4"],[1655431200,"NaN"]]},
+{"metric":{"productId":"b"},"values":[[1655424000,"1.5"]]},
+{"metric":{"productId":"c"},"values":[]}]}}`
+
+var _ = Describe("prometheus client", func() {
+	var (
+		server *ghttp.Server
+		sut    Client
+
+		mu       sync.Mutex
+		requests int
+		respond  func(attempt int) (int, string)
+
+		backoff wait.Backoff
+	)
+
+	BeforeEach(func() {
+		backoff = DefaultBackoff
+		DefaultBackoff = wait.Backoff{Steps: 3, Duration: time.Millisecond, Factor: 1}
+
+		requests = 0
+		respond = func(attempt int) (int, string) {
+			return http.StatusOK, recorded
+		}
+
+		server = ghttp.NewServer()
+		server.RouteToHandler("POST", "/api/v1/query_range", func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("Authorization")).To(Equal("Bearer prom-token"))
+			Expect(r.ParseForm()).To(Succeed())
+			Expect(r.Form.Get("query")).To(Equal("sum by (productId) (product_vcpu)"))
+			Expect(r.Form.Get("step")).To(Equal("300"))
+
+			mu.Lock()
+			requests++
+			attempt := requests
+			mu.Unlock()
+
+			code, body := respond(attempt)
+			w.WriteHeader(code)
+			fmt.Fprint(w, body)
+		})
+
+		var err error
+		sut, err = NewClient(&PrometheusConfig{
+			URL:     server.URL(),
+			Token:   "prom-token",
+			Step:    5 * time.Minute,
+			Queries: map[string]string{"vcpu": "sum by (productId) (product_vcpu)"},
+		})
+		Expect(err).To(Succeed())
+	})
+
+	AfterEach(func() {
+		DefaultBackoff = backoff
+		server.Close()
+	})
+
+	It("should report the high water mark of each series per day", func() {
+		count, data, err := sut.FetchUsageData(context.Background(), DateRange{StartDate: "2022-06-17", EndDate: "2022-06-18"})
+		Expect(err).To(Succeed())
+		Expect(count).To(Equal(4))
+		Expect(requests).To(Equal(2))
+
+		report := UsageReport{}
+		Expect(json.Unmarshal([]byte(data), &report)).To(Succeed())
+		Expect(report.Data).To(HaveLen(4))
+
+		first := report.Data[0]
+		Expect(first.MeasuredUsage).To(Equal([]MeasuredUsage{{MetricId: "vcpu", Value: 4}}))
+		Expect(first.AdditionalAttributes).To(HaveKeyWithValue("productId", "a"))
+		Expect(first.AdditionalAttributes).ToNot(HaveKey("__name__"))
+		Expect(first.StartDate).To(Equal(time.Date(2022, 6, 17, 0, 0, 0, 0, time.UTC).UnixMilli()))
+		Expect(report.Data[1].MeasuredUsage[0].Value).To(Equal(1.5))
+
+		Expect(report.Data[0].EventId).ToNot(Equal(report.Data[2].EventId))
+	})
+
+	It("should retry server errors", func() {
+		respond = func(attempt int) (int, string) {
+			if attempt == 1 {
+				return http.StatusServiceUnavailable, "unavailable"
+			}
+			return http.StatusOK, recorded
+		}
+
+		count, _, err := sut.FetchUsageData(context.Background(), DateRange{StartDate: "2022-06-17", EndDate: "2022-06-17"})
+		Expect(err).To(Succeed())
+		Expect(count).To(Equal(2))
+		Expect(requests).To(Equal(2))
+	})
+
+	It("should return query errors without retrying", func() {
+		respond = func(attempt int) (int, string) {
+			return http.StatusBadRequest, `{"status":"error","errorType":"bad_data","error":"parse error"}`
+		}
+
+		_, _, err := sut.FetchUsageData(context.Background(), DateRange{StartDate: "2022-06-17", EndDate: "2022-06-17"})
+		Expect(errors.Is(err, QueryError)).To(BeTrue())
+		Expect(requests).To(Equal(1))
+	})
+
+	It("should return auth errors", func() {
+		respond = func(attempt int) (int, string) {
+			return http.StatusUnauthorized, "unauthorized"
+		}
+
+		_, _, err := sut.FetchUsageData(context.Background(), DateRange{StartDate: "2022-06-17", EndDate: "2022-06-17"})
+		Expect(errors.Is(err, AuthError)).To(BeTrue())
+	})
+})
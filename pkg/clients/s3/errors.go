@@ -0,0 +1,81 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"encoding/xml"
+	"net/http"
+
+	"emperror.dev/errors"
+)
+
+// Errors returned by the S3 client. Use errors.Is to check for them.
+const (
+	// AuthError is returned when the credentials are rejected or not allowed
+	// to access the bucket.
+	AuthError = errors.Sentinel("s3 authentication failed")
+
+	// NotFoundError is returned when the bucket or object doesn't exist.
+	NotFoundError = errors.Sentinel("s3 object not found")
+
+	// RequestError is returned when a request is rejected.
+	RequestError = errors.Sentinel("s3 request failed")
+
+	// ServerError is returned when the server fails to serve a request or
+	// asks the client to slow down. It is retried.
+	ServerError = errors.Sentinel("s3 server error")
+
+	// DecodeError is returned when a response can't be read.
+	DecodeError = errors.Sentinel("s3 response can't be decoded")
+
+	// RetryableError is returned when a request fails before the server
+	// responds.
+	RetryableError = errors.Sentinel("retryable")
+)
+
+func isRetryable(err error) bool {
+	return errors.Is(err, ServerError) || errors.Is(err, RetryableError)
+}
+
+// errorResponse is the body of an S3 error.
+type errorResponse struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+// checkStatus returns the error of a response, with the code and message of
+// its body.
+func checkStatus(resp *http.Response, body []byte) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	var e errorResponse
+	xml.Unmarshal(body, &e)
+
+	details := []interface{}{"code", resp.StatusCode, "errorCode", e.Code, "message", e.Message}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return errors.WithDetails(AuthError, details...)
+	case resp.StatusCode == http.StatusNotFound:
+		return errors.WithDetails(NotFoundError, details...)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return errors.WithDetails(ServerError, details...)
+	default:
+		return errors.WithDetails(RequestError, details...)
+	}
+}
@@ -0,0 +1,395 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/tls"
+	b64 "encoding/base64"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/go-logr/logr"
+	"github.com/redhat-marketplace/datactl/pkg/clients/shared"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2/klogr"
+)
+
+var (
+	logger logr.Logger = klogr.New().V(5).WithName("pkg/clients/s3")
+)
+
+// DefaultRegion is the region requests are signed for when the config has
+// none. MinIO accepts it unless it's configured with another region.
+const DefaultRegion = "us-east-1"
+
+type S3Config struct {
+	// Endpoint is the url of the S3 API, like https://minio.example.com:9000.
+	// Buckets are addressed in the path of requests, as MinIO expects.
+	Endpoint string `json:"endpoint"`
+	Region   string `json:"region,omitempty"`
+	Bucket   string `json:"bucket"`
+
+	AccessKeyID     string `json:"-"`
+	SecretAccessKey string `json:"-"`
+
+	TlsConfig *tls.Config
+}
+
+// Object is an object of a bucket.
+type Object struct {
+	Key          string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+}
+
+type Client interface {
+	// ListObjects returns the objects with a key starting with prefix, sorted
+	// by key.
+	ListObjects(ctx context.Context, prefix string) ([]Object, error)
+
+	// GetObject returns the content of an object. The caller closes it.
+	GetObject(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// PutObject writes an object. The body is rewound to retry.
+	PutObject(ctx context.Context, key string, body io.ReadSeeker) error
+
+	// DeleteObject deletes an object. An object already gone is deleted.
+	DeleteObject(ctx context.Context, key string) error
+
+	// GetObjectTagging returns the tags of an object.
+	GetObjectTagging(ctx context.Context, key string) (map[string]string, error)
+
+	// PutObjectTagging replaces the tags of an object.
+	PutObjectTagging(ctx context.Context, key string, tags map[string]string) error
+}
+
+type s3Client struct {
+	*http.Client
+	S3Config
+
+	now func() time.Time
+}
+
+func NewClient(config *S3Config) (Client, error) {
+	if config.Bucket == "" {
+		return nil, errors.New("no bucket configured")
+	}
+
+	u, err := url.Parse(config.Endpoint)
+	if err != nil || u.Host == "" {
+		return nil, errors.NewWithDetails("invalid endpoint", "endpoint", config.Endpoint)
+	}
+
+	shared.RegisterSecret(config.SecretAccessKey)
+
+	client, err := shared.NewHttpClient(config.TlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	cli := &s3Client{
+		Client:   client,
+		S3Config: *config,
+		now:      time.Now,
+	}
+
+	if cli.Region == "" {
+		cli.Region = DefaultRegion
+	}
+
+	return cli, nil
+}
+
+var DefaultBackoff = wait.Backoff{
+	Steps:    4,
+	Duration: 50 * time.Millisecond,
+	Factor:   5.0,
+	Jitter:   0.1,
+}
+
+// listBucketResult is the response of ListObjectsV2.
+type listBucketResult struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	IsTruncated           bool     `xml:"IsTruncated"`
+	NextContinuationToken string   `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		ETag         string    `xml:"ETag"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+}
+
+func (c *s3Client) ListObjects(ctx context.Context, prefix string) ([]Object, error) {
+	objects := []Object{}
+	token := ""
+
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		var result listBucketResult
+
+		resp, err := c.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err == nil {
+			err = decodeXML(resp.Body, &result)
+			resp.Body.Close()
+		}
+
+		if err != nil {
+			return nil, errors.WithDetails(err, "bucket", c.Bucket, "prefix", prefix)
+		}
+
+		for _, content := range result.Contents {
+			objects = append(objects, Object{
+				Key:          content.Key,
+				Size:         content.Size,
+				ETag:         strings.Trim(content.ETag, `"`),
+				LastModified: content.LastModified,
+			})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+
+		token = result.NextContinuationToken
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+
+	return objects, nil
+}
+
+func (c *s3Client) GetObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, key, nil, nil, nil)
+	if err != nil {
+		return nil, errors.WithDetails(err, "key", key)
+	}
+
+	return resp.Body, nil
+}
+
+func (c *s3Client) PutObject(ctx context.Context, key string, body io.ReadSeeker) error {
+	err := c.doAndClose(ctx, http.MethodPut, key, nil, body, nil)
+	return errors.WithDetails(err, "key", key)
+}
+
+func (c *s3Client) DeleteObject(ctx context.Context, key string) error {
+	err := c.doAndClose(ctx, http.MethodDelete, key, nil, nil, nil)
+	if errors.Is(err, NotFoundError) {
+		return nil
+	}
+
+	return errors.WithDetails(err, "key", key)
+}
+
+// tagging is the body of the object tagging requests.
+type tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	TagSet  []tag    `xml:"TagSet>Tag"`
+}
+
+type tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+func (c *s3Client) GetObjectTagging(ctx context.Context, key string) (map[string]string, error) {
+	var result tagging
+
+	resp, err := c.do(ctx, http.MethodGet, key, url.Values{"tagging": {""}}, nil, nil)
+	if err == nil {
+		err = decodeXML(resp.Body, &result)
+		resp.Body.Close()
+	}
+
+	if err != nil {
+		return nil, errors.WithDetails(err, "key", key)
+	}
+
+	tags := map[string]string{}
+	for _, t := range result.TagSet {
+		tags[t.Key] = t.Value
+	}
+
+	return tags, nil
+}
+
+func (c *s3Client) PutObjectTagging(ctx context.Context, key string, tags map[string]string) error {
+	body := tagging{}
+	for k, v := range tags {
+		body.TagSet = append(body.TagSet, tag{Key: k, Value: v})
+	}
+
+	sort.Slice(body.TagSet, func(i, j int) bool {
+		return body.TagSet[i].Key < body.TagSet[j].Key
+	})
+
+	data, err := xml.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	// S3 requires the digest of tagging requests
+	sum := md5.Sum(data)
+	header := http.Header{}
+	header.Set("Content-MD5", b64.StdEncoding.EncodeToString(sum[:]))
+	header.Set("Content-Type", "application/xml")
+
+	err = c.doAndClose(ctx, http.MethodPut, key, url.Values{"tagging": {""}}, bytes.NewReader(data), header)
+	return errors.WithDetails(err, "key", key)
+}
+
+// do sends a signed request for a key of the bucket, or the bucket if key is
+// empty, and returns the response when it's successful. The caller closes its
+// body. Server and connection errors are retried, rewinding the body.
+func (c *s3Client) do(
+	ctx context.Context,
+	method, key string,
+	query url.Values,
+	body io.ReadSeeker,
+	header http.Header,
+) (*http.Response, error) {
+	var out *http.Response
+
+	err := retry.OnError(DefaultBackoff, isRetryable, func() error {
+		req, err := c.newRequest(ctx, method, key, query, body, header)
+		if err != nil {
+			return errors.Wrap(err, "failed to build request")
+		}
+
+		resp, err := c.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			logger.Info("request failed", "method", method, "key", key, "err", err)
+			return errors.WithDetails(RetryableError, "message", err.Error())
+		}
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			defer resp.Body.Close()
+
+			data, _ := ioutil.ReadAll(resp.Body)
+			err := checkStatus(resp, data)
+			logger.Info("request failed", "method", method, "key", key, "code", resp.StatusCode, "err", err)
+			return err
+		}
+
+		out = resp
+		return nil
+	})
+
+	return out, err
+}
+
+// doAndClose sends a request with do, and closes the body of the response.
+func (c *s3Client) doAndClose(
+	ctx context.Context,
+	method, key string,
+	query url.Values,
+	body io.ReadSeeker,
+	header http.Header,
+) error {
+	resp, err := c.do(ctx, method, key, query, body, header)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+func (c *s3Client) newRequest(
+	ctx context.Context,
+	method, key string,
+	query url.Values,
+	body io.ReadSeeker,
+	header http.Header,
+) (*http.Request, error) {
+	u, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	path := strings.TrimSuffix(u.Path, "/") + "/" + c.Bucket
+	if key != "" {
+		path = path + "/" + key
+	}
+
+	u.Path = path
+	u.RawPath = uriEncode(path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	var reqBody io.Reader
+	var length int64
+
+	if body != nil {
+		length, err = body.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := body.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+
+		reqBody = ioutil.NopCloser(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req.ContentLength = length
+
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	req.Header.Set(headerContentSHA256, unsignedPayload)
+	sign(req, c.AccessKeyID, c.SecretAccessKey, c.Region, c.now())
+
+	return req, nil
+}
+
+func decodeXML(r io.Reader, v interface{}) error {
+	if err := xml.NewDecoder(r).Decode(v); err != nil {
+		return errors.WithDetails(DecodeError, "message", err.Error())
+	}
+
+	return nil
+}
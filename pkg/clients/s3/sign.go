@@ -0,0 +1,164 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	signAlgorithm = "AWS4-HMAC-SHA256"
+	signService   = "s3"
+
+	amzDateFormat   = "20060102T150405Z"
+	scopeDateFormat = "20060102"
+
+	// unsignedPayload is sent as the payload hash of every request, so bodies
+	// are streamed without being read twice.
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+
+	headerContentSHA256 = "X-Amz-Content-Sha256"
+	headerDate          = "X-Amz-Date"
+)
+
+// sign adds the AWS signature version 4 Authorization header to a request.
+// The payload hash is read from the X-Amz-Content-Sha256 header, which the
+// caller sets.
+func sign(req *http.Request, accessKeyID, secretAccessKey, region string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(amzDateFormat)
+	req.Header.Set(headerDate, amzDate)
+
+	names, canonicalHeaders := canonicalHeaders(req)
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		req.Header.Get(headerContentSHA256),
+	}, "\n")
+
+	scope := strings.Join([]string{now.Format(scopeDateFormat), region, signService, "aws4_request"}, "/")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	stringToSign := strings.Join([]string{
+		signAlgorithm,
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), now.Format(scopeDateFormat))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, signService)
+	key = hmacSHA256(key, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signAlgorithm, accessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalHeaders returns the names of the signed headers, sorted, and their
+// canonical form. The host and the headers S3 requires to be signed are
+// signed.
+func canonicalHeaders(req *http.Request) ([]string, string) {
+	values := map[string]string{
+		"host": req.URL.Host,
+	}
+
+	for name, v := range req.Header {
+		lower := strings.ToLower(name)
+
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-md5" || lower == "content-type" || lower == "range" {
+			values[lower] = strings.Join(v, ",")
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b := &strings.Builder{}
+	for _, name := range names {
+		b.WriteString(name + ":" + strings.Join(strings.Fields(values[name]), " ") + "\n")
+	}
+
+	return names, b.String()
+}
+
+// canonicalQuery returns the query sorted by name, with the names and values
+// encoded the way they are signed. It's also the query sent, so the server
+// signs the same string.
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := []string{}
+	for _, name := range names {
+		values := append([]string{}, query[name]...)
+		sort.Strings(values)
+
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode escapes every byte but the unreserved characters, and the slashes
+// of paths.
+func uriEncode(s string, encodeSlash bool) string {
+	b := &strings.Builder{}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
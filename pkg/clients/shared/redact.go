@@ -0,0 +1,141 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shared
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces secrets in logs and errors.
+const Redacted = "REDACTED"
+
+var (
+	secretsLock sync.RWMutex
+	secrets     = map[string]struct{}{}
+
+	// token query parameters, as sent by older ILMT clients
+	tokenParam = regexp.MustCompile(`(?i)([?&](?:access_)?token=)[^&\s"']+`)
+
+	// authorization headers printed in logs or errors
+	bearerToken = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9\-._~+/]+=*`)
+
+	sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "Token"}
+)
+
+// RegisterSecret adds a value that Redact replaces wherever it appears. The
+// clients register their tokens when they are created.
+func RegisterSecret(secret string) {
+	if len(secret) < 4 {
+		return
+	}
+
+	secretsLock.Lock()
+	defer secretsLock.Unlock()
+
+	secrets[secret] = struct{}{}
+}
+
+// Redact replaces the registered secrets, token query parameters and bearer
+// tokens in s.
+func Redact(s string) string {
+	secretsLock.RLock()
+	for secret := range secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	secretsLock.RUnlock()
+
+	s = tokenParam.ReplaceAllString(s, "${1}"+Redacted)
+	s = bearerToken.ReplaceAllString(s, "${1}"+Redacted)
+
+	return s
+}
+
+// RedactHeader returns a copy of the header with the values of the headers
+// that carry credentials replaced.
+func RedactHeader(header http.Header) http.Header {
+	out := header.Clone()
+
+	for _, key := range sensitiveHeaders {
+		if _, ok := out[key]; ok {
+			out[key] = []string{Redacted}
+		}
+	}
+
+	for key, values := range out {
+		for i := range values {
+			values[i] = Redact(values[i])
+		}
+		out[key] = values
+	}
+
+	return out
+}
+
+// RedactError returns an error with the message of err redacted. The
+// returned error unwraps to err, so errors.Is and errors.As still match.
+func RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	msg := Redact(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+
+	return &redactedError{err: err, msg: msg}
+}
+
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// withRedactedLogging logs the requests and responses of a client with their
+// secrets redacted, and redacts the errors of the transport.
+type withRedactedLogging struct {
+	rt http.RoundTripper
+}
+
+func (r withRedactedLogging) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !logger.Enabled() {
+		resp, err := r.rt.RoundTrip(req)
+		return resp, RedactError(err)
+	}
+
+	url := Redact(req.URL.String())
+	logger.Info("request", "method", req.Method, "url", url, "headers", RedactHeader(req.Header))
+
+	resp, err := r.rt.RoundTrip(req)
+	if err != nil {
+		err = RedactError(err)
+		logger.Info("request failed", "url", url, "err", err)
+		return resp, err
+	}
+
+	logger.Info("response", "url", url, "statusCode", resp.StatusCode, "headers", RedactHeader(resp.Header))
+	return resp, nil
+}
@@ -44,6 +44,10 @@ func NewHttpClient(
 		return nil, err
 	}
 
+	// headers are added by the options, so they are set on the request before
+	// it is logged
+	roundtripper = withRedactedLogging{rt: roundtripper}
+
 	for _, apply := range opts {
 		roundtripper = apply(roundtripper)
 	}
@@ -65,6 +69,8 @@ func WithBearerAuth(token string) RoundTripperOptions {
 		}
 	}
 
+	RegisterSecret(token)
+
 	rt := WithHeaders("Authorization", "Bearer "+token)
 	return rt
 }
@@ -84,6 +84,16 @@ type FileInfoCTLAction struct {
 	// +optional
 	UploadError string `protobuf:"-" json:"uploadError,omitempty"`
 
+	// UploadStatus is the last processing status reported by the upload API
+	// for UploadID. One of success, inProgress or failed.
+	// +optional
+	UploadStatus string `protobuf:"-" json:"uploadStatus,omitempty"`
+
+	// VerifiedChecksum is the SHA-256 of the file computed on pull and
+	// verified against Checksum.
+	// +optional
+	VerifiedChecksum string `protobuf:"-" json:"verifiedChecksum,omitempty"`
+
 	// +optional
 	Pushed bool `protobuf:"-" json:"pushed,omitempty"`
 
@@ -102,12 +112,26 @@ func NewFileInfoCTLAction(info *FileInfo) *FileInfoCTLAction {
 	}
 }
 
+// PullCheckpoint records the page a pull of a dataservice source was
+// processing, so an interrupted pull continues from that page.
+type PullCheckpoint struct {
+	// PageToken lists the page being pulled. It is empty for the first page.
+	// +optional
+	PageToken string `json:"pageToken,omitempty"`
+
+	// +optional
+	PageSize int32 `json:"pageSize,omitempty"`
+}
+
 type Result string
 
 const (
 	Ok     Result = "Ok"
 	Error  Result = "Err"
 	DryRun Result = "DryRun"
+	// Skipped is the result of a file that was refused, like a file that
+	// isn't pushed on commit.
+	Skipped Result = "Skipped"
 )
 
 var (
@@ -122,7 +146,7 @@ func (a Result) MarshalText() ([]byte, error) {
 		return []byte(green.Sprint(a)), nil
 	case Error:
 		return []byte(red.Sprint(a)), nil
-	case DryRun:
+	case DryRun, Skipped:
 		return []byte(yellow.Sprint(a)), nil
 	default:
 		return []byte(string(a)), nil
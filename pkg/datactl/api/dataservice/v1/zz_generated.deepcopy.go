@@ -141,3 +141,18 @@ func (in *ListFilesResponse) DeepCopyObject() runtime.Object {
 	}
 	return nil
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PullCheckpoint) DeepCopyInto(out *PullCheckpoint) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PullCheckpoint.
+func (in *PullCheckpoint) DeepCopy() *PullCheckpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(PullCheckpoint)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -42,3 +42,12 @@ func (obj *Config) SetGroupVersionKind(gvk schema.GroupVersionKind) {
 func (obj *Config) GroupVersionKind() schema.GroupVersionKind {
 	return schema.FromAPIVersionAndKind(SchemeGroupVersion.Group, "Config")
 }
+
+func (obj *SourceDetails) GetObjectKind() schema.ObjectKind { return obj }
+
+func (obj *SourceDetails) SetGroupVersionKind(gvk schema.GroupVersionKind) {
+}
+
+func (obj *SourceDetails) GroupVersionKind() schema.GroupVersionKind {
+	return schema.FromAPIVersionAndKind(SchemeGroupVersion.Group, "SourceDetails")
+}
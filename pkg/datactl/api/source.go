@@ -2,6 +2,7 @@ package api
 
 import (
 	"fmt"
+	"sync"
 )
 
 type SourceType string
@@ -19,14 +20,31 @@ func (s SourceType) MarshalText() (text []byte, err error) {
 	return []byte(fmt.Sprintf("%s", s)), nil
 }
 
+var (
+	sourceTypesLock sync.RWMutex
+	sourceTypes     = map[SourceType]struct{}{
+		DataService: {},
+		ILMT:        {},
+	}
+)
+
+// RegisterSourceType adds a source type that can be read from the config.
+// Source types are registered by the sources registry.
+func RegisterSourceType(s SourceType) {
+	sourceTypesLock.Lock()
+	defer sourceTypesLock.Unlock()
+
+	sourceTypes[s] = struct{}{}
+}
+
 func (s *SourceType) UnmarshalText(text []byte) error {
-	switch string(text) {
-	case DataService.String():
-		*s = DataService
-	case ILMT.String():
-		*s = ILMT
-	default:
+	sourceTypesLock.RLock()
+	defer sourceTypesLock.RUnlock()
+
+	if _, ok := sourceTypes[SourceType(text)]; !ok {
 		return fmt.Errorf("source type %s not defined", text)
 	}
+
+	*s = SourceType(text)
 	return nil
 }
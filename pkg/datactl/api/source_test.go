@@ -16,3 +16,26 @@ var _ = Describe("source", func() {
 		Expect(obj).To(Equal(DataService))
 	})
 })
+
+var _ = Describe("Config", func() {
+	It("should find a source by its name", func() {
+		cfg := &Config{
+			Sources: map[string]*Source{
+				"DataService:cluster": {Name: "cluster", Type: DataService},
+				"host":                {Name: "host", Type: ILMT},
+			},
+		}
+
+		key, s, ok := cfg.SourceByName("cluster")
+		Expect(ok).To(BeTrue())
+		Expect(key).To(Equal("DataService:cluster"))
+		Expect(s.Type).To(Equal(DataService))
+
+		key, _, ok = cfg.SourceByName("host")
+		Expect(ok).To(BeTrue())
+		Expect(key).To(Equal("host"))
+
+		_, _, ok = cfg.SourceByName("missing")
+		Expect(ok).To(BeFalse())
+	})
+})
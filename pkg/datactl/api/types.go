@@ -16,6 +16,8 @@ package api
 
 import (
 	"errors"
+	"path/filepath"
+	"strings"
 
 	dataservicev1 "github.com/redhat-marketplace/datactl/pkg/datactl/api/dataservice/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -44,12 +46,28 @@ type MeteringExport struct {
 
 	FileName string `json:"name"`
 
+	// Name is the key of the export in the metering export history. It is set
+	// when the export is archived.
+	// +optional
+	Name string `json:"export-name,omitempty"`
+
 	// +optional
 	DataServiceCluster string `json:"data-service-cluster,omitempty"`
 
 	// +optional
 	Files []*dataservicev1.FileInfoCTLAction `json:"files,omitempty"`
 
+	// PullCheckpoints are the pages being pulled from dataservice sources,
+	// keyed by source name. A checkpoint is removed when its pull completes.
+	// +optional
+	PullCheckpoints map[string]*dataservicev1.PullCheckpoint `json:"pull-checkpoints,omitempty"`
+
+	// SyncStage is the last stage of an export sync that completed, so an
+	// interrupted sync continues with the next one. It is empty when no sync
+	// is in progress.
+	// +optional
+	SyncStage string `json:"sync-stage,omitempty"`
+
 	// +k8s:conversion-gen=false
 	Committed bool `json:"-"`
 
@@ -57,6 +75,23 @@ type MeteringExport struct {
 	Pushed bool `json:"-"`
 }
 
+const (
+	// SyncStagePulled is the stage of an export sync that pulled the sources.
+	SyncStagePulled = "pulled"
+	// SyncStagePushed is the stage of an export sync that pushed the files.
+	SyncStagePushed = "pushed"
+)
+
+// DisplayName returns the name of the export in the history, or the base
+// name of its file if it has not been archived yet.
+func (e *MeteringExport) DisplayName() string {
+	if e.Name != "" {
+		return e.Name
+	}
+
+	return filepath.Base(e.FileName)
+}
+
 type Source struct {
 	// LocationOfOrigin indicates where this object came from.  It is used for round tripping config post-merge, but never serialized.
 	// +k8s:conversion-gen=false
@@ -67,12 +102,73 @@ type Source struct {
 	Type SourceType `json:"source-type"`
 
 	LastAccessTime metav1.Time `json:"last-access-time,omitempty"`
+
+	// Properties are the settings of source types that don't have their own
+	// section in the config. They are described by the schema of the type.
+	// +optional
+	Properties map[string]string `json:"properties,omitempty"`
 }
 
 func (s *Source) String() string {
 	return s.Type.String() + ":" + s.Name
 }
 
+// PropertiesWithPrefix returns the properties with a name starting with
+// prefix, by their name without the prefix.
+func (s *Source) PropertiesWithPrefix(prefix string) map[string]string {
+	out := map[string]string{}
+
+	for name, value := range s.Properties {
+		if strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+			out[strings.TrimPrefix(name, prefix)] = value
+		}
+	}
+
+	return out
+}
+
+// SourceByName returns the key and the source named name. Sources are keyed
+// by their type and name once the config is loaded, so they are looked up by
+// the name on the source rather than the key.
+func (c *Config) SourceByName(name string) (string, *Source, bool) {
+	if s, ok := c.Sources[name]; ok {
+		return name, s, true
+	}
+
+	for key, s := range c.Sources {
+		if s.Name == name {
+			return key, s, true
+		}
+	}
+
+	return "", nil, false
+}
+
+// SourceDetails describes a source and its endpoint for the sources
+// commands. Secret properties are redacted.
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type SourceDetails struct {
+	Name string `json:"name"`
+
+	Type SourceType `json:"type"`
+
+	// Endpoint is the host or location the source is pulled from.
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// +optional
+	TokenExpiration *metav1.Time `json:"tokenExpiration,omitempty"`
+
+	// +optional
+	LastAccessTime *metav1.Time `json:"lastAccessTime,omitempty"`
+
+	// +optional
+	LastPullDate string `json:"lastPullDate,omitempty"`
+
+	// +optional
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
 // DEPRECATED
 type MeteringFileSummary struct {
 	DataServiceContext string `json:"data-service-context"`
@@ -122,6 +218,31 @@ type UploadAPI struct {
 	// attach, port forward).
 	// +optional
 	ProxyURL string `json:"proxy-url,omitempty"`
+
+	// Retry is how the requests to the upload API are retried. The defaults of
+	// the client are used for the fields that are not set.
+	// +optional
+	Retry *UploadRetryPolicy `json:"retry,omitempty"`
+}
+
+// UploadRetryPolicy is how the requests to the upload API are retried.
+type UploadRetryPolicy struct {
+	// MaxAttempts is the number of attempts of a request, including the first.
+	// +optional
+	MaxAttempts int `json:"max-attempts,omitempty"`
+
+	// BaseDelay is the delay before the first retry, doubled on each retry.
+	// +optional
+	BaseDelay metav1.Duration `json:"base-delay,omitempty"`
+
+	// MaxDelay caps the delay between two attempts, including the delay asked
+	// for by a Retry-After header.
+	// +optional
+	MaxDelay metav1.Duration `json:"max-delay,omitempty"`
+
+	// RetryableStatusCodes are the response status codes that are retried.
+	// +optional
+	RetryableStatusCodes []int `json:"retryable-status-codes,omitempty"`
 }
 
 type DataServiceEndpoint struct {
@@ -48,7 +48,11 @@ func Convert_v1_Config_To_api_Config(a *Config, b *api.Config, scope conversion.
 			return err
 		}
 
-		b.MeteringExports[bD.FileName] = bD
+		if bD.Name == "" {
+			bD.Name = bD.FileName
+		}
+
+		b.MeteringExports[bD.Name] = bD
 	}
 
 	b.ILMTEndpoints = make(map[string]*api.ILMTEndpoint)
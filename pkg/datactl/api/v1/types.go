@@ -38,11 +38,23 @@ type Config struct {
 type MeteringExport struct {
 	FileName string `json:"name"`
 
+	// +optional
+	Name string `json:"export-name,omitempty"`
+
 	// +optional
 	DataServiceCluster string `json:"data-service-cluster,omitempty"`
 
 	// +optional
 	Files []*dataservicev1.FileInfoCTLAction `json:"files,omitempty"`
+
+	// PullCheckpoints are the pages being pulled from dataservice sources,
+	// keyed by source name. A checkpoint is removed when its pull completes.
+	// +optional
+	PullCheckpoints map[string]*dataservicev1.PullCheckpoint `json:"pull-checkpoints,omitempty"`
+
+	// SyncStage is the last stage of an export sync that completed.
+	// +optional
+	SyncStage string `json:"sync-stage,omitempty"`
 }
 
 type Source struct {
@@ -51,6 +63,11 @@ type Source struct {
 	Type api.SourceType `json:"source-type"`
 
 	LastAccessTime metav1.Time `json:"last-access-time,omitempty"`
+
+	// Properties are the settings of source types that don't have their own
+	// section in the config. They are described by the schema of the type.
+	// +optional
+	Properties map[string]string `json:"properties,omitempty"`
 }
 
 func (s *Source) String() string {
@@ -90,6 +107,31 @@ type UploadAPI struct {
 	// attach, port forward).
 	// +optional
 	ProxyURL string `json:"proxy-url,omitempty"`
+
+	// Retry is how the requests to the upload API are retried. The defaults of
+	// the client are used for the fields that are not set.
+	// +optional
+	Retry *UploadRetryPolicy `json:"retry,omitempty"`
+}
+
+// UploadRetryPolicy is how the requests to the upload API are retried.
+type UploadRetryPolicy struct {
+	// MaxAttempts is the number of attempts of a request, including the first.
+	// +optional
+	MaxAttempts int `json:"max-attempts,omitempty"`
+
+	// BaseDelay is the delay before the first retry, doubled on each retry.
+	// +optional
+	BaseDelay metav1.Duration `json:"base-delay,omitempty"`
+
+	// MaxDelay caps the delay between two attempts, including the delay asked
+	// for by a Retry-After header.
+	// +optional
+	MaxDelay metav1.Duration `json:"max-delay,omitempty"`
+
+	// RetryableStatusCodes are the response status codes that are retried.
+	// +optional
+	RetryableStatusCodes []int `json:"retryable-status-codes,omitempty"`
 }
 
 type DataServiceEndpoint struct {
@@ -205,8 +205,11 @@ func Convert_api_ILMTEndpoint_To_v1_ILMTEndpoint(in *api.ILMTEndpoint, out *ILMT
 
 func autoConvert_v1_MeteringExport_To_api_MeteringExport(in *MeteringExport, out *api.MeteringExport, s conversion.Scope) error {
 	out.FileName = in.FileName
+	out.Name = in.Name
 	out.DataServiceCluster = in.DataServiceCluster
 	out.Files = *(*[]*dataservicev1.FileInfoCTLAction)(unsafe.Pointer(&in.Files))
+	out.PullCheckpoints = *(*map[string]*dataservicev1.PullCheckpoint)(unsafe.Pointer(&in.PullCheckpoints))
+	out.SyncStage = in.SyncStage
 	return nil
 }
 
@@ -218,8 +221,11 @@ func Convert_v1_MeteringExport_To_api_MeteringExport(in *MeteringExport, out *ap
 func autoConvert_api_MeteringExport_To_v1_MeteringExport(in *api.MeteringExport, out *MeteringExport, s conversion.Scope) error {
 	// INFO: in.LocationOfOrigin opted out of conversion generation
 	out.FileName = in.FileName
+	out.Name = in.Name
 	out.DataServiceCluster = in.DataServiceCluster
 	out.Files = *(*[]*dataservicev1.FileInfoCTLAction)(unsafe.Pointer(&in.Files))
+	out.PullCheckpoints = *(*map[string]*dataservicev1.PullCheckpoint)(unsafe.Pointer(&in.PullCheckpoints))
+	out.SyncStage = in.SyncStage
 	// INFO: in.Committed opted out of conversion generation
 	// INFO: in.Pushed opted out of conversion generation
 	return nil
@@ -234,6 +240,7 @@ func autoConvert_v1_Source_To_api_Source(in *Source, out *api.Source, s conversi
 	out.Name = in.Name
 	out.Type = api.SourceType(in.Type)
 	out.LastAccessTime = in.LastAccessTime
+	out.Properties = *(*map[string]string)(unsafe.Pointer(&in.Properties))
 	return nil
 }
 
@@ -247,6 +254,7 @@ func autoConvert_api_Source_To_v1_Source(in *api.Source, out *Source, s conversi
 	out.Name = in.Name
 	out.Type = api.SourceType(in.Type)
 	out.LastAccessTime = in.LastAccessTime
+	out.Properties = *(*map[string]string)(unsafe.Pointer(&in.Properties))
 	return nil
 }
 
@@ -263,6 +271,7 @@ func autoConvert_v1_UploadAPI_To_api_UploadAPI(in *UploadAPI, out *api.UploadAPI
 	out.CertificateAuthority = in.CertificateAuthority
 	out.CertificateAuthorityData = *(*[]byte)(unsafe.Pointer(&in.CertificateAuthorityData))
 	out.ProxyURL = in.ProxyURL
+	out.Retry = (*api.UploadRetryPolicy)(unsafe.Pointer(in.Retry))
 	return nil
 }
 
@@ -280,6 +289,7 @@ func autoConvert_api_UploadAPI_To_v1_UploadAPI(in *api.UploadAPI, out *UploadAPI
 	out.CertificateAuthority = in.CertificateAuthority
 	out.CertificateAuthorityData = *(*[]byte)(unsafe.Pointer(&in.CertificateAuthorityData))
 	out.ProxyURL = in.ProxyURL
+	out.Retry = (*UploadRetryPolicy)(unsafe.Pointer(in.Retry))
 	return nil
 }
 
@@ -133,6 +133,21 @@ func (in *MeteringExport) DeepCopyInto(out *MeteringExport) {
 			}
 		}
 	}
+	if in.PullCheckpoints != nil {
+		in, out := &in.PullCheckpoints, &out.PullCheckpoints
+		*out = make(map[string]*dataservicev1.PullCheckpoint, len(*in))
+		for key, val := range *in {
+			var outVal *dataservicev1.PullCheckpoint
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = new(dataservicev1.PullCheckpoint)
+				(*in).DeepCopyInto(*out)
+			}
+			(*out)[key] = outVal
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MeteringExport.
@@ -149,6 +164,13 @@ func (in *MeteringExport) DeepCopy() *MeteringExport {
 func (in *Source) DeepCopyInto(out *Source) {
 	*out = *in
 	in.LastAccessTime.DeepCopyInto(&out.LastAccessTime)
+	if in.Properties != nil {
+		in, out := &in.Properties, &out.Properties
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Source.
@@ -169,6 +191,11 @@ func (in *UploadAPI) DeepCopyInto(out *UploadAPI) {
 		*out = make([]byte, len(*in))
 		copy(*out, *in)
 	}
+	if in.Retry != nil {
+		in, out := &in.Retry, &out.Retry
+		*out = new(UploadRetryPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UploadAPI.
@@ -180,3 +207,25 @@ func (in *UploadAPI) DeepCopy() *UploadAPI {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UploadRetryPolicy) DeepCopyInto(out *UploadRetryPolicy) {
+	*out = *in
+	out.BaseDelay = in.BaseDelay
+	out.MaxDelay = in.MaxDelay
+	if in.RetryableStatusCodes != nil {
+		in, out := &in.RetryableStatusCodes, &out.RetryableStatusCodes
+		*out = make([]int, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UploadRetryPolicy.
+func (in *UploadRetryPolicy) DeepCopy() *UploadRetryPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(UploadRetryPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
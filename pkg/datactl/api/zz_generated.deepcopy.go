@@ -149,6 +149,21 @@ func (in *MeteringExport) DeepCopyInto(out *MeteringExport) {
 			}
 		}
 	}
+	if in.PullCheckpoints != nil {
+		in, out := &in.PullCheckpoints, &out.PullCheckpoints
+		*out = make(map[string]*v1.PullCheckpoint, len(*in))
+		for key, val := range *in {
+			var outVal *v1.PullCheckpoint
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = new(v1.PullCheckpoint)
+				(*in).DeepCopyInto(*out)
+			}
+			(*out)[key] = outVal
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MeteringExport.
@@ -191,6 +206,13 @@ func (in *MeteringFileSummary) DeepCopy() *MeteringFileSummary {
 func (in *Source) DeepCopyInto(out *Source) {
 	*out = *in
 	in.LastAccessTime.DeepCopyInto(&out.LastAccessTime)
+	if in.Properties != nil {
+		in, out := &in.Properties, &out.Properties
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Source.
@@ -203,6 +225,44 @@ func (in *Source) DeepCopy() *Source {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SourceDetails) DeepCopyInto(out *SourceDetails) {
+	*out = *in
+	if in.TokenExpiration != nil {
+		in, out := &in.TokenExpiration, &out.TokenExpiration
+		*out = (*in).DeepCopy()
+	}
+	if in.LastAccessTime != nil {
+		in, out := &in.LastAccessTime, &out.LastAccessTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Properties != nil {
+		in, out := &in.Properties, &out.Properties
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SourceDetails.
+func (in *SourceDetails) DeepCopy() *SourceDetails {
+	if in == nil {
+		return nil
+	}
+	out := new(SourceDetails)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SourceDetails) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UploadAPI) DeepCopyInto(out *UploadAPI) {
 	*out = *in
@@ -211,6 +271,11 @@ func (in *UploadAPI) DeepCopyInto(out *UploadAPI) {
 		*out = make([]byte, len(*in))
 		copy(*out, *in)
 	}
+	if in.Retry != nil {
+		in, out := &in.Retry, &out.Retry
+		*out = new(UploadRetryPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UploadAPI.
@@ -222,3 +287,25 @@ func (in *UploadAPI) DeepCopy() *UploadAPI {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UploadRetryPolicy) DeepCopyInto(out *UploadRetryPolicy) {
+	*out = *in
+	out.BaseDelay = in.BaseDelay
+	out.MaxDelay = in.MaxDelay
+	if in.RetryableStatusCodes != nil {
+		in, out := &in.RetryableStatusCodes, &out.RetryableStatusCodes
+		*out = make([]int, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UploadRetryPolicy.
+func (in *UploadRetryPolicy) DeepCopy() *UploadRetryPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(UploadRetryPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
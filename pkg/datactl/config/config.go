@@ -134,6 +134,23 @@ func ModifyConfig(configAccess ConfigAccess, newConfig datactlapi.Config, relati
 		}
 	}
 
+	for key := range startingConfig.DataServiceEndpoints {
+		if _, exists := newConfig.DataServiceEndpoints[key]; exists {
+			continue
+		}
+
+		if err := writeConfig(configAccess, func(in *datactlapi.Config) (bool, error) {
+			if _, exists := in.DataServiceEndpoints[key]; !exists {
+				return false, nil
+			}
+
+			delete(in.DataServiceEndpoints, key)
+			return true, nil
+		}); err != nil {
+			return err
+		}
+	}
+
 	newExports := map[string]*datactlapi.MeteringExport{}
 
 	for key, export := range newConfig.MeteringExports {
@@ -154,7 +171,7 @@ func ModifyConfig(configAccess ConfigAccess, newConfig datactlapi.Config, relati
 		}
 	}
 
-	if len(newExports) != 0 {
+	if len(newExports) != 0 || len(startingConfig.MeteringExports) != 0 {
 		if err := writeConfig(configAccess,
 			func(in *datactlapi.Config) (bool, error) {
 				in.MeteringExports = newExports
@@ -197,7 +214,7 @@ func ModifyConfig(configAccess ConfigAccess, newConfig datactlapi.Config, relati
 		}
 	}
 
-	if len(newIlmtEndpt) != 0 {
+	if len(newIlmtEndpt) != 0 || len(startingConfig.ILMTEndpoints) != 0 {
 		if err := writeConfig(configAccess,
 			func(in *datactlapi.Config) (bool, error) {
 				in.ILMTEndpoints = newIlmtEndpt
@@ -229,7 +246,7 @@ func ModifyConfig(configAccess ConfigAccess, newConfig datactlapi.Config, relati
 		}
 	}
 
-	if len(newSources) != 0 {
+	if len(newSources) != 0 || len(startingConfig.Sources) != 0 {
 		if err := writeConfig(configAccess,
 			func(in *datactlapi.Config) (bool, error) {
 				in.Sources = newSources
@@ -15,6 +15,8 @@
 package config
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"sync"
 
 	"github.com/gotidy/ptr"
@@ -25,6 +27,7 @@ import (
 	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
 	"github.com/spf13/pflag"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	k8sapiflag "k8s.io/component-base/cli/flag"
 )
 
 type ConfigFlags struct {
@@ -78,6 +81,23 @@ func NewConfigFlags(kubeFlags *genericclioptions.ConfigFlags) *ConfigFlags {
 	}
 }
 
+// WithoutCache returns config flags that share the flag values of f but load
+// the config, the clients and the export again, for long running commands
+// that must see the changes saved since they started.
+func (f *ConfigFlags) WithoutCache() *ConfigFlags {
+	return &ConfigFlags{
+		overrides:         f.overrides,
+		DATACTLConfig:     f.DATACTLConfig,
+		MarketplaceHost:   f.MarketplaceHost,
+		MarketplaceToken:  f.MarketplaceToken,
+		DataServiceCAFile: f.DataServiceCAFile,
+		ExportFileName:    f.ExportFileName,
+		MinVersion:        f.MinVersion,
+		CipherSuites:      f.CipherSuites,
+		KubectlConfig:     f.KubectlConfig,
+	}
+}
+
 func (f *ConfigFlags) ConfigAccess() ConfigAccess {
 	return f.config.ConfigAccess()
 }
@@ -228,6 +248,36 @@ func (f *ConfigFlags) toPersistentMeteringExport() (*datactlapi.MeteringExport,
 	return exp, err
 }
 
+// TLSConfig returns a TLS config with the system root CAs and the TLS version
+// and cipher suites of the flags, for the clients of source types that don't
+// have their own client config.
+func (f *ConfigFlags) TLSConfig() (*tls.Config, error) {
+	rootCAs, _ := x509.SystemCertPool()
+	if rootCAs == nil {
+		rootCAs = x509.NewCertPool()
+	}
+
+	tlsConfig := &tls.Config{RootCAs: rootCAs}
+
+	if f.MinVersion != nil {
+		tlsVersion, err := k8sapiflag.TLSVersion(*f.MinVersion)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.MinVersion = tlsVersion
+	}
+
+	if f.CipherSuites != nil {
+		tlsCipherSuites, err := k8sapiflag.TLSCipherSuites(*f.CipherSuites)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.CipherSuites = tlsCipherSuites
+	}
+
+	return tlsConfig, nil
+}
+
 func (f *ConfigFlags) AddFlags(flags *pflag.FlagSet) {
 	flags.StringVar(f.DATACTLConfig, "rhm-config", "", "override the rhm config file")
 	flags.StringVar(f.MinVersion, "tls-min-version", "VersionTLS12", "Minimum TLS version supported. Value must match version names from https://golang.org/pkg/crypto/tls/#pkg-constants.")
@@ -17,6 +17,7 @@ package config
 import (
 	"io/ioutil"
 	"os"
+	"time"
 
 	"github.com/gotidy/ptr"
 	. "github.com/onsi/ginkgo/v2"
@@ -69,6 +70,25 @@ data-service-endpoints:
 		Expect(conf.DataServiceEndpoints["foo.test"].Host).To(Equal("foo.test"))
 	})
 
+	It("should parse the retry policy of the upload api", func() {
+		Expect(os.WriteFile(name, []byte(`
+upload-api:
+  host: test.com
+  retry:
+    max-attempts: 10
+    base-delay: 5s
+    max-delay: 2m
+    retryable-status-codes: [429, 503]`), 0600)).To(Succeed())
+
+		conf, err := LoadFromFile(name)
+		Expect(err).To(Succeed())
+		Expect(conf.MarketplaceEndpoint.Retry).ToNot(BeNil())
+		Expect(conf.MarketplaceEndpoint.Retry.MaxAttempts).To(Equal(10))
+		Expect(conf.MarketplaceEndpoint.Retry.BaseDelay.Duration).To(Equal(5 * time.Second))
+		Expect(conf.MarketplaceEndpoint.Retry.MaxDelay.Duration).To(Equal(2 * time.Minute))
+		Expect(conf.MarketplaceEndpoint.Retry.RetryableStatusCodes).To(Equal([]int{429, 503}))
+	})
+
 	It("should read file from flags", func() {
 		testFlags := genericclioptions.NewConfigFlags(false)
 		testFlags.Context = ptr.String("my-context")
@@ -110,4 +130,71 @@ data-service-endpoints:
 		Expect(conf.DataServiceEndpoints["foo.test"].Host).To(Equal("foo.test"))
 		Expect(conf.MeteringExports).To(HaveLen(1))
 	})
+
+	It("should remove exports from the history", func() {
+		testFlags := genericclioptions.NewConfigFlags(false)
+		testFlags.ClusterName = ptr.String("foo")
+		testFlags.Context = ptr.String("my-context")
+
+		rhmConfigFlags := NewConfigFlags(testFlags)
+		rhmConfigFlags.DATACTLConfig = ptr.String(name)
+
+		conf, err := rhmConfigFlags.RawPersistentConfigLoader().RawConfig()
+		Expect(err).To(Succeed())
+
+		conf.MeteringExports["export-1"] = &api.MeteringExport{
+			Name:     "export-1",
+			FileName: "foo",
+		}
+
+		Expect(ModifyConfig(rhmConfigFlags.ConfigAccess(), *conf, true)).To(Succeed())
+
+		conf, err = LoadFromFile(name)
+		Expect(err).To(Succeed())
+		Expect(conf.MeteringExports).To(HaveKey("export-1"))
+
+		delete(conf.MeteringExports, "export-1")
+		Expect(ModifyConfig(rhmConfigFlags.ConfigAccess(), *conf, true)).To(Succeed())
+
+		conf, err = LoadFromFile(name)
+		Expect(err).To(Succeed())
+		Expect(conf.MeteringExports).To(BeEmpty())
+	})
+	It("should remove sources and their endpoints", func() {
+		testFlags := genericclioptions.NewConfigFlags(false)
+		testFlags.ClusterName = ptr.String("foo")
+		testFlags.Context = ptr.String("my-context")
+
+		rhmConfigFlags := NewConfigFlags(testFlags)
+		rhmConfigFlags.DATACTLConfig = ptr.String(name)
+
+		conf, err := rhmConfigFlags.RawPersistentConfigLoader().RawConfig()
+		Expect(err).To(Succeed())
+
+		conf.Sources = map[string]*api.Source{
+			"foo.test":      {Name: "foo.test", Type: api.DataService},
+			"ilmt.test.com": {Name: "ilmt.test.com", Type: api.ILMT},
+		}
+		conf.ILMTEndpoints = map[string]*api.ILMTEndpoint{
+			"ilmt.test.com": {Host: "ilmt.test.com", Port: "443", Token: "token"},
+		}
+
+		Expect(ModifyConfig(rhmConfigFlags.ConfigAccess(), *conf, true)).To(Succeed())
+
+		conf, err = LoadFromFile(name)
+		Expect(err).To(Succeed())
+		Expect(conf.Sources).To(HaveLen(2))
+		Expect(conf.ILMTEndpoints).To(HaveKey("ilmt.test.com"))
+
+		conf.Sources = map[string]*api.Source{}
+		conf.ILMTEndpoints = map[string]*api.ILMTEndpoint{}
+		delete(conf.DataServiceEndpoints, "foo.test")
+		Expect(ModifyConfig(rhmConfigFlags.ConfigAccess(), *conf, true)).To(Succeed())
+
+		conf, err = LoadFromFile(name)
+		Expect(err).To(Succeed())
+		Expect(conf.Sources).To(BeEmpty())
+		Expect(conf.ILMTEndpoints).To(BeEmpty())
+		Expect(conf.DataServiceEndpoints).To(BeEmpty())
+	})
 })
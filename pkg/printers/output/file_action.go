@@ -77,6 +77,42 @@ func NewActionCLITableOrStruct(
 	}
 }
 
+func NewUploadStatusCLITableOrStruct(
+	flags *get.PrintFlags,
+	printer printers.ResourcePrinter,
+) *TableOrStructPrinter {
+	return &TableOrStructPrinter{
+		PrintFlags: flags,
+		ColumnDefinitions: []metav1.TableColumnDefinition{
+			{
+				Name:        "      Name                                                         ",
+				Description: "name of the file",
+			},
+			{
+				Name:        "Upload ID",
+				Description: "id returned by the metric api on upload",
+			},
+			{
+				Name:        "Status",
+				Description: "processing status reported by the metric api",
+			},
+			{
+				Name:        "Error",
+				Description: "error reported by the metric api",
+			},
+		},
+		Printer: printer,
+		ObjectToRow: func(obj runtime.Object) metav1.TableRow {
+			file := obj.(*dataservicev1.FileInfoCTLAction)
+			return metav1.TableRow{
+				Cells: []interface{}{
+					fmt.Sprintf("     %s", file.Name), file.UploadID, file.UploadStatus, file.UploadError,
+				},
+			}
+		},
+	}
+}
+
 func NewPushFileOnlyCLITableOrStruct(
 	flags *get.PrintFlags,
 	printer printers.ResourcePrinter,
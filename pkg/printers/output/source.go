@@ -0,0 +1,67 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"time"
+
+	datactlapi "github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/printers"
+	"k8s.io/kubectl/pkg/cmd/get"
+)
+
+func NewSourceCLITableOrStruct(
+	flags *get.PrintFlags,
+	printer printers.ResourcePrinter,
+) *TableOrStructPrinter {
+	return &TableOrStructPrinter{
+		PrintFlags: flags,
+		ColumnDefinitions: []metav1.TableColumnDefinition{
+			{
+				Name:        "Name",
+				Description: "name of the source",
+			},
+			{
+				Name:        "Type",
+				Description: "type of the source",
+			},
+			{
+				Name:        "Endpoint",
+				Description: "host or location the source is pulled from",
+			},
+			{
+				Name:        "Last Access",
+				Description: "last time the source was accessed",
+			},
+		},
+		Printer: printer,
+		ObjectToRow: func(obj runtime.Object) metav1.TableRow {
+			source := obj.(*datactlapi.SourceDetails)
+
+			lastAccess := ""
+			if source.LastAccessTime != nil {
+				lastAccess = source.LastAccessTime.UTC().Format(time.RFC3339)
+			}
+
+			return metav1.TableRow{
+				Cells: []interface{}{
+					source.Name, source.Type, source.Endpoint, lastAccess,
+				},
+			}
+		},
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedule
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+)
+
+// Schedule returns the times a recurring job runs at.
+type Schedule interface {
+	// Next returns the first time the job runs after t.
+	Next(t time.Time) time.Time
+}
+
+// Every returns a schedule that runs a job every interval.
+func Every(interval time.Duration) Schedule {
+	return every(interval)
+}
+
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// maxSearch bounds the search of the next time of a cron schedule, so a
+// schedule that never matches, like the 31st of February, doesn't loop
+// forever.
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is accepted for Sunday and folded into 0
+	dowField = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// cron is a schedule of the five fields of a crontab line. Each field is the
+// set of values it matches as a bit set.
+type cron struct {
+	minute, hour, dom, month, dow uint64
+
+	// a day matches either day field when both are restricted, like cron
+	domStar, dowStar bool
+}
+
+// ParseCron parses a standard five field cron expression, minute, hour, day
+// of month, month and day of week, or one of the @hourly, @daily, @weekly,
+// @monthly and @yearly descriptors. Times are matched in the location of the
+// time given to Next.
+func ParseCron(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+
+	if d, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = d
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, errors.NewWithDetails("cron schedule must have 5 fields", "schedule", spec)
+	}
+
+	c := &cron{
+		domStar: isStar(fields[2]),
+		dowStar: isStar(fields[4]),
+	}
+
+	var err error
+	for i, f := range []struct {
+		field
+		bits *uint64
+	}{
+		{minuteField, &c.minute},
+		{hourField, &c.hour},
+		{domField, &c.dom},
+		{monthField, &c.month},
+		{dowField, &c.dow},
+	} {
+		*f.bits, err = f.parse(fields[i])
+		if err != nil {
+			return nil, errors.WithDetails(err, "schedule", spec)
+		}
+	}
+
+	if c.dow&(1<<7) != 0 {
+		c.dow = c.dow&^(1<<7) | 1
+	}
+
+	return c, nil
+}
+
+func isStar(value string) bool {
+	return value == "*" || strings.HasPrefix(value, "*/")
+}
+
+// parse returns the bit set of the values matched by a field: a comma
+// separated list of *, a value or a range, each with an optional /step.
+func (f field) parse(value string) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(value, ",") {
+		rangePart, step := part, 1
+
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, errors.NewWithDetails("invalid step", "field", f.name, "value", part)
+			}
+			rangePart = part[:i]
+		}
+
+		start, end := f.min, f.max
+
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+
+			var err error
+			if start, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if end, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+		default:
+			var err error
+			if start, err = f.value(rangePart); err != nil {
+				return 0, err
+			}
+
+			// a single value only matches itself unless it has a step
+			if step == 1 {
+				end = start
+			}
+		}
+
+		if start > end {
+			return 0, errors.NewWithDetails("invalid range", "field", f.name, "value", part)
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func (f field) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, errors.NewWithDetails("value out of range", "field", f.name, "value", s, "min", f.min, "max", f.max)
+	}
+
+	return v, nil
+}
+
+func (c *cron) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxSearch)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (c *cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+
+	return dom || dow
+}
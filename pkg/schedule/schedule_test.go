@@ -0,0 +1,67 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedule
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("schedule", func() {
+	// a Friday
+	start := time.Date(2021, time.October, 15, 10, 30, 20, 0, time.UTC)
+
+	It("should run every interval", func() {
+		Expect(Every(time.Hour).Next(start)).To(Equal(start.Add(time.Hour)))
+	})
+
+	DescribeTable("should find the next time of a cron schedule",
+		func(spec string, expected time.Time) {
+			s, err := ParseCron(spec)
+			Expect(err).To(Succeed())
+			Expect(s.Next(start)).To(Equal(expected))
+		},
+		Entry("every minute", "* * * * *", time.Date(2021, time.October, 15, 10, 31, 0, 0, time.UTC)),
+		Entry("daily", "0 2 * * *", time.Date(2021, time.October, 16, 2, 0, 0, 0, time.UTC)),
+		Entry("descriptor", "@hourly", time.Date(2021, time.October, 15, 11, 0, 0, 0, time.UTC)),
+		Entry("step", "*/20 * * * *", time.Date(2021, time.October, 15, 10, 40, 0, 0, time.UTC)),
+		Entry("list and range", "0 8-9,12 * * *", time.Date(2021, time.October, 15, 12, 0, 0, 0, time.UTC)),
+		Entry("day of week name", "0 0 * * mon", time.Date(2021, time.October, 18, 0, 0, 0, 0, time.UTC)),
+		Entry("sunday as 7", "0 0 * * 7", time.Date(2021, time.October, 17, 0, 0, 0, 0, time.UTC)),
+		Entry("either day field", "0 0 1 * sat", time.Date(2021, time.October, 16, 0, 0, 0, 0, time.UTC)),
+		Entry("month", "0 0 1 jan *", time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)),
+		Entry("leap day", "0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)),
+	)
+
+	It("should not find a time for a date that doesn't exist", func() {
+		s, err := ParseCron("0 0 31 2 *")
+		Expect(err).To(Succeed())
+		Expect(s.Next(start).IsZero()).To(BeTrue())
+	})
+
+	DescribeTable("should reject invalid cron schedules",
+		func(spec string) {
+			_, err := ParseCron(spec)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("too few fields", "* * * *"),
+		Entry("out of range", "60 * * * *"),
+		Entry("unknown name", "* * * foo *"),
+		Entry("bad step", "*/0 * * * *"),
+		Entry("reversed range", "* 5-2 * * *"),
+	)
+})
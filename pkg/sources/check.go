@@ -0,0 +1,363 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sources
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/gotidy/ptr"
+	"github.com/redhat-marketplace/datactl/pkg/clients"
+	"github.com/redhat-marketplace/datactl/pkg/clients/dataservice"
+	"github.com/redhat-marketplace/datactl/pkg/clients/ilmt"
+	"github.com/redhat-marketplace/datactl/pkg/clients/marketplace"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	dataservicev1 "github.com/redhat-marketplace/datactl/pkg/datactl/api/dataservice/v1"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+)
+
+// The steps of the checks. A check stops at its first failing step.
+const (
+	StepReachable      = "host reachable"
+	StepTLS            = "tls verified"
+	StepToken          = "service account token"
+	StepListFiles      = "list files"
+	StepTokenAccepted  = "token accepted"
+	StepQuery          = "one day query"
+	StepPullSecret     = "pull secret accepted"
+	StepSourceCreated  = "source created"
+	UploadAPICheckType = "UploadAPI"
+)
+
+// UploadAPICheckID is the upload asked for the status of to check the upload
+// API. It doesn't exist, so a reachable API with a valid pull secret answers
+// not found.
+const UploadAPICheckID = "datactl-connectivity-check"
+
+// dialTimeout bounds the connections of the reachable and TLS steps.
+const dialTimeout = 10 * time.Second
+
+// CheckStep is the outcome of a step of a check.
+type CheckStep struct {
+	Name string `json:"name"`
+
+	Passed bool `json:"passed"`
+
+	// Skipped steps weren't run because an earlier step failed.
+	Skipped bool `json:"skipped,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// CheckResult is the outcome of the check of a source or the upload API.
+type CheckResult struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Steps []CheckStep `json:"steps"`
+}
+
+// Passed returns whether every step of the check passed.
+func (r *CheckResult) Passed() bool {
+	for _, step := range r.Steps {
+		if !step.Passed {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FailedStep returns the step the check stopped at.
+func (r *CheckResult) FailedStep() (CheckStep, bool) {
+	for _, step := range r.Steps {
+		if !step.Passed && !step.Skipped {
+			return step, true
+		}
+	}
+
+	return CheckStep{}, false
+}
+
+// checker runs the steps of a check in order, skipping the steps after the
+// first failure.
+type checker struct {
+	result *CheckResult
+	failed bool
+}
+
+func newChecker(name, checkType string) *checker {
+	return &checker{result: &CheckResult{Name: name, Type: checkType, Steps: []CheckStep{}}}
+}
+
+func (c *checker) step(name string, f func() error) {
+	if c.failed {
+		c.result.Steps = append(c.result.Steps, CheckStep{Name: name, Skipped: true})
+		return
+	}
+
+	step := CheckStep{Name: name, Passed: true}
+
+	if err := f(); err != nil {
+		c.failed = true
+		step.Passed = false
+		step.Error = errorString(err)
+	}
+
+	c.result.Steps = append(c.result.Steps, step)
+}
+
+func errorString(err error) string {
+	details := errors.GetDetails(err)
+	if len(details) == 0 {
+		return err.Error()
+	}
+
+	return fmt.Sprintf("%s %+v", err.Error(), details)
+}
+
+// CheckSource checks that a source can be pulled, using the clients of the
+// config flags.
+func CheckSource(ctx context.Context, rhmConfigFlags *config.ConfigFlags, cfg *api.Config, source api.Source) CheckResult {
+	switch source.Type {
+	case api.DataService:
+		return checkDataService(ctx, rhmConfigFlags, cfg, source)
+	case api.ILMT:
+		return checkIlmt(ctx, rhmConfigFlags, cfg, source)
+	}
+
+	c := newChecker(source.Name, source.Type.String())
+	c.step(StepSourceCreated, func() error {
+		r, err := Lookup(source.Type)
+		if err != nil {
+			return err
+		}
+
+		_, err = r.New(rhmConfigFlags, nil, source)
+		return err
+	})
+
+	return *c.result
+}
+
+// checkDataService checks that the route of a data service is reachable and
+// verifies, that a service account token is minted for it, and lists a file.
+func checkDataService(ctx context.Context, rhmConfigFlags *config.ConfigFlags, cfg *api.Config, source api.Source) CheckResult {
+	c := newChecker(source.Name, source.Type.String())
+	endpoint := cfg.DataServiceEndpoints[source.Name]
+	addr := ""
+
+	c.step(StepReachable, func() error {
+		if endpoint == nil {
+			return errors.NewWithDetails("data service endpoint not found", "name", source.Name)
+		}
+
+		var err error
+		addr, err = hostPort(endpoint.Host, "443")
+		if err != nil {
+			return err
+		}
+
+		return dialReachable(ctx, addr)
+	})
+
+	c.step(StepTLS, func() error {
+		tlsConfig, err := clients.DataServiceTLSConfig(endpoint)
+		if err != nil {
+			return err
+		}
+
+		return dialTLS(ctx, addr, tlsConfig)
+	})
+
+	var client dataservice.Client
+
+	c.step(StepToken, func() error {
+		var err error
+		client, err = rhmConfigFlags.DataServiceClient(source)
+		return err
+	})
+
+	c.step(StepListFiles, func() error {
+		return client.ListFiles(ctx, dataservice.ListOptions{PageSize: ptr.Int(1)}, &dataservicev1.ListFilesResponse{})
+	})
+
+	return *c.result
+}
+
+// checkIlmt checks that the ILMT host is reachable and accepts the token, and
+// queries the usage of a single day.
+func checkIlmt(ctx context.Context, rhmConfigFlags *config.ConfigFlags, cfg *api.Config, source api.Source) CheckResult {
+	c := newChecker(source.Name, source.Type.String())
+	endpoint := cfg.ILMTEndpoints[source.Name]
+
+	c.step(StepReachable, func() error {
+		if endpoint == nil {
+			return errors.NewWithDetails("ILMT endpoint not found", "name", source.Name)
+		}
+
+		port := endpoint.Port
+		if port == "" {
+			port = "443"
+		}
+
+		addr, err := hostPort(endpoint.Host, port)
+		if err != nil {
+			return err
+		}
+
+		return dialReachable(ctx, addr)
+	})
+
+	ilmtSteps(ctx, c, func() (ilmt.Client, error) {
+		return rhmConfigFlags.IlmtClient(source)
+	})
+
+	return *c.result
+}
+
+// ilmtSteps queries the usage of yesterday, the smallest query the ILMT API
+// answers. An authentication error fails the token step, any other error the
+// query step.
+func ilmtSteps(ctx context.Context, c *checker, newClient func() (ilmt.Client, error)) {
+	var queryErr error
+
+	c.step(StepTokenAccepted, func() error {
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+
+		yesterday := time.Now().AddDate(0, 0, -1).Format(ilmt.REQUIRED_FORMAT)
+		_, _, queryErr = client.FetchUsageData(ctx, ilmt.DateRange{StartDate: yesterday, EndDate: yesterday})
+
+		if errors.Is(queryErr, ilmt.AuthError) {
+			return queryErr
+		}
+		return nil
+	})
+
+	c.step(StepQuery, func() error {
+		return queryErr
+	})
+}
+
+// CheckUploadAPI checks that the upload API is reachable and accepts the pull
+// secret.
+func CheckUploadAPI(ctx context.Context, rhmConfigFlags *config.ConfigFlags, cfg *api.Config) CheckResult {
+	c := newChecker(cfg.MarketplaceEndpoint.Host, UploadAPICheckType)
+
+	c.step(StepReachable, func() error {
+		addr, err := hostPort(cfg.MarketplaceEndpoint.Host, "443")
+		if err != nil {
+			return err
+		}
+
+		return dialReachable(ctx, addr)
+	})
+
+	uploadAPISteps(ctx, c, rhmConfigFlags.MarketplaceClient)
+
+	return *c.result
+}
+
+// uploadAPISteps asks the upload API for the status of an upload that doesn't
+// exist. Not found means the pull secret was accepted.
+func uploadAPISteps(ctx context.Context, c *checker, newClient func() (marketplace.Client, error)) {
+	c.step(StepPullSecret, func() error {
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+
+		_, err = client.Metrics().Status(ctx, UploadAPICheckID)
+		if err == nil || StatusCode(err) == http.StatusNotFound {
+			return nil
+		}
+
+		return err
+	})
+}
+
+// StatusCode returns the code detail of an error of a client, or 0.
+func StatusCode(err error) int {
+	details := errors.GetDetails(err)
+
+	for i := 0; i+1 < len(details); i += 2 {
+		if details[i] == "code" {
+			if code, ok := details[i+1].(int); ok {
+				return code
+			}
+		}
+	}
+
+	return 0
+}
+
+// hostPort returns the address of a host of the config, which may be a url
+// or a host with or without a port.
+func hostPort(host, defaultPort string) (string, error) {
+	if strings.Contains(host, "://") {
+		u, err := url.Parse(host)
+		if err != nil {
+			return "", errors.WrapWithDetails(err, "invalid host", "host", host)
+		}
+		host = u.Host
+	}
+
+	host = strings.TrimSuffix(host, "/")
+	if host == "" {
+		return "", errors.New("host is not set")
+	}
+
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host, nil
+	}
+
+	return net.JoinHostPort(host, defaultPort), nil
+}
+
+func dialReachable(ctx context.Context, addr string) error {
+	conn, err := (&net.Dialer{Timeout: dialTimeout}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return errors.WrapWithDetails(err, "host not reachable", "address", addr)
+	}
+
+	return conn.Close()
+}
+
+func dialTLS(ctx context.Context, addr string, tlsConfig *tls.Config) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.ServerName = host
+
+	dialer := &tls.Dialer{NetDialer: &net.Dialer{Timeout: dialTimeout}, Config: tlsConfig}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return errors.WrapWithDetails(err, "tls verification failed", "address", addr)
+	}
+
+	return conn.Close()
+}
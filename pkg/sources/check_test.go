@@ -0,0 +1,112 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sources
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	"github.com/redhat-marketplace/datactl/pkg/clients/ilmt"
+	"github.com/redhat-marketplace/datactl/pkg/clients/marketplace"
+)
+
+var _ = Describe("check", func() {
+	It("should skip the steps after the first failure", func() {
+		c := newChecker("source", "Test")
+		c.step("first", func() error { return nil })
+		c.step("second", func() error { return errors.New("failed") })
+		c.step("third", func() error { return nil })
+
+		Expect(c.result.Passed()).To(BeFalse())
+		Expect(c.result.Steps).To(Equal([]CheckStep{
+			{Name: "first", Passed: true},
+			{Name: "second", Error: "failed"},
+			{Name: "third", Skipped: true},
+		}))
+
+		step, ok := c.result.FailedStep()
+		Expect(ok).To(BeTrue())
+		Expect(step.Name).To(Equal("second"))
+	})
+
+	It("should fail the token step when ilmt rejects the token", func() {
+		c := newChecker("ilmt", "ILMT")
+		ilmtSteps(context.Background(), c, func() (ilmt.Client, error) {
+			return &fakeIlmt{}, nil
+		})
+		Expect(c.result.Passed()).To(BeTrue())
+
+		c = newChecker("ilmt", "ILMT")
+		ilmtSteps(context.Background(), c, func() (ilmt.Client, error) {
+			return &fakeIlmt{err: ilmt.AuthError}, nil
+		})
+		step, _ := c.result.FailedStep()
+		Expect(step.Name).To(Equal(StepTokenAccepted))
+
+		c = newChecker("ilmt", "ILMT")
+		ilmtSteps(context.Background(), c, func() (ilmt.Client, error) {
+			return &fakeIlmt{err: ilmt.ServerError}, nil
+		})
+		step, _ = c.result.FailedStep()
+		Expect(step.Name).To(Equal(StepQuery))
+	})
+
+	It("should accept the pull secret when the upload api answers", func() {
+		server := ghttp.NewServer()
+		defer server.Close()
+
+		code := http.StatusNotFound
+		server.RouteToHandler("GET", "/metering/api/v2/metrics/"+UploadAPICheckID, func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("Authorization")).To(Equal("Bearer token"))
+			w.WriteHeader(code)
+			w.Write([]byte(`{"message":"not found"}`))
+		})
+
+		newClient := func() (marketplace.Client, error) {
+			return marketplace.NewClient(&marketplace.MarketplaceConfig{URL: server.URL(), Token: "token"})
+		}
+
+		c := newChecker(server.URL(), UploadAPICheckType)
+		uploadAPISteps(context.Background(), c, newClient)
+		Expect(c.result.Passed()).To(BeTrue())
+
+		code = http.StatusUnauthorized
+		c = newChecker(server.URL(), UploadAPICheckType)
+		uploadAPISteps(context.Background(), c, newClient)
+		step, _ := c.result.FailedStep()
+		Expect(step.Name).To(Equal(StepPullSecret))
+	})
+
+	It("should check that a host is reachable", func() {
+		server := ghttp.NewServer()
+		defer server.Close()
+
+		addr, err := hostPort(server.URL(), "443")
+		Expect(err).To(Succeed())
+		Expect(dialReachable(context.Background(), addr)).To(Succeed())
+
+		other, err := hostPort("ilmt.example.com", "9081")
+		Expect(err).To(Succeed())
+		Expect(other).To(Equal("ilmt.example.com:9081"))
+
+		server.Close()
+		Expect(dialReachable(context.Background(), addr)).ToNot(Succeed())
+	})
+})
@@ -0,0 +1,128 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sources
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/pflag"
+	"k8s.io/kubectl/pkg/util/i18n"
+)
+
+// Flags of the pull command for the sources pulled by date range.
+const (
+	StartDateFlag = "start-date"
+	EndDateFlag   = "end-date"
+)
+
+const dateLayout = "2006-01-02"
+
+// UpToDateError is returned by DateRange when the pull of a source would start
+// at its last pull date and that date is after yesterday, so the source has
+// nothing new to pull.
+const UpToDateError = errors.Sentinel("source is up to date")
+
+var dateFormat = regexp.MustCompile(`((19|20)\d\d)-(0?[1-9]|1[012])-(0?[1-9]|[12][0-9]|3[01])`)
+
+// DateRangePullFlags adds the start and end date flags to the pull command.
+func DateRangePullFlags(flags *pflag.FlagSet) {
+	flags.String(StartDateFlag, EMPTY, i18n.T("Start Date"))
+	flags.String(EndDateFlag, EMPTY, i18n.T("End Date"))
+}
+
+// DateRange returns the dates of the pull of a source. The range starts at
+// the start date flag, the last pull date of the source or the date prompted
+// for, and ends at the end date flag or yesterday.
+func DateRange(req *PullRequest, lastPullDate string) (string, string, error) {
+	startDate, _ := req.Flags.GetString(StartDateFlag)
+	endDate, _ := req.Flags.GetString(EndDateFlag)
+
+	fromLastPull := false
+	if startDate == EMPTY && lastPullDate != EMPTY {
+		startDate = lastPullDate
+		fromLastPull = true
+	}
+
+	if startDate == EMPTY {
+		var err error
+		startDate, err = promptStartDate(req)
+		if err != nil {
+			return EMPTY, EMPTY, err
+		}
+
+		if startDate == EMPTY {
+			return EMPTY, EMPTY, errors.New(i18n.T("Startdate mandatory to provide in case of pulling data from source first time"))
+		}
+	}
+
+	yesterday := time.Now().AddDate(0, 0, -1)
+
+	if !dateFormat.MatchString(startDate) {
+		return EMPTY, EMPTY, errors.New(i18n.T("Startdate must be in format yyyy-mm-dd"))
+	}
+
+	start, err := time.Parse(dateLayout, startDate)
+	if err != nil {
+		return EMPTY, EMPTY, err
+	}
+
+	if start.After(yesterday) && fromLastPull {
+		return EMPTY, EMPTY, errors.WithDetails(UpToDateError, "lastPullDate", lastPullDate)
+	}
+
+	if start.After(yesterday) {
+		return EMPTY, EMPTY, errors.New(i18n.T("Start date must not be greater than yesterday date"))
+	}
+
+	if endDate == EMPTY {
+		endDate = yesterday.Format(dateLayout)
+	}
+
+	if !dateFormat.MatchString(endDate) {
+		return EMPTY, EMPTY, errors.New(i18n.T("Enddate must be in format yyyy-mm-dd"))
+	}
+
+	end, err := time.Parse(dateLayout, endDate)
+	if err != nil {
+		return EMPTY, EMPTY, err
+	}
+
+	if end.After(yesterday) || end.Before(start) {
+		return EMPTY, EMPTY, errors.New(i18n.T("End date must not be less than start date or greater than yesterday date"))
+	}
+
+	return startDate, endDate, nil
+}
+
+func promptStartDate(req *PullRequest) (string, error) {
+	prompt := promptui.Prompt{
+		Label:  fmt.Sprintf(i18n.T("Enter start date in %s format"), "yyyy-mm-dd"),
+		Stdin:  io.NopCloser(req.In),
+		Stdout: nopWCloser{req.Out},
+	}
+
+	return prompt.Run()
+}
+
+type nopWCloser struct {
+	io.Writer
+}
+
+func (nopWCloser) Close() error { return nil }
@@ -0,0 +1,243 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sources
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"emperror.dev/errors"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+const (
+	// NotRegisteredError is returned for a source with a type that is not
+	// registered.
+	NotRegisteredError = errors.Sentinel("source type not registered")
+
+	// AlreadyRegisteredError is returned when a source type is registered
+	// twice.
+	AlreadyRegisteredError = errors.Sentinel("source type already registered")
+)
+
+// Registration describes a source type to the commands. A source type is
+// registered once, usually from an init function, and is then added, pulled
+// and committed by the commands like the built in types.
+type Registration struct {
+	// Type is the source type stored in the config.
+	Type api.SourceType
+
+	// Schema describes the Properties of a source of the type in the config.
+	// Types with their own section in the config leave it empty.
+	Schema []Property
+
+	// New creates the source from its config.
+	New func(rhmConfigFlags *config.ConfigFlags, printer printers.Printer, source api.Source) (Source, error)
+
+	// AddCommand returns the subcommand of `sources add` for the type.
+	AddCommand func(rhmFlags *config.ConfigFlags, f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command
+
+	// PullFlags adds the flags of the type to the pull command. The flags
+	// are read by PullOptions. Optional.
+	PullFlags func(flags *pflag.FlagSet)
+
+	// PullOptions validates the pull flags for a source and returns the
+	// options of its pull. It's called for every source before any is pulled.
+	// Optional, the options of a pull default to the shared ones.
+	PullOptions func(req *PullRequest) (GenericOptions, error)
+
+	// Pulled is called after a source is pulled, with the error of the pull,
+	// to update the config. Optional.
+	Pulled func(cfg *api.Config, source *api.Source, err error)
+}
+
+// Property is a setting of a source type.
+type Property struct {
+	Name        string
+	Description string
+
+	// Required properties must be set on every source of the type.
+	Required bool
+
+	// Secret properties are redacted when a source is printed.
+	Secret bool
+
+	// Prefix properties describe every property with a name starting with
+	// Name, like the queries of a source.
+	Prefix bool
+}
+
+// PullRequest holds what a source type needs to build the options of a pull.
+type PullRequest struct {
+	Config *api.Config
+	Source *api.Source
+
+	// Flags of the pull command, including the ones added by PullFlags.
+	Flags *pflag.FlagSet
+
+	// Checkpoint saves the config during a pull.
+	Checkpoint CheckpointFunc
+
+	genericclioptions.IOStreams
+}
+
+var (
+	registryLock sync.RWMutex
+	registry     = map[api.SourceType]Registration{}
+)
+
+// Register adds a source type to the registry.
+func Register(r Registration) error {
+	if r.Type == "" {
+		return errors.New("source type is required")
+	}
+
+	if r.New == nil {
+		return errors.WithDetails(errors.New("source type has no constructor"), "sourceType", r.Type)
+	}
+
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if _, ok := registry[r.Type]; ok {
+		return errors.WithDetails(AlreadyRegisteredError, "sourceType", r.Type)
+	}
+
+	registry[r.Type] = r
+	api.RegisterSourceType(r.Type)
+
+	return nil
+}
+
+// MustRegister adds a source type to the registry and panics if it can't.
+func MustRegister(r Registration) {
+	if err := Register(r); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup returns the registration of a source type.
+func Lookup(sourceType api.SourceType) (Registration, error) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	r, ok := registry[sourceType]
+	if !ok {
+		return Registration{}, errors.WithDetails(NotRegisteredError, "sourceType", sourceType)
+	}
+
+	return r, nil
+}
+
+// Registrations returns the registered source types, sorted by type.
+func Registrations() []Registration {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	out := make([]Registration, 0, len(registry))
+	for _, r := range registry {
+		out = append(out, r)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Type < out[j].Type
+	})
+
+	return out
+}
+
+// ValidateProperties checks that the required properties of the schema of a
+// source are set, and that it has no properties outside of the schema.
+func (r Registration) ValidateProperties(source *api.Source) error {
+	for _, p := range r.Schema {
+		if p.Required && !p.Prefix && source.Properties[p.Name] == "" {
+			return errors.NewWithDetails("required property not set", "sourceName", source.Name, "property", p.Name)
+		}
+
+		if p.Required && p.Prefix && len(source.PropertiesWithPrefix(p.Name)) == 0 {
+			return errors.NewWithDetails("required property not set", "sourceName", source.Name, "property", p.Name+"*")
+		}
+	}
+
+	for name := range source.Properties {
+		if _, ok := r.Property(name); !ok {
+			return errors.NewWithDetails("unknown property", "sourceName", source.Name, "property", name)
+		}
+	}
+
+	return nil
+}
+
+// Property returns the property of the schema describing a property name.
+func (r Registration) Property(name string) (Property, bool) {
+	for _, p := range r.Schema {
+		if p.Name == name || p.Prefix && strings.HasPrefix(name, p.Name) {
+			return p, true
+		}
+	}
+
+	return Property{}, false
+}
+
+// SecretProperties returns the names of the properties of a source that are
+// secret in the schema of its type. Sources of types that are not registered
+// have none.
+func SecretProperties(source *api.Source) []string {
+	r, err := Lookup(source.Type)
+	if err != nil {
+		return nil
+	}
+
+	names := []string{}
+	for name := range source.Properties {
+		if p, ok := r.Property(name); ok && p.Secret {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// PullOptionsFor validates the properties of a source and returns the options
+// of its pull: the options of the type with the SourceName and Checkpoint
+// options added.
+func (r Registration) PullOptionsFor(req *PullRequest) (GenericOptions, error) {
+	if err := r.ValidateProperties(req.Source); err != nil {
+		return nil, err
+	}
+
+	opts := EmptyOptions()
+
+	if r.PullOptions != nil {
+		var err error
+		opts, err = r.PullOptions(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return WithOptions(opts,
+		SourceName, req.Source.Name,
+		Checkpoint, req.Checkpoint,
+	), nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sources
+
+import (
+	"emperror.dev/errors"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers"
+	"github.com/spf13/pflag"
+)
+
+var _ = Describe("registry", func() {
+	const inHouse api.SourceType = "InHouse"
+
+	newInHouse := func(*config.ConfigFlags, printers.Printer, api.Source) (Source, error) {
+		return nil, nil
+	}
+
+	BeforeEach(func() {
+		registryLock.Lock()
+		delete(registry, inHouse)
+		registryLock.Unlock()
+	})
+
+	It("should register a source type", func() {
+		Expect(Register(Registration{Type: inHouse, New: newInHouse})).To(Succeed())
+
+		r, err := Lookup(inHouse)
+		Expect(err).To(Succeed())
+		Expect(r.Type).To(Equal(inHouse))
+
+		types := []api.SourceType{}
+		for _, r := range Registrations() {
+			types = append(types, r.Type)
+		}
+		Expect(types).To(ContainElement(inHouse))
+
+		var sourceType api.SourceType
+		Expect(sourceType.UnmarshalText([]byte(inHouse))).To(Succeed())
+		Expect(sourceType).To(Equal(inHouse))
+	})
+
+	It("should not register a source type twice", func() {
+		Expect(Register(Registration{Type: inHouse, New: newInHouse})).To(Succeed())
+
+		err := Register(Registration{Type: inHouse, New: newInHouse})
+		Expect(errors.Is(err, AlreadyRegisteredError)).To(BeTrue())
+	})
+
+	It("should require a constructor", func() {
+		Expect(Register(Registration{Type: inHouse})).ToNot(Succeed())
+	})
+
+	It("should fail to create a source of a type not registered", func() {
+		factory := (&SourceFactoryBuilder{}).Build()
+
+		_, err := factory.FromSource(api.Source{Name: "foo", Type: inHouse})
+		Expect(errors.Is(err, NotRegisteredError)).To(BeTrue())
+	})
+
+	Context("pull options", func() {
+		var (
+			r     Registration
+			flags *pflag.FlagSet
+		)
+
+		BeforeEach(func() {
+			r = Registration{
+				Type: inHouse,
+				New:  newInHouse,
+				Schema: []Property{
+					{Name: "path", Required: true},
+					{Name: "token", Secret: true},
+				},
+				PullFlags: func(flags *pflag.FlagSet) {
+					flags.Int("batch", 10, "")
+				},
+				PullOptions: func(req *PullRequest) (GenericOptions, error) {
+					batch, err := req.Flags.GetInt("batch")
+					if err != nil {
+						return nil, err
+					}
+
+					return NewOptions("batch", batch), nil
+				},
+			}
+
+			flags = pflag.NewFlagSet("pull", pflag.ContinueOnError)
+			r.PullFlags(flags)
+		})
+
+		It("should add the shared options to the options of the type", func() {
+			Expect(flags.Set("batch", "3")).To(Succeed())
+
+			checkpoints := 0
+
+			opts, err := r.PullOptionsFor(&PullRequest{
+				Source:     &api.Source{Name: "foo", Type: inHouse, Properties: map[string]string{"path": "/data"}},
+				Flags:      flags,
+				Checkpoint: func() error { checkpoints++; return nil },
+			})
+			Expect(err).To(Succeed())
+
+			batch, _, _ := opts.GetInt("batch")
+			Expect(batch).To(Equal(3))
+
+			name, _, _ := opts.GetString(SourceName)
+			Expect(name).To(Equal("foo"))
+
+			checkpoint, err := getCheckpoint(opts)
+			Expect(err).To(Succeed())
+			Expect(checkpoint()).To(Succeed())
+			Expect(checkpoints).To(Equal(1))
+		})
+
+		It("should fail without a required property", func() {
+			_, err := r.PullOptionsFor(&PullRequest{
+				Source: &api.Source{Name: "foo", Type: inHouse},
+				Flags:  flags,
+			})
+			Expect(err).To(MatchError(ContainSubstring("required property not set")))
+		})
+
+		It("should fail with a property outside of the schema", func() {
+			_, err := r.PullOptionsFor(&PullRequest{
+				Source: &api.Source{Name: "foo", Type: inHouse, Properties: map[string]string{"path": "/data", "other": "x"}},
+				Flags:  flags,
+			})
+			Expect(err).To(MatchError(ContainSubstring("unknown property")))
+		})
+	})
+})
@@ -2,6 +2,11 @@ package sources
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gotidy/ptr"
@@ -9,7 +14,10 @@ import (
 	"github.com/redhat-marketplace/datactl/pkg/clients/dataservice"
 	"github.com/redhat-marketplace/datactl/pkg/datactl/api"
 	dataservicev1 "github.com/redhat-marketplace/datactl/pkg/datactl/api/dataservice/v1"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
 	"github.com/redhat-marketplace/datactl/pkg/printers"
+	"github.com/spf13/pflag"
+	"k8s.io/kubectl/pkg/util/i18n"
 )
 
 type dataServiceSource struct {
@@ -22,12 +30,25 @@ type dataServiceOptions struct {
 }
 
 const (
-	IncludeDeleted string = "includeDeleted"
-	BeforeDate            = "beforeDate"
-	AfterDate             = "afterDate"
-	DryRun                = "dryRun"
+	IncludeDeleted  string = "includeDeleted"
+	BeforeDate             = "beforeDate"
+	AfterDate              = "afterDate"
+	DryRun                 = "dryRun"
+	Force                  = "force"
+	CommitFileNames        = "commitFileNames"
+	CommitFileIDs          = "commitFileIds"
+	Concurrency            = "concurrency"
+	SourceName             = "sourceName"
+	Checkpoint             = "checkpoint"
 )
 
+const DefaultConcurrency = 4
+
+// CheckpointFunc saves the state of the export. It is passed to a pull with
+// the Checkpoint option and called after each file is added to the bundle, so
+// an interrupted pull can be resumed.
+type CheckpointFunc func() error
+
 func NewDataServiceOptions(includeDeleted bool, beforeDate, afterDate time.Time, dryRun bool) GenericOptions {
 	return NewOptions(
 		IncludeDeleted, includeDeleted,
@@ -49,21 +70,72 @@ func NewDataService(
 	return d, nil
 }
 
+// NewDataServiceFromConfig creates a dataservice source with the client of its
+// config.
+func NewDataServiceFromConfig(rhmConfigFlags *config.ConfigFlags, printer printers.Printer, source api.Source) (Source, error) {
+	client, err := rhmConfigFlags.DataServiceClient(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewDataService(client, printer)
+}
+
+// ConcurrencyFlag is the flag of the pull command for the number of files
+// downloaded at once from a dataservice source.
+const ConcurrencyFlag = "concurrency"
+
+func DataServicePullFlags(flags *pflag.FlagSet) {
+	flags.Int(ConcurrencyFlag, DefaultConcurrency, i18n.T("number of files downloaded in parallel from dataservice sources"))
+}
+
+func DataServicePullOptions(req *PullRequest) (GenericOptions, error) {
+	concurrency, err := req.Flags.GetInt(ConcurrencyFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	if concurrency < 1 {
+		return nil, fmt.Errorf("concurrency must be greater than 0")
+	}
+
+	return NewOptions(Concurrency, concurrency), nil
+}
+
 func (d *dataServiceSource) Commit(
 	ctx context.Context,
 	currentMeteringExport *api.MeteringExport,
 	bundle *bundle.BundleFile,
 	opts GenericOptions) (int, error) {
 
-	dryRun, _, err := opts.GetBool(DryRun)
+	commitOpts, err := commitOptionsFrom(opts)
 	if err != nil {
 		return 0, err
 	}
 
+	dryRun := commitOpts.dryRun
+
 	errs := map[string]error{}
 	committed := 0
 
 	for _, file := range currentMeteringExport.Files {
+		// files without an id were not pulled from a dataservice
+		if file.FileInfo == nil || file.Id == "" {
+			continue
+		}
+
+		if !commitOpts.selected(file) {
+			continue
+		}
+
+		if !file.Committed && commitOpts.skip(file) {
+			d.TableOutput(func(po printers.PrintObj) {
+				po.Print(file)
+			})
+
+			continue
+		}
+
 		file.Action = dataservicev1.Commit
 		file.Result = dataservicev1.Ok
 
@@ -116,6 +188,10 @@ func (d *dataServiceSource) Commit(
 		return committed, err
 	}
 
+	if len(errs) != 0 {
+		return committed, fmt.Errorf("failed to commit %d files", len(errs))
+	}
+
 	return committed, nil
 }
 
@@ -147,7 +223,36 @@ func (d *dataServiceSource) Pull(
 		AfterDate:      afterDate,
 	}
 
-	files := []*dataservicev1.FileInfoCTLAction{}
+	concurrency, ok, err := options.GetInt(Concurrency)
+	if err != nil {
+		return 0, err
+	}
+
+	if !ok || concurrency < 1 {
+		concurrency = DefaultConcurrency
+	}
+
+	sourceName, _, err := options.GetString(SourceName)
+	if err != nil {
+		return 0, err
+	}
+
+	checkpoint, err := getCheckpoint(options)
+	if err != nil {
+		return 0, err
+	}
+
+	files := newExportFiles(currentMeteringExport)
+	resumed := false
+
+	if c, ok := currentMeteringExport.PullCheckpoints[sourceName]; ok && sourceName != "" && c != nil {
+		listOpts.PageToken = c.PageToken
+		if c.PageSize != 0 {
+			listOpts.PageSize = ptr.Int(int(c.PageSize))
+		}
+		resumed = listOpts.PageToken != ""
+	}
+
 	errs := map[string]error{}
 	found := 0
 	pulled := 0
@@ -155,26 +260,51 @@ func (d *dataServiceSource) Pull(
 	for {
 		err := d.dataService.ListFiles(ctx, listOpts, &response)
 
+		if err != nil && resumed {
+			// the page token of the checkpoint may have expired, start over
+			// and skip the files already pulled
+			resumed = false
+			listOpts.PageToken = ""
+			listOpts.PageSize = nil
+			continue
+		}
+
 		if err != nil {
-			return 0, err
+			return found, err
 		}
 
+		resumed = false
+
+		page := make([]*dataservicev1.FileInfoCTLAction, 0, len(response.Files))
+		download := make([]*dataservicev1.FileInfoCTLAction, 0, len(response.Files))
+
 		for i := range response.Files {
 			cliFile := dataservicev1.NewFileInfoCTLAction(response.Files[i])
-			files = append(files, cliFile)
-			found = found + 1
+			page = append(page, cliFile)
 
-			w, err := bundle.NewFile(cliFile.Name, int64(cliFile.Size))
-			if err != nil {
-				return 0, err
+			if !files.pulled(cliFile) {
+				download = append(download, cliFile)
+			}
+		}
+
+		found = found + len(page)
+
+		spools := d.download(ctx, download, concurrency)
+
+		// files are appended in list order so the bundle does not depend on
+		// which download finished first
+		for i, cliFile := range download {
+			err := spools[i].err
+			if err == nil {
+				err = verifyChecksum(cliFile.Checksum, spools[i].checksum)
 			}
 
-			_, err = d.dataService.DownloadFile(ctx, cliFile.Id, w)
 			if err != nil {
 				cliFile.Action = dataservicev1.Pull
 				cliFile.Result = dataservicev1.Error
 				cliFile.Error = err.Error()
 				errs[cliFile.Name] = err
+				files.set(cliFile)
 
 				d.TableOutput(func(tosp printers.PrintObj) {
 					tosp.Print(cliFile)
@@ -182,47 +312,266 @@ func (d *dataServiceSource) Pull(
 				continue
 			}
 
+			cliFile.VerifiedChecksum = spools[i].checksum
+
+			if err := spools[i].appendTo(bundle, cliFile, currentMeteringExport.DisplayName()); err != nil {
+				closeSpools(spools)
+				return found, err
+			}
+
+			if err := bundle.Flush(); err != nil {
+				closeSpools(spools)
+				return found, err
+			}
+
 			cliFile.Action = dataservicev1.Pull
 			cliFile.Result = dataservicev1.Ok
+			cliFile.Error = ""
 			pulled = pulled + 1
+			files.set(cliFile)
+
+			if err := checkpoint(); err != nil {
+				closeSpools(spools)
+				return found, err
+			}
 
 			d.TableOutput(func(tosp printers.PrintObj) {
 				tosp.Print(cliFile)
 			})
 		}
 
+		closeSpools(spools)
+
 		if response.NextPageToken == "" {
 			break
 		}
 
 		listOpts.PageSize = ptr.Int(int(response.PageSize))
 		listOpts.PageToken = response.NextPageToken
+
+		if sourceName != "" {
+			if currentMeteringExport.PullCheckpoints == nil {
+				currentMeteringExport.PullCheckpoints = map[string]*dataservicev1.PullCheckpoint{}
+			}
+
+			currentMeteringExport.PullCheckpoints[sourceName] = &dataservicev1.PullCheckpoint{
+				PageToken: response.NextPageToken,
+				PageSize:  response.PageSize,
+			}
+		}
+
+		if err := checkpoint(); err != nil {
+			return found, err
+		}
+	}
+
+	delete(currentMeteringExport.PullCheckpoints, sourceName)
+
+	if err := checkpoint(); err != nil {
+		return found, err
+	}
+
+	return found, nil
+}
+
+func getCheckpoint(options GenericOptions) (CheckpointFunc, error) {
+	v, ok, err := options.Get(Checkpoint)
+	if err != nil || !ok || v == nil {
+		return func() error { return nil }, err
 	}
 
-	filesMap := map[string]*dataservicev1.FileInfoCTLAction{}
-	fileNames := map[string]interface{}{}
+	checkpoint, ok := v.(CheckpointFunc)
+	if !ok {
+		return nil, fmt.Errorf("failed to convert type %T to CheckpointFunc", v)
+	}
+
+	if checkpoint == nil {
+		return func() error { return nil }, nil
+	}
+
+	return checkpoint, nil
+}
+
+// exportFiles updates the files of an export in place as they are pulled, so
+// the export can be saved after each file.
+type exportFiles struct {
+	export *api.MeteringExport
+	index  map[string]int
+}
+
+// newExportFiles indexes the files of the export, dropping the files that were
+// pushed and committed.
+func newExportFiles(export *api.MeteringExport) *exportFiles {
+	files := &exportFiles{
+		export: export,
+		index:  map[string]int{},
+	}
 
-	for _, f := range currentMeteringExport.Files {
+	existing := export.Files
+	export.Files = make([]*dataservicev1.FileInfoCTLAction, 0, len(existing))
+
+	for _, f := range existing {
 		if f.Committed && f.Pushed {
 			continue
 		}
 
-		filesMap[f.Name+f.Source+f.SourceType] = f
-		fileNames[f.Name] = nil
+		files.set(f)
+	}
+
+	return files
+}
+
+// indexExportFiles indexes the files of the export.
+func indexExportFiles(export *api.MeteringExport) *exportFiles {
+	files := &exportFiles{
+		export: export,
+		index:  map[string]int{},
+	}
+
+	for i, f := range export.Files {
+		files.index[fileKey(f)] = i
+	}
+
+	return files
+}
+
+func fileKey(f *dataservicev1.FileInfoCTLAction) string {
+	return f.Name + f.Source + f.SourceType
+}
+
+func (e *exportFiles) set(f *dataservicev1.FileInfoCTLAction) {
+	key := fileKey(f)
+
+	if i, ok := e.index[key]; ok {
+		e.export.Files[i] = f
+		return
+	}
+
+	e.index[key] = len(e.export.Files)
+	e.export.Files = append(e.export.Files, f)
+}
+
+func (e *exportFiles) get(key string) *dataservicev1.FileInfoCTLAction {
+	if i, ok := e.index[key]; ok {
+		return e.export.Files[i]
 	}
 
-	for _, f := range files {
-		filesMap[f.Name+f.Source+f.SourceType] = f
-		fileNames[f.Name] = nil
+	return nil
+}
+
+// pulled returns true if the file was already downloaded and verified by a
+// previous pull of the export.
+func (e *exportFiles) pulled(f *dataservicev1.FileInfoCTLAction) bool {
+	existing := e.get(fileKey(f))
+	if existing == nil {
+		return false
 	}
 
-	currentMeteringExport.Files = make([]*dataservicev1.FileInfoCTLAction, 0, len(filesMap))
+	if existing.Id != f.Id || existing.VerifiedChecksum == "" || existing.Result == dataservicev1.Error {
+		return false
+	}
+
+	return verifyChecksum(f.Checksum, existing.VerifiedChecksum) == nil
+}
 
-	for i := range filesMap {
-		currentMeteringExport.Files = append(currentMeteringExport.Files, filesMap[i])
+// download fetches the files into spool files using at most concurrency
+// workers. The returned spools are in the same order as files.
+func (d *dataServiceSource) download(
+	ctx context.Context,
+	files []*dataservicev1.FileInfoCTLAction,
+	concurrency int,
+) []*spoolFile {
+	spools := make([]*spoolFile, len(files))
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+
+	for i := range files {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			spools[i] = d.downloadToSpool(ctx, files[i])
+		}(i)
 	}
 
-	return len(files), nil
+	wg.Wait()
+	return spools
+}
+
+func (d *dataServiceSource) downloadToSpool(ctx context.Context, file *dataservicev1.FileInfoCTLAction) *spoolFile {
+	spool := &spoolFile{}
+
+	spool.file, spool.err = os.CreateTemp("", "datactl-spool-*")
+	if spool.err != nil {
+		return spool
+	}
+
+	spool.checksum, spool.err = d.dataService.DownloadFile(ctx, file.Id, spool.file)
+	return spool
+}
+
+// verifyChecksum compares the SHA-256 computed on download with the checksum
+// reported by the dataservice. Files without a reported checksum are accepted.
+func verifyChecksum(expected, actual string) error {
+	expected = strings.TrimPrefix(strings.ToLower(expected), "sha256:")
+
+	if expected == "" {
+		return nil
+	}
+
+	if expected != strings.ToLower(actual) {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", expected, actual)
+	}
+
+	return nil
+}
+
+// spoolFile holds a downloaded file on disk until it is appended to the
+// bundle.
+type spoolFile struct {
+	file     *os.File
+	checksum string
+	err      error
+}
+
+// appendTo copies the spooled file into the bundle with its metadata.
+func (s *spoolFile) appendTo(b *bundle.BundleFile, file *dataservicev1.FileInfoCTLAction, exportName string) error {
+	size, err := s.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	w, err := b.NewFileWithMetadata(file.Name, size, bundle.NewFileMetadata(file, exportName))
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, s.file)
+	return err
+}
+
+func (s *spoolFile) Close() error {
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.file.Close()
+	os.Remove(s.file.Name())
+	s.file = nil
+	return err
+}
+
+func closeSpools(spools []*spoolFile) {
+	for _, spool := range spools {
+		spool.Close()
+	}
 }
 
 func (d *dataServiceSource) GetResponse() string {
@@ -0,0 +1,352 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sources
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-marketplace/datactl/pkg/bundle"
+	"github.com/redhat-marketplace/datactl/pkg/clients/dataservice"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	dataservicev1 "github.com/redhat-marketplace/datactl/pkg/datactl/api/dataservice/v1"
+	"github.com/redhat-marketplace/datactl/pkg/printers"
+	"k8s.io/kubectl/pkg/cmd/get"
+)
+
+type fakeDataService struct {
+	dataservice.Client
+
+	pages   [][]*dataservicev1.FileInfo
+	failIds map[string]bool
+
+	// failTokens fails the listing of a page token the number of times set
+	failTokens map[string]int
+	tokens     []string
+
+	mu         sync.Mutex
+	running    int
+	max        int
+	downloaded []string
+	deleted    []string
+}
+
+func (f *fakeDataService) DeleteFile(ctx context.Context, id string) error {
+	f.deleted = append(f.deleted, id)
+	return nil
+}
+
+func (f *fakeDataService) ListFiles(ctx context.Context, opts dataservice.ListOptions, files *dataservicev1.ListFilesResponse) error {
+	f.tokens = append(f.tokens, opts.PageToken)
+
+	if f.failTokens[opts.PageToken] > 0 {
+		f.failTokens[opts.PageToken] = f.failTokens[opts.PageToken] - 1
+		return fmt.Errorf("connection reset")
+	}
+
+	page := 0
+	if opts.PageToken != "" {
+		fmt.Sscanf(opts.PageToken, "%d", &page)
+	}
+
+	files.Files = f.pages[page]
+	files.NextPageToken = ""
+	files.PageSize = int32(len(f.pages[page]))
+
+	if page+1 < len(f.pages) {
+		files.NextPageToken = fmt.Sprintf("%d", page+1)
+	}
+
+	return nil
+}
+
+func (f *fakeDataService) DownloadFile(ctx context.Context, id string, w io.Writer) (string, error) {
+	f.mu.Lock()
+	f.running = f.running + 1
+	if f.running > f.max {
+		f.max = f.running
+	}
+	f.downloaded = append(f.downloaded, id)
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		f.running = f.running - 1
+		f.mu.Unlock()
+	}()
+
+	// later files finish first
+	time.Sleep(time.Duration(10-len(id)) * 5 * time.Millisecond)
+
+	if f.failIds[id] {
+		return "", fmt.Errorf("download failed")
+	}
+
+	data := []byte("data-" + id)
+	if _, err := w.Write(data); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+func newFileInfo(id string) *dataservicev1.FileInfo {
+	file := &dataservicev1.FileInfo{Id: id}
+	file.Name = "file-" + id
+	return file
+}
+
+var _ = Describe("dataservice source", func() {
+	var (
+		fake       *fakeDataService
+		bundleFile *bundle.BundleFile
+		export     *api.MeteringExport
+		sut        Source
+	)
+
+	BeforeEach(func() {
+		fake = &fakeDataService{
+			pages: [][]*dataservicev1.FileInfo{
+				{newFileInfo("1"), newFileInfo("22"), newFileInfo("333")},
+				{newFileInfo("4444"), newFileInfo("55555")},
+			},
+			failIds: map[string]bool{"22": true},
+		}
+
+		var err error
+		bundleFile, err = bundle.NewBundle(filepath.Join(GinkgoT().TempDir(), "bundle.tar"))
+		Expect(err).To(Succeed())
+
+		printer, err := printers.NewPrinter(io.Discard, get.NewGetPrintFlags())
+		Expect(err).To(Succeed())
+
+		sut, err = NewDataService(fake, printer)
+		Expect(err).To(Succeed())
+
+		export = &api.MeteringExport{FileName: bundleFile.Name()}
+	})
+
+	It("should download in parallel and append in list order", func() {
+		count, err := sut.Pull(context.Background(), export, bundleFile, NewOptions(Concurrency, 2))
+		Expect(err).To(Succeed())
+		Expect(count).To(Equal(5))
+		Expect(fake.max).To(Equal(2))
+		Expect(bundleFile.Close()).To(Succeed())
+
+		names := []string{}
+		contents := []string{}
+		ids := []string{}
+		Expect(bundle.WalkTar(bundleFile.Name(), func(header *tar.Header, r io.Reader) error {
+			data, err := io.ReadAll(r)
+			names = append(names, header.Name)
+			contents = append(contents, string(data))
+
+			m, ok := bundle.MetadataFromHeader(header)
+			Expect(ok).To(BeTrue())
+			Expect(m.ExportName).To(Equal("bundle.tar"))
+			ids = append(ids, m.ID)
+			return err
+		})).To(Succeed())
+
+		Expect(ids).To(Equal([]string{"1", "333", "4444", "55555"}))
+
+		Expect(names).To(Equal([]string{"file-1", "file-333", "file-4444", "file-55555"}))
+		Expect(contents).To(Equal([]string{"data-1", "data-333", "data-4444", "data-55555"}))
+
+		results := map[string]dataservicev1.Result{}
+		for _, file := range export.Files {
+			results[file.Name] = file.Result
+		}
+		Expect(results).To(HaveKeyWithValue("file-22", dataservicev1.Error))
+		Expect(results).To(HaveKeyWithValue("file-333", dataservicev1.Ok))
+	})
+
+	It("should verify the checksum reported by the dataservice", func() {
+		fake.pages[0][0].Checksum = fmt.Sprintf("sha256:%X", sha256.Sum256([]byte("data-1")))
+		fake.pages[0][2].Checksum = "bad"
+
+		_, err := sut.Pull(context.Background(), export, bundleFile, EmptyOptions())
+		Expect(err).To(Succeed())
+		Expect(bundleFile.Close()).To(Succeed())
+
+		files := map[string]*dataservicev1.FileInfoCTLAction{}
+		for _, file := range export.Files {
+			files[file.Name] = file
+		}
+
+		Expect(files["file-1"].Result).To(Equal(dataservicev1.Ok))
+		Expect(files["file-1"].VerifiedChecksum).To(Equal(fmt.Sprintf("%x", sha256.Sum256([]byte("data-1")))))
+		Expect(files["file-333"].Result).To(Equal(dataservicev1.Error))
+		Expect(files["file-333"].Error).To(ContainSubstring("checksum mismatch"))
+		Expect(files["file-333"].VerifiedChecksum).To(BeEmpty())
+
+		names := []string{}
+		Expect(bundle.WalkTar(bundleFile.Name(), func(header *tar.Header, r io.Reader) error {
+			names = append(names, header.Name)
+			return nil
+		})).To(Succeed())
+		Expect(names).ToNot(ContainElement("file-333"))
+	})
+
+	It("should remove the spool files", func() {
+		tmp := GinkgoT().TempDir()
+		os.Setenv("TMPDIR", tmp)
+		defer os.Unsetenv("TMPDIR")
+
+		_, err := sut.Pull(context.Background(), export, bundleFile, EmptyOptions())
+		Expect(err).To(Succeed())
+		Expect(bundleFile.Close()).To(Succeed())
+
+		entries, err := os.ReadDir(tmp)
+		Expect(err).To(Succeed())
+		Expect(entries).To(BeEmpty())
+	})
+
+	It("should save the export after each file and resume from the checkpoint", func() {
+		fake.failIds = map[string]bool{}
+		fake.failTokens = map[string]int{"1": 1}
+
+		checkpoints := 0
+		opts := func() GenericOptions {
+			return NewOptions(
+				SourceName, "ds",
+				Checkpoint, CheckpointFunc(func() error {
+					checkpoints = checkpoints + 1
+					return nil
+				}),
+			)
+		}
+
+		_, err := sut.Pull(context.Background(), export, bundleFile, opts())
+		Expect(err).To(MatchError("connection reset"))
+		Expect(checkpoints).To(Equal(4))
+		Expect(export.Files).To(HaveLen(3))
+		Expect(export.PullCheckpoints).To(HaveKeyWithValue("ds", &dataservicev1.PullCheckpoint{PageToken: "1", PageSize: 3}))
+
+		fake.tokens = nil
+		fake.downloaded = nil
+
+		count, err := sut.Pull(context.Background(), export, bundleFile, opts())
+		Expect(err).To(Succeed())
+		Expect(count).To(Equal(2))
+		Expect(fake.tokens).To(Equal([]string{"1"}))
+		Expect(fake.downloaded).To(ConsistOf("4444", "55555"))
+		Expect(export.PullCheckpoints).ToNot(HaveKey("ds"))
+		Expect(bundleFile.Close()).To(Succeed())
+
+		names := []string{}
+		for _, file := range export.Files {
+			names = append(names, file.Name)
+		}
+		Expect(names).To(Equal([]string{"file-1", "file-22", "file-333", "file-4444", "file-55555"}))
+	})
+
+	It("should skip files already pulled and verified", func() {
+		_, err := sut.Pull(context.Background(), export, bundleFile, EmptyOptions())
+		Expect(err).To(Succeed())
+
+		for _, file := range export.Files {
+			if file.Name == "file-1" {
+				file.Pushed = true
+			}
+		}
+
+		fake.downloaded = nil
+
+		_, err = sut.Pull(context.Background(), export, bundleFile, EmptyOptions())
+		Expect(err).To(Succeed())
+		Expect(fake.downloaded).To(ConsistOf("22"))
+		Expect(bundleFile.Close()).To(Succeed())
+
+		for _, file := range export.Files {
+			if file.Name == "file-1" {
+				Expect(file.Pushed).To(BeTrue())
+			}
+		}
+	})
+
+	It("should start over when the checkpoint can't be listed", func() {
+		export.PullCheckpoints = map[string]*dataservicev1.PullCheckpoint{"ds": {PageToken: "expired"}}
+		fake.failTokens = map[string]int{"expired": 1}
+
+		count, err := sut.Pull(context.Background(), export, bundleFile, NewOptions(SourceName, "ds"))
+		Expect(err).To(Succeed())
+		Expect(count).To(Equal(5))
+		Expect(fake.tokens).To(Equal([]string{"expired", "", "1"}))
+		Expect(export.PullCheckpoints).ToNot(HaveKey("ds"))
+		Expect(bundleFile.Close()).To(Succeed())
+	})
+
+	Context("commit", func() {
+		var (
+			commitSut CommitableSource
+			files     []*dataservicev1.FileInfoCTLAction
+		)
+
+		BeforeEach(func() {
+			files = []*dataservicev1.FileInfoCTLAction{}
+			for _, id := range []string{"1", "22", "333"} {
+				file := dataservicev1.NewFileInfoCTLAction(newFileInfo(id))
+				file.Pushed = id != "22"
+				files = append(files, file)
+			}
+
+			export.Files = files
+			commitSut = sut.(CommitableSource)
+		})
+
+		It("should skip the files that are not pushed", func() {
+			count, err := commitSut.Commit(context.Background(), export, bundleFile, EmptyOptions())
+			Expect(err).To(Succeed())
+			Expect(count).To(Equal(2))
+			Expect(fake.deleted).To(Equal([]string{"1", "333"}))
+			Expect(files[1].Committed).To(BeFalse())
+			Expect(files[1].Result).To(Equal(dataservicev1.Skipped))
+		})
+
+		It("should commit the files that are not pushed when forced", func() {
+			count, err := commitSut.Commit(context.Background(), export, bundleFile, NewOptions(Force, true))
+			Expect(err).To(Succeed())
+			Expect(count).To(Equal(3))
+			Expect(files[1].Committed).To(BeTrue())
+		})
+
+		It("should commit the selected files only", func() {
+			count, err := commitSut.Commit(context.Background(), export, bundleFile,
+				NewOptions(CommitFileNames, []string{"file-1"}, CommitFileIDs, []string{"333"}))
+			Expect(err).To(Succeed())
+			Expect(count).To(Equal(2))
+			Expect(fake.deleted).To(Equal([]string{"1", "333"}))
+		})
+
+		It("should not delete files on a dry run", func() {
+			count, err := commitSut.Commit(context.Background(), export, bundleFile, NewOptions(DryRun, true))
+			Expect(err).To(Succeed())
+			Expect(count).To(Equal(0))
+			Expect(fake.deleted).To(BeEmpty())
+			Expect(files[0].Result).To(Equal(dataservicev1.DryRun))
+		})
+	})
+})
@@ -0,0 +1,417 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sources
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/redhat-marketplace/datactl/pkg/bundle"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	dataservicev1 "github.com/redhat-marketplace/datactl/pkg/datactl/api/dataservice/v1"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Filesystem is the source type of a directory products write their usage
+// report archives to.
+const Filesystem api.SourceType = "Filesystem"
+
+// Properties of a filesystem source.
+const (
+	// PathProperty is the directory the reports are written to.
+	PathProperty = "path"
+
+	// PatternProperty is the glob the names of the reports match.
+	PatternProperty = "pattern"
+
+	// ArchivePathProperty is the directory reports are moved to once they
+	// are committed. Committed reports are deleted if it's not set.
+	ArchivePathProperty = "archive-path"
+)
+
+const (
+	DefaultFilesystemPattern = "*.tar.gz"
+
+	filesystemSourceType = "filesystem"
+)
+
+// FilesystemSchema describes the properties of a filesystem source.
+var FilesystemSchema = []Property{
+	{Name: PathProperty, Description: "directory the usage reports are written to", Required: true},
+	{Name: PatternProperty, Description: "glob the names of the usage reports match"},
+	{Name: ArchivePathProperty, Description: "directory committed reports are moved to, instead of being deleted"},
+}
+
+type filesystemSource struct {
+	printers.TablePrinter
+
+	name        string
+	path        string
+	pattern     string
+	archivePath string
+}
+
+// NewFilesystemSourceFromConfig creates a filesystem source from the
+// properties of its config.
+func NewFilesystemSourceFromConfig(rhmConfigFlags *config.ConfigFlags, printer printers.Printer, source api.Source) (Source, error) {
+	return NewFilesystemSource(source, printer)
+}
+
+func NewFilesystemSource(
+	source api.Source,
+	printer printers.TablePrinter,
+) (CommitableSource, error) {
+	f := &filesystemSource{
+		TablePrinter: printer,
+		name:         source.Name,
+		path:         source.Properties[PathProperty],
+		pattern:      source.Properties[PatternProperty],
+		archivePath:  source.Properties[ArchivePathProperty],
+	}
+
+	if f.path == "" {
+		return nil, errors.NewWithDetails("filesystem source has no path", "sourceName", source.Name)
+	}
+
+	if f.pattern == "" {
+		f.pattern = DefaultFilesystemPattern
+	}
+
+	if err := ValidateFilesystemPattern(f.pattern); err != nil {
+		return nil, errors.WithDetails(err, "sourceName", source.Name)
+	}
+
+	return f, nil
+}
+
+// ValidateFilesystemPattern checks the pattern is a glob of file names in the
+// directory of the source.
+func ValidateFilesystemPattern(pattern string) error {
+	if strings.ContainsRune(pattern, filepath.Separator) || strings.ContainsRune(pattern, '/') {
+		return errors.NewWithDetails("pattern must match file names, not paths", "pattern", pattern)
+	}
+
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return errors.WithDetails(err, "pattern", pattern)
+	}
+
+	return nil
+}
+
+func (f *filesystemSource) GetResponse() string {
+	return ""
+}
+
+// Pull adds the files of the directory that match the pattern to the bundle.
+// Files already pulled with the same content are skipped.
+func (f *filesystemSource) Pull(
+	ctx context.Context,
+	currentMeteringExport *api.MeteringExport,
+	bundleFile *bundle.BundleFile,
+	options GenericOptions,
+) (int, error) {
+	checkpoint, err := getCheckpoint(options)
+	if err != nil {
+		return 0, err
+	}
+
+	names, err := f.match()
+	if err != nil {
+		return 0, err
+	}
+
+	files := indexExportFiles(currentMeteringExport)
+	errs := map[string]error{}
+	pulled := 0
+
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return pulled, err
+		}
+
+		cliFile := &dataservicev1.FileInfoCTLAction{
+			Action: dataservicev1.Pull,
+			FileInfo: &dataservicev1.FileInfo{
+				Source:     f.name,
+				SourceType: filesystemSourceType,
+				MimeType:   mimeType(name),
+			},
+		}
+		cliFile.Name = name
+
+		if other := nameTakenBy(currentMeteringExport, cliFile); other != nil {
+			err := errors.NewWithDetails("file name already pulled from another source", "file", name, "otherSource", other.Source)
+
+			// not recorded in the export, push looks files up by name
+			cliFile.Result = dataservicev1.Error
+			cliFile.Error = err.Error()
+			errs[name] = err
+
+			f.TableOutput(func(po printers.PrintObj) {
+				po.Print(cliFile)
+			})
+			continue
+		}
+
+		spool, size, modTime := f.spool(name)
+
+		if spool.err != nil {
+			spool.Close()
+
+			cliFile.Result = dataservicev1.Error
+			cliFile.Error = spool.err.Error()
+			errs[name] = spool.err
+			files.set(cliFile)
+
+			f.TableOutput(func(po printers.PrintObj) {
+				po.Print(cliFile)
+			})
+			continue
+		}
+
+		if existing := files.get(fileKey(cliFile)); existing != nil &&
+			existing.VerifiedChecksum == spool.checksum && existing.Result != dataservicev1.Error {
+			spool.Close()
+			continue
+		}
+
+		cliFile.Size = uint32(size)
+		cliFile.Checksum = spool.checksum
+		cliFile.VerifiedChecksum = spool.checksum
+		cliFile.CreatedAt = &metav1.Time{Time: modTime}
+
+		err := spool.appendTo(bundleFile, cliFile, currentMeteringExport.DisplayName())
+		spool.Close()
+		if err != nil {
+			return pulled, err
+		}
+
+		if err := bundleFile.Flush(); err != nil {
+			return pulled, err
+		}
+
+		cliFile.Result = dataservicev1.Ok
+		files.set(cliFile)
+		pulled = pulled + 1
+
+		if err := checkpoint(); err != nil {
+			return pulled, err
+		}
+
+		f.TableOutput(func(po printers.PrintObj) {
+			po.Print(cliFile)
+		})
+	}
+
+	if len(errs) != 0 {
+		return pulled, errors.NewWithDetails("failed to pull files", "files", len(errs))
+	}
+
+	return pulled, nil
+}
+
+// Commit moves the pushed files of the source to the archive path, or deletes
+// them if the source has none.
+func (f *filesystemSource) Commit(
+	ctx context.Context,
+	currentMeteringExport *api.MeteringExport,
+	bundleFile *bundle.BundleFile,
+	opts GenericOptions,
+) (int, error) {
+	commitOpts, err := commitOptionsFrom(opts)
+	if err != nil {
+		return 0, err
+	}
+
+	dryRun := commitOpts.dryRun
+
+	errs := map[string]error{}
+	committed := 0
+
+	for _, file := range currentMeteringExport.Files {
+		if file.FileInfo == nil || file.Source != f.name || file.SourceType != filesystemSourceType {
+			continue
+		}
+
+		if file.Committed || !commitOpts.selected(file) {
+			continue
+		}
+
+		if commitOpts.skip(file) {
+			f.TableOutput(func(po printers.PrintObj) {
+				po.Print(file)
+			})
+			continue
+		}
+
+		file.Action = dataservicev1.Commit
+		file.Result = dataservicev1.Ok
+
+		if dryRun {
+			file.Result = dataservicev1.DryRun
+
+			f.TableOutput(func(po printers.PrintObj) {
+				po.Print(file)
+			})
+			continue
+		}
+
+		if err := f.commitFile(file.Name); err != nil {
+			file.Error = err.Error()
+			file.Result = dataservicev1.Error
+			errs[file.Name] = err
+
+			f.TableOutput(func(po printers.PrintObj) {
+				po.Print(file)
+			})
+			continue
+		}
+
+		file.Error = ""
+		file.Committed = true
+		committed = committed + 1
+
+		f.TableOutput(func(po printers.PrintObj) {
+			po.Print(file)
+		})
+	}
+
+	if len(errs) != 0 {
+		return committed, errors.NewWithDetails("failed to commit files", "files", len(errs))
+	}
+
+	return committed, nil
+}
+
+// nameTakenBy returns the file of another source with the name of file in the
+// export. Bundle entries are keyed by name, so pulling the file would replace
+// the entry of the other source.
+func nameTakenBy(currentMeteringExport *api.MeteringExport, file *dataservicev1.FileInfoCTLAction) *dataservicev1.FileInfoCTLAction {
+	for _, other := range currentMeteringExport.Files {
+		if other.FileInfo == nil || other.Name != file.Name {
+			continue
+		}
+
+		if other.Source != file.Source || other.SourceType != file.SourceType {
+			return other
+		}
+	}
+
+	return nil
+}
+
+// commitFile moves or deletes the original of a file. A file already gone is
+// committed.
+func (f *filesystemSource) commitFile(name string) error {
+	original := filepath.Join(f.path, name)
+
+	var err error
+	if f.archivePath != "" {
+		if err = os.MkdirAll(f.archivePath, 0755); err != nil {
+			return err
+		}
+
+		err = os.Rename(original, filepath.Join(f.archivePath, name))
+	} else {
+		err = os.Remove(original)
+	}
+
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// match returns the names of the regular files of the directory that match
+// the pattern, sorted.
+func (f *filesystemSource) match() ([]string, error) {
+	entries, err := os.ReadDir(f.path)
+	if err != nil {
+		return nil, errors.WithDetails(err, "path", f.path)
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		if ok, _ := filepath.Match(f.pattern, entry.Name()); ok {
+			names = append(names, entry.Name())
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// spool copies a file of the directory to a spool file, so a file rewritten
+// while it's pulled can't change between its checksum and the bundle. It
+// returns the size of the copy and the modification time of the original.
+func (f *filesystemSource) spool(name string) (*spoolFile, int64, time.Time) {
+	spool := &spoolFile{}
+
+	in, err := os.Open(filepath.Join(f.path, name))
+	if err != nil {
+		spool.err = err
+		return spool, 0, time.Time{}
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		spool.err = err
+		return spool, 0, time.Time{}
+	}
+
+	spool.file, spool.err = os.CreateTemp("", "datactl-spool-*")
+	if spool.err != nil {
+		return spool, 0, time.Time{}
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(spool.file, hash), in)
+	if err != nil {
+		spool.err = err
+		return spool, 0, time.Time{}
+	}
+
+	spool.checksum = fmt.Sprintf("%x", hash.Sum(nil))
+	return spool, size, info.ModTime()
+}
+
+func mimeType(name string) string {
+	if strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz") {
+		return "application/gzip"
+	}
+
+	if t := mime.TypeByExtension(filepath.Ext(name)); t != "" {
+		return t
+	}
+
+	return "application/octet-stream"
+}
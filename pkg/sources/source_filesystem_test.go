@@ -0,0 +1,187 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sources
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-marketplace/datactl/pkg/bundle"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	dataservicev1 "github.com/redhat-marketplace/datactl/pkg/datactl/api/dataservice/v1"
+	"github.com/redhat-marketplace/datactl/pkg/printers"
+	"k8s.io/kubectl/pkg/cmd/get"
+)
+
+var _ = Describe("filesystem source", func() {
+	var (
+		dir, archive string
+		bundleFile   *bundle.BundleFile
+		export       *api.MeteringExport
+		source       api.Source
+		printer      printers.Printer
+	)
+
+	write := func(name, data string) {
+		Expect(os.WriteFile(filepath.Join(dir, name), []byte(data), 0644)).To(Succeed())
+	}
+
+	newSource := func() CommitableSource {
+		sut, err := NewFilesystemSource(source, printer)
+		Expect(err).To(Succeed())
+		return sut
+	}
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		archive = filepath.Join(GinkgoT().TempDir(), "archive")
+
+		var err error
+		bundleFile, err = bundle.NewBundle(filepath.Join(GinkgoT().TempDir(), "bundle.tar"))
+		Expect(err).To(Succeed())
+
+		printer, err = printers.NewPrinter(io.Discard, get.NewGetPrintFlags())
+		Expect(err).To(Succeed())
+
+		export = &api.MeteringExport{FileName: bundleFile.Name()}
+		source = api.Source{
+			Name: "reports",
+			Type: Filesystem,
+			Properties: map[string]string{
+				PathProperty:    dir,
+				PatternProperty: "*.tar.gz",
+			},
+		}
+
+		write("a.tar.gz", "a")
+		write("b.tar.gz", "b")
+		write("notes.txt", "c")
+	})
+
+	AfterEach(func() {
+		bundleFile.Close()
+	})
+
+	It("should pull the files matching the pattern with their checksums", func() {
+		count, err := newSource().Pull(context.Background(), export, bundleFile, EmptyOptions())
+		Expect(err).To(Succeed())
+		Expect(count).To(Equal(2))
+
+		Expect(export.Files).To(HaveLen(2))
+		Expect(export.Files[0].Name).To(Equal("a.tar.gz"))
+		Expect(export.Files[0].Source).To(Equal("reports"))
+		Expect(export.Files[0].Result).To(Equal(dataservicev1.Ok))
+		Expect(export.Files[0].VerifiedChecksum).To(Equal(fmt.Sprintf("%x", sha256.Sum256([]byte("a")))))
+
+		Expect(bundleFile.Close()).To(Succeed())
+
+		names := []string{}
+		Expect(bundle.WalkTar(bundleFile.Name(), func(header *tar.Header, r io.Reader) error {
+			names = append(names, header.Name)
+
+			metadata, ok := bundle.MetadataFromHeader(header)
+			Expect(ok).To(BeTrue())
+			Expect(metadata.Source).To(Equal("reports"))
+			return nil
+		})).To(Succeed())
+		Expect(names).To(Equal([]string{"a.tar.gz", "b.tar.gz"}))
+	})
+
+	It("should skip the files already pulled and pull the changed ones", func() {
+		_, err := newSource().Pull(context.Background(), export, bundleFile, EmptyOptions())
+		Expect(err).To(Succeed())
+
+		write("b.tar.gz", "changed")
+
+		count, err := newSource().Pull(context.Background(), export, bundleFile, EmptyOptions())
+		Expect(err).To(Succeed())
+		Expect(count).To(Equal(1))
+		Expect(export.Files).To(HaveLen(2))
+		Expect(export.Files[1].VerifiedChecksum).To(Equal(fmt.Sprintf("%x", sha256.Sum256([]byte("changed")))))
+	})
+
+	It("should move the pushed files to the archive path on commit", func() {
+		source.Properties[ArchivePathProperty] = archive
+		sut := newSource()
+
+		_, err := sut.Pull(context.Background(), export, bundleFile, EmptyOptions())
+		Expect(err).To(Succeed())
+
+		export.Files[0].Pushed = true
+
+		count, err := sut.Commit(context.Background(), export, bundleFile, EmptyOptions())
+		Expect(err).To(Succeed())
+		Expect(count).To(Equal(1))
+
+		Expect(export.Files[0].Committed).To(BeTrue())
+		Expect(export.Files[1].Committed).To(BeFalse())
+
+		Expect(filepath.Join(dir, "a.tar.gz")).ToNot(BeAnExistingFile())
+		Expect(filepath.Join(archive, "a.tar.gz")).To(BeAnExistingFile())
+		Expect(filepath.Join(dir, "b.tar.gz")).To(BeAnExistingFile())
+	})
+
+	It("should delete the pushed files on commit without an archive path", func() {
+		sut := newSource()
+
+		_, err := sut.Pull(context.Background(), export, bundleFile, EmptyOptions())
+		Expect(err).To(Succeed())
+
+		export.Files[0].Pushed = true
+		export.Files[1].Pushed = true
+
+		count, err := sut.Commit(context.Background(), export, bundleFile, NewOptions(DryRun, true))
+		Expect(err).To(Succeed())
+		Expect(count).To(Equal(0))
+		Expect(filepath.Join(dir, "a.tar.gz")).To(BeAnExistingFile())
+
+		count, err = sut.Commit(context.Background(), export, bundleFile, EmptyOptions())
+		Expect(err).To(Succeed())
+		Expect(count).To(Equal(2))
+		Expect(filepath.Join(dir, "a.tar.gz")).ToNot(BeAnExistingFile())
+		Expect(filepath.Join(dir, "b.tar.gz")).ToNot(BeAnExistingFile())
+		Expect(filepath.Join(dir, "notes.txt")).To(BeAnExistingFile())
+	})
+
+	It("should not replace a file of the same name from another source", func() {
+		other := &dataservicev1.FileInfoCTLAction{FileInfo: &dataservicev1.FileInfo{Source: "cluster", SourceType: "report"}}
+		other.Name = "a.tar.gz"
+		export.Files = append(export.Files, other)
+
+		count, err := newSource().Pull(context.Background(), export, bundleFile, EmptyOptions())
+		Expect(err).To(HaveOccurred())
+		Expect(count).To(Equal(1))
+
+		Expect(export.Files).To(HaveLen(2))
+		Expect(export.Files[0]).To(Equal(other))
+		Expect(export.Files[1].Name).To(Equal("b.tar.gz"))
+		Expect(export.Files[1].Result).To(Equal(dataservicev1.Ok))
+	})
+
+	It("should only accept patterns of file names", func() {
+		source.Properties[PatternProperty] = "sub/*.tar.gz"
+
+		_, err := NewFilesystemSource(source, printer)
+		Expect(err).To(HaveOccurred())
+	})
+})
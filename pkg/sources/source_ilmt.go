@@ -5,12 +5,15 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
 	"emperror.dev/errors"
 	"github.com/redhat-marketplace/datactl/pkg/bundle"
@@ -22,24 +25,31 @@ import (
 )
 
 const (
-	StartDate = "startDate"
-	EndDate   = "endDate"
-	EMPTY     = ""
+	StartDate    = "startDate"
+	EndDate      = "endDate"
+	DailyReports = "dailyReports"
+	EMPTY        = ""
 )
 
 type ilmtSource struct {
 	printers.TablePrinter
-	ilmt                    ilmt.Client
+	ilmt ilmt.Client
+
+	// name of the source, recorded as the source of its reports so the
+	// reports of ILMT servers don't replace each other.
+	name                    string
 	productUsageResponseStr string
 }
 
 func NewIlmtSource(
+	name string,
 	ilmt ilmt.Client,
 	printer printers.TablePrinter,
 ) (Source, error) {
 	i := &ilmtSource{
 		ilmt:         ilmt,
 		TablePrinter: printer,
+		name:         name,
 	}
 	return i, nil
 }
@@ -48,10 +58,15 @@ func (i *ilmtSource) GetResponse() string {
 	return i.productUsageResponseStr
 }
 
+// Pull adds a report of the usage in the date range to the bundle, or a report
+// per day with the DailyReports option. If the usage of some days can't be
+// fetched, the report holds the days before the first failed day, or the
+// daily reports hold the other days, and is returned with an
+// *ilmt.PartialError.
 func (i *ilmtSource) Pull(
 	ctx context.Context,
 	currentMeteringExport *api.MeteringExport,
-	bundle *bundle.BundleFile,
+	bundleFile *bundle.BundleFile,
 	options GenericOptions,
 ) (int, error) {
 	startDate, _, err := options.GetString(StartDate)
@@ -64,72 +79,178 @@ func (i *ilmtSource) Pull(
 		return 0, err
 	}
 
+	dailyReports, _, err := options.GetBool(DailyReports)
+	if err != nil {
+		return 0, err
+	}
+
 	dateRangeOptions := ilmt.DateRange{
 		StartDate: startDate,
 		EndDate:   endDate,
 	}
 
-	_, productUsageRespStr, err := i.ilmt.FetchUsageData(ctx, dateRangeOptions)
+	if dailyReports {
+		return i.pullDaily(ctx, currentMeteringExport, bundleFile, dateRangeOptions)
+	}
 
-	if err != nil {
-		return -1, err
+	days, productUsageRespStr, fetchErr := i.ilmt.FetchUsageData(ctx, dateRangeOptions)
+
+	// the usage of the days before the first failed day is kept, the next pull
+	// starts at the failed day
+	var partial *ilmt.PartialError
+	if fetchErr != nil && (!errors.As(fetchErr, &partial) || days == 0) {
+		return -1, fetchErr
 	}
 
 	i.productUsageResponseStr = productUsageRespStr
 
-	// create temporary directory
-	tempDir, err := ioutil.TempDir("", "iltmdata")
-	if err != nil {
-		return 0, err
+	endDate = dateRangeOptions.EndDate
+	if partial != nil {
+		endDate, err = dayBefore(partial.FirstFailedDate())
+		if err != nil {
+			return -1, err
+		}
 	}
 
-	// create file with received data and manifest in temporary directory
-	err = CreateFileFromString(filepath.Join(tempDir, "ilmtdata.json"), productUsageRespStr)
+	reportFileName := fmt.Sprintf("upload-ilmt-%s-%s-%s.tar.gz", i.fileNamePart(), dateRangeOptions.StartDate, endDate)
+
+	ilmtFile, err := addReport(bundleFile, currentMeteringExport, i.name, reportFileName, ilmtDataFileName, productUsageRespStr)
 	if err != nil {
 		return 0, err
 	}
 
-	CreateFileFromString(filepath.Join(tempDir, "manifest.json"), "{\"version\":\"1\",\"type\":\"accountMetrics\"}")
-	if err != nil {
-		return 0, err
+	currentMeteringExport.Files = append(currentMeteringExport.Files, ilmtFile)
+
+	return 1, fetchErr
+}
+
+// pullDaily adds a report per day to the bundle. A day pulled again replaces
+// its report, unless the report was already pushed.
+func (i *ilmtSource) pullDaily(
+	ctx context.Context,
+	currentMeteringExport *api.MeteringExport,
+	bundleFile *bundle.BundleFile,
+	dateRange ilmt.DateRange,
+) (int, error) {
+	days, fetchErr := i.ilmt.FetchDailyUsageData(ctx, dateRange)
+
+	var partial *ilmt.PartialError
+	if fetchErr != nil && (!errors.As(fetchErr, &partial) || len(days) == 0) {
+		return -1, fetchErr
 	}
 
-	var buffer bytes.Buffer
+	files := indexExportFiles(currentMeteringExport)
+	count := 0
 
-	// create archive file
-	err = Tar(tempDir, &buffer)
-	if err != nil {
-		return 0, err
+	for _, day := range days {
+		reportFileName := fmt.Sprintf("upload-ilmt-%s-%s.tar.gz", i.fileNamePart(), day.Date)
+
+		if existing := files.get(reportFileName + i.name + reportSourceType); existing != nil && existing.Pushed {
+			continue
+		}
+
+		ilmtFile, err := addReport(bundleFile, currentMeteringExport, i.name, reportFileName, ilmtDataFileName, day.Data)
+		if err != nil {
+			return count, err
+		}
+
+		files.set(ilmtFile)
+		count = count + 1
 	}
 
-	reportFileName := fmt.Sprintf("upload-ilmt-%s-%s.tar.gz", dateRangeOptions.StartDate, dateRangeOptions.EndDate)
+	return count, fetchErr
+}
 
-	// create new bundle file
-	w, err := bundle.NewFile(reportFileName, int64(buffer.Len()))
+// dayBefore returns the date of the day before a date.
+func dayBefore(date string) (string, error) {
+	day, err := time.Parse(dateLayout, date)
 	if err != nil {
-		return 0, err
+		return EMPTY, err
 	}
 
-	w.Write(buffer.Bytes())
+	return day.AddDate(0, 0, -1).Format(dateLayout), nil
+}
+
+var unsafeFileName = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// fileNamePart is the name of the source as it appears in report file names.
+func (i *ilmtSource) fileNamePart() string {
+	return unsafeFileName.ReplaceAllString(i.name, "_")
+}
+
+const (
+	ilmtDataFileName = "ilmtdata.json"
+	reportSourceType = "report"
+)
+
+// addReport writes an upload archive of the usage data to the bundle and
+// returns its file record. The archive holds the data file and a manifest.
+func addReport(
+	bundleFile *bundle.BundleFile,
+	currentMeteringExport *api.MeteringExport,
+	source string,
+	reportFileName string,
+	dataFileName string,
+	productUsageRespStr string,
+) (*dataservicev1.FileInfoCTLAction, error) {
+	// create temporary directory
+	tempDir, err := ioutil.TempDir("", "report")
+	if err != nil {
+		return nil, err
+	}
 
 	// remove temporary directory
 	defer os.RemoveAll(tempDir)
 
+	// create file with received data and manifest in temporary directory
+	err = CreateFileFromString(filepath.Join(tempDir, dataFileName), productUsageRespStr)
+	if err != nil {
+		return nil, err
+	}
+
+	err = CreateFileFromString(filepath.Join(tempDir, "manifest.json"), "{\"version\":\"1\",\"type\":\"accountMetrics\"}")
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+
+	// create archive file
+	err = Tar(tempDir, &buffer)
+	if err != nil {
+		return nil, err
+	}
+
 	ilmtFile := &dataservicev1.FileInfoCTLAction{
 		Action: dataservicev1.Pull,
 		FileInfo: &dataservicev1.FileInfo{
-			Source:     "ilmt",
-			SourceType: "report",
+			Source:     source,
+			SourceType: reportSourceType,
 			Size:       uint32(buffer.Len()),
 			MimeType:   "application/gzip",
 			CreatedAt:  &v1.Time{},
 		},
+		VerifiedChecksum: fmt.Sprintf("%x", sha256.Sum256(buffer.Bytes())),
 	}
 	ilmtFile.Name = reportFileName
 
-	currentMeteringExport.Files = append(currentMeteringExport.Files, ilmtFile)
+	// create new bundle file
+	w, err := bundleFile.NewFileWithMetadata(reportFileName, int64(buffer.Len()),
+		bundle.NewFileMetadata(ilmtFile, currentMeteringExport.DisplayName()))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(buffer.Bytes()); err != nil {
+		return nil, err
+	}
+
+	// the report is recorded once it is on disk
+	if err := bundleFile.Flush(); err != nil {
+		return nil, err
+	}
 
-	return 1, nil
+	return ilmtFile, nil
 }
 
 // Creates file with given data content
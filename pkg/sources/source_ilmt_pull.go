@@ -0,0 +1,91 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sources
+
+import (
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/redhat-marketplace/datactl/pkg/clients/ilmt"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers"
+	"github.com/spf13/pflag"
+	"k8s.io/kubectl/pkg/util/i18n"
+)
+
+// DailyReportsFlag is the flag of the pull command for a report per day from
+// ILMT sources.
+const DailyReportsFlag = "daily-reports"
+
+// NewIlmtSourceFromConfig creates an ILMT source with the client of its
+// config.
+func NewIlmtSourceFromConfig(rhmConfigFlags *config.ConfigFlags, printer printers.Printer, source api.Source) (Source, error) {
+	client, err := rhmConfigFlags.IlmtClient(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewIlmtSource(source.Name, client, printer)
+}
+
+// IlmtPullFlags adds the date range flags to the pull command.
+func IlmtPullFlags(flags *pflag.FlagSet) {
+	DateRangePullFlags(flags)
+	flags.Bool(DailyReportsFlag, false, i18n.T("write one ILMT report file per day instead of one for the date range"))
+}
+
+// IlmtPullOptions returns the date range of the pull of an ILMT source,
+// starting at the last pull date of the source by default.
+func IlmtPullOptions(req *PullRequest) (GenericOptions, error) {
+	lastPullDate := EMPTY
+	if endpoint, ok := req.Config.ILMTEndpoints[req.Source.Name]; ok && endpoint != nil {
+		lastPullDate = endpoint.LastPulldate
+	}
+
+	startDate, endDate, err := DateRange(req, lastPullDate)
+	if err != nil {
+		return nil, err
+	}
+
+	dailyReports, _ := req.Flags.GetBool(DailyReportsFlag)
+
+	return NewOptions(
+		StartDate, startDate,
+		EndDate, endDate,
+		DailyReports, dailyReports,
+	), nil
+}
+
+// IlmtPulled sets the date the next pull of an ILMT source starts from: the
+// first day that failed, or today.
+func IlmtPulled(cfg *api.Config, source *api.Source, err error) {
+	endpoint, ok := cfg.ILMTEndpoints[source.Name]
+	if !ok || endpoint == nil {
+		return
+	}
+
+	var partial *ilmt.PartialError
+	if errors.As(err, &partial) {
+		endpoint.LastPulldate = partial.FirstFailedDate()
+		return
+	}
+
+	if err != nil {
+		return
+	}
+
+	endpoint.LastPulldate = time.Now().Format(dateLayout)
+}
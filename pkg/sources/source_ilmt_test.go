@@ -0,0 +1,331 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sources
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	"github.com/redhat-marketplace/datactl/pkg/bundle"
+	"github.com/redhat-marketplace/datactl/pkg/clients/ilmt"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	"github.com/redhat-marketplace/datactl/pkg/printers"
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/kubectl/pkg/cmd/get"
+)
+
+type fakeIlmt struct {
+	days int
+	err  error
+
+	daily []ilmt.DailyUsage
+}
+
+func (f *fakeIlmt) FetchUsageData(ctx context.Context, dateRange ilmt.DateRange) (int, string, error) {
+	return f.days, `{"data":[]}`, f.err
+}
+
+func (f *fakeIlmt) FetchDailyUsageData(ctx context.Context, dateRange ilmt.DateRange) ([]ilmt.DailyUsage, error) {
+	return f.daily, f.err
+}
+
+var _ = Describe("ilmt source", func() {
+	var (
+		fake       *fakeIlmt
+		bundleFile *bundle.BundleFile
+		export     *api.MeteringExport
+		sut        Source
+		opts       GenericOptions
+	)
+
+	BeforeEach(func() {
+		fake = &fakeIlmt{}
+
+		var err error
+		bundleFile, err = bundle.NewBundle(filepath.Join(GinkgoT().TempDir(), "bundle.tar"))
+		Expect(err).To(Succeed())
+
+		printer, err := printers.NewPrinter(io.Discard, get.NewGetPrintFlags())
+		Expect(err).To(Succeed())
+
+		sut, err = NewIlmtSource("ilmt.example.com", fake, printer)
+		Expect(err).To(Succeed())
+
+		export = &api.MeteringExport{FileName: bundleFile.Name()}
+		opts = NewOptions(StartDate, "2022-06-17", EndDate, "2022-06-19")
+	})
+
+	AfterEach(func() {
+		bundleFile.Close()
+	})
+
+	It("should keep the report of the days before the first failed day", func() {
+		fake.days = 1
+		fake.err = &ilmt.PartialError{Failed: []ilmt.DayError{{Date: "2022-06-18", Err: ilmt.ServerError}}}
+
+		count, err := sut.Pull(context.Background(), export, bundleFile, opts)
+		Expect(count).To(Equal(1))
+
+		var partial *ilmt.PartialError
+		Expect(errors.As(err, &partial)).To(BeTrue())
+		Expect(export.Files).To(HaveLen(1))
+		Expect(export.Files[0].Name).To(Equal("upload-ilmt-ilmt.example.com-2022-06-17-2022-06-17.tar.gz"))
+	})
+
+	It("should write the report to disk before recording it", func() {
+		fake.days = 3
+
+		_, err := sut.Pull(context.Background(), export, bundleFile, opts)
+		Expect(err).To(Succeed())
+		Expect(export.Files).To(HaveLen(1))
+
+		names := []string{}
+		Expect(bundle.WalkTar(bundleFile.Name(), func(header *tar.Header, r io.Reader) error {
+			names = append(names, header.Name)
+			return nil
+		})).To(Succeed())
+		Expect(names).To(Equal([]string{export.Files[0].Name}))
+	})
+
+	It("should not write a report when no day was fetched", func() {
+		fake.err = &ilmt.PartialError{Failed: []ilmt.DayError{{Date: "2022-06-17", Err: ilmt.AuthError}}, Stopped: true}
+
+		_, err := sut.Pull(context.Background(), export, bundleFile, opts)
+		Expect(err).To(HaveOccurred())
+		Expect(export.Files).To(BeEmpty())
+	})
+
+	It("should write a report per day", func() {
+		fake.daily = []ilmt.DailyUsage{{Date: "2022-06-17", Data: "{}"}, {Date: "2022-06-18", Data: "{}"}}
+		opts = NewOptions(StartDate, "2022-06-17", EndDate, "2022-06-18", DailyReports, true)
+
+		count, err := sut.Pull(context.Background(), export, bundleFile, opts)
+		Expect(err).To(Succeed())
+		Expect(count).To(Equal(2))
+		Expect(export.Files).To(HaveLen(2))
+		Expect(export.Files[0].Name).To(Equal("upload-ilmt-ilmt.example.com-2022-06-17.tar.gz"))
+		Expect(export.Files[0].VerifiedChecksum).ToNot(BeEmpty())
+		Expect(export.Files[1].Name).To(Equal("upload-ilmt-ilmt.example.com-2022-06-18.tar.gz"))
+
+		export.Files[0].Pushed = true
+
+		fake.daily = []ilmt.DailyUsage{{Date: "2022-06-17", Data: "{}"}, {Date: "2022-06-18", Data: "{}"}, {Date: "2022-06-19", Data: "{}"}}
+		opts = NewOptions(StartDate, "2022-06-17", EndDate, "2022-06-19", DailyReports, true)
+
+		count, err = sut.Pull(context.Background(), export, bundleFile, opts)
+		Expect(err).To(Succeed())
+		Expect(count).To(Equal(2))
+		Expect(export.Files).To(HaveLen(3))
+		Expect(export.Files[0].Pushed).To(BeTrue())
+		Expect(export.Files[2].Name).To(Equal("upload-ilmt-ilmt.example.com-2022-06-19.tar.gz"))
+	})
+
+	It("should keep the daily reports of each ilmt source", func() {
+		fake.daily = []ilmt.DailyUsage{{Date: "2022-06-17", Data: "{}"}}
+		opts = NewOptions(StartDate, "2022-06-17", EndDate, "2022-06-17", DailyReports, true)
+
+		_, err := sut.Pull(context.Background(), export, bundleFile, opts)
+		Expect(err).To(Succeed())
+		export.Files[0].Pushed = true
+
+		printer, err := printers.NewPrinter(io.Discard, get.NewGetPrintFlags())
+		Expect(err).To(Succeed())
+
+		other, err := NewIlmtSource("other.example.com", fake, printer)
+		Expect(err).To(Succeed())
+
+		count, err := other.Pull(context.Background(), export, bundleFile, opts)
+		Expect(err).To(Succeed())
+		Expect(count).To(Equal(1))
+		Expect(export.Files).To(HaveLen(2))
+		Expect(export.Files[0].Source).To(Equal("ilmt.example.com"))
+		Expect(export.Files[1].Source).To(Equal("other.example.com"))
+		Expect(export.Files[1].Name).To(Equal("upload-ilmt-other.example.com-2022-06-17.tar.gz"))
+	})
+})
+
+var _ = Describe("ilmt pull options", func() {
+	var (
+		cfg   *api.Config
+		s     *api.Source
+		flags *pflag.FlagSet
+	)
+
+	BeforeEach(func() {
+		s = &api.Source{Name: "ilmt.example.com", Type: api.ILMT}
+		cfg = &api.Config{
+			ILMTEndpoints: map[string]*api.ILMTEndpoint{
+				s.Name: {LastPulldate: "2022-06-17"},
+			},
+		}
+
+		flags = pflag.NewFlagSet("pull", pflag.ContinueOnError)
+		IlmtPullFlags(flags)
+	})
+
+	It("should start from the last pull date", func() {
+		Expect(flags.Set(EndDateFlag, "2022-06-18")).To(Succeed())
+
+		opts, err := IlmtPullOptions(&PullRequest{Config: cfg, Source: s, Flags: flags})
+		Expect(err).To(Succeed())
+
+		startDate, _, _ := opts.GetString(StartDate)
+		Expect(startDate).To(Equal("2022-06-17"))
+		endDate, _, _ := opts.GetString(EndDate)
+		Expect(endDate).To(Equal("2022-06-18"))
+	})
+
+	It("should fail with an end date before the start date", func() {
+		Expect(flags.Set(StartDateFlag, "2022-06-17")).To(Succeed())
+		Expect(flags.Set(EndDateFlag, "2022-06-16")).To(Succeed())
+
+		_, err := IlmtPullOptions(&PullRequest{Config: cfg, Source: s, Flags: flags})
+		Expect(err).To(MatchError(ContainSubstring("End date must not be less than start date")))
+	})
+
+	It("should start the next pull from the first failed day", func() {
+		IlmtPulled(cfg, s, &ilmt.PartialError{Failed: []ilmt.DayError{{Date: "2022-06-18"}}})
+		Expect(cfg.ILMTEndpoints[s.Name].LastPulldate).To(Equal("2022-06-18"))
+
+		IlmtPulled(cfg, s, errors.New("failed"))
+		Expect(cfg.ILMTEndpoints[s.Name].LastPulldate).To(Equal("2022-06-18"))
+	})
+})
+
+var _ = Describe("ilmt pulls", func() {
+	var (
+		server     *ghttp.Server
+		failed     map[string]bool
+		bundleFile *bundle.BundleFile
+		export     *api.MeteringExport
+		cfg        *api.Config
+		s          *api.Source
+		sut        Source
+		backoff    wait.Backoff
+	)
+
+	day := func(days int) string {
+		return time.Now().AddDate(0, 0, days).Format(dateLayout)
+	}
+
+	BeforeEach(func() {
+		backoff = ilmt.DefaultBackoff
+		ilmt.DefaultBackoff = wait.Backoff{Steps: 1, Duration: time.Millisecond}
+
+		// the standalone usage of a day is an event, the usage of bundles is
+		// empty. The days in failed fail once.
+		failed = map[string]bool{}
+		server = ghttp.NewTLSServer()
+		server.RouteToHandler("GET", "/api/sam/v2/license_usage", func(w http.ResponseWriter, r *http.Request) {
+			date := r.URL.Query().Get("startdate")
+			criteria := strings.Join(r.URL.Query()["criteria"], "")
+
+			if failed[date] {
+				failed[date] = false
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			if strings.Contains(criteria, "'bundle_type','=','-1'") {
+				fmt.Fprint(w, `{"total":1,"rows":[{"product_id":1,"product_name":"product","metric_code_name":"PVU","hwm_quantity":10}]}`)
+				return
+			}
+
+			fmt.Fprint(w, `{"total":0,"rows":[]}`)
+		})
+
+		caCertPool, _ := x509.SystemCertPool()
+		caCertPool.AddCert(server.HTTPTestServer.Certificate())
+
+		client, err := ilmt.NewClient(&ilmt.IlmtConfig{
+			Host:      server.URL(),
+			Token:     "token",
+			TlsConfig: &tls.Config{RootCAs: caCertPool},
+		})
+		Expect(err).To(Succeed())
+
+		printer, err := printers.NewPrinter(io.Discard, get.NewGetPrintFlags())
+		Expect(err).To(Succeed())
+
+		s = &api.Source{Name: "ilmt.example.com", Type: api.ILMT}
+		sut, err = NewIlmtSource(s.Name, client, printer)
+		Expect(err).To(Succeed())
+
+		cfg = &api.Config{
+			ILMTEndpoints: map[string]*api.ILMTEndpoint{
+				s.Name: {LastPulldate: day(-3)},
+			},
+		}
+
+		bundleFile, err = bundle.NewBundle(filepath.Join(GinkgoT().TempDir(), "bundle.tar"))
+		Expect(err).To(Succeed())
+		export = &api.MeteringExport{FileName: bundleFile.Name()}
+	})
+
+	AfterEach(func() {
+		ilmt.DefaultBackoff = backoff
+		bundleFile.Close()
+		server.Close()
+	})
+
+	pull := func() (int, error) {
+		flags := pflag.NewFlagSet("pull", pflag.ContinueOnError)
+		IlmtPullFlags(flags)
+
+		opts, err := IlmtPullOptions(&PullRequest{Config: cfg, Source: s, Flags: flags})
+		Expect(err).To(Succeed())
+
+		count, err := sut.Pull(context.Background(), export, bundleFile, opts)
+		IlmtPulled(cfg, s, err)
+		return count, err
+	}
+
+	It("should not report a day twice after a failed day", func() {
+		failed[day(-2)] = true
+
+		_, err := pull()
+		var partial *ilmt.PartialError
+		Expect(errors.As(err, &partial)).To(BeTrue())
+		Expect(cfg.ILMTEndpoints[s.Name].LastPulldate).To(Equal(day(-2)))
+
+		Expect(export.Files).To(HaveLen(1))
+		Expect(export.Files[0].Name).To(Equal(fmt.Sprintf("upload-ilmt-ilmt.example.com-%s-%s.tar.gz", day(-3), day(-3))))
+		events := strings.Count(sut.GetResponse(), `"eventId"`)
+
+		_, err = pull()
+		Expect(err).To(Succeed())
+
+		Expect(export.Files).To(HaveLen(2))
+		Expect(export.Files[1].Name).To(Equal(fmt.Sprintf("upload-ilmt-ilmt.example.com-%s-%s.tar.gz", day(-2), day(-1))))
+		events = events + strings.Count(sut.GetResponse(), `"eventId"`)
+
+		// one event for each of the 3 days
+		Expect(events).To(Equal(3))
+	})
+})
@@ -0,0 +1,203 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sources
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/redhat-marketplace/datactl/pkg/bundle"
+	"github.com/redhat-marketplace/datactl/pkg/clients/prometheus"
+	"github.com/redhat-marketplace/datactl/pkg/clients/shared"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers"
+)
+
+// Prometheus is the source type of a Prometheus or Thanos query API that
+// usage reports are generated from.
+const Prometheus api.SourceType = "Prometheus"
+
+// Properties of a prometheus source.
+const (
+	// URLProperty is the url of the query API.
+	URLProperty = "url"
+
+	// TokenProperty is the bearer token sent to the query API.
+	TokenProperty = "token"
+
+	// StepProperty is the resolution of the queries, as a duration.
+	StepProperty = "step"
+
+	// QueryPropertyPrefix prefixes the queries, by the metric id reported
+	// for their results.
+	QueryPropertyPrefix = "query."
+
+	// LastPullDateProperty is the date the next pull starts from. It's set by
+	// the pull.
+	LastPullDateProperty = "last-pull-date"
+)
+
+// PrometheusSchema describes the properties of a prometheus source.
+var PrometheusSchema = []Property{
+	{Name: URLProperty, Description: "url of the Prometheus or Thanos query API", Required: true},
+	{Name: TokenProperty, Description: "bearer token of the query API", Secret: true},
+	{Name: StepProperty, Description: "resolution of the queries"},
+	{Name: QueryPropertyPrefix, Description: "PromQL queries by the metric id of their results", Required: true, Prefix: true},
+	{Name: LastPullDateProperty, Description: "date the next pull starts from"},
+}
+
+type prometheusSource struct {
+	printers.TablePrinter
+	prometheus prometheus.Client
+
+	name                    string
+	productUsageResponseStr string
+}
+
+// NewPrometheusSourceFromConfig creates a prometheus source with a client for
+// the properties of its config.
+func NewPrometheusSourceFromConfig(rhmConfigFlags *config.ConfigFlags, printer printers.Printer, source api.Source) (Source, error) {
+	tlsConfig, err := rhmConfigFlags.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	promConfig, err := PrometheusConfig(source)
+	if err != nil {
+		return nil, err
+	}
+
+	promConfig.TlsConfig = tlsConfig
+
+	client, err := prometheus.NewClient(promConfig)
+	if err != nil {
+		return nil, errors.WithDetails(err, "sourceName", source.Name)
+	}
+
+	return NewPrometheusSource(source.Name, client, printer)
+}
+
+// PrometheusConfig returns the client config of the properties of a source.
+func PrometheusConfig(source api.Source) (*prometheus.PrometheusConfig, error) {
+	promConfig := &prometheus.PrometheusConfig{
+		URL:     source.Properties[URLProperty],
+		Token:   source.Properties[TokenProperty],
+		Queries: source.PropertiesWithPrefix(QueryPropertyPrefix),
+	}
+
+	if step := source.Properties[StepProperty]; step != "" {
+		d, err := time.ParseDuration(step)
+		if err != nil {
+			return nil, errors.WrapWithDetails(err, "invalid step", "sourceName", source.Name, "step", step)
+		}
+		promConfig.Step = d
+	}
+
+	shared.RegisterSecret(promConfig.Token)
+
+	return promConfig, nil
+}
+
+func NewPrometheusSource(
+	name string,
+	client prometheus.Client,
+	printer printers.TablePrinter,
+) (Source, error) {
+	p := &prometheusSource{
+		TablePrinter: printer,
+		prometheus:   client,
+		name:         name,
+	}
+
+	return p, nil
+}
+
+func (p *prometheusSource) GetResponse() string {
+	return p.productUsageResponseStr
+}
+
+// prometheusDataFileName is the name of the usage data in a report archive.
+const prometheusDataFileName = "usage.json"
+
+// Pull runs the queries of the source for each day of the date range and adds
+// a report of the results to the bundle.
+func (p *prometheusSource) Pull(
+	ctx context.Context,
+	currentMeteringExport *api.MeteringExport,
+	bundleFile *bundle.BundleFile,
+	options GenericOptions,
+) (int, error) {
+	startDate, _, err := options.GetString(StartDate)
+	if err != nil {
+		return 0, err
+	}
+
+	endDate, _, err := options.GetString(EndDate)
+	if err != nil {
+		return 0, err
+	}
+
+	_, report, err := p.prometheus.FetchUsageData(ctx, prometheus.DateRange{
+		StartDate: startDate,
+		EndDate:   endDate,
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	p.productUsageResponseStr = report
+
+	reportFileName := fmt.Sprintf("upload-prometheus-%s-%s-%s.tar.gz", unsafeFileName.ReplaceAllString(p.name, "_"), startDate, endDate)
+
+	file, err := addReport(bundleFile, currentMeteringExport, p.name, reportFileName, prometheusDataFileName, report)
+	if err != nil {
+		return 0, err
+	}
+
+	indexExportFiles(currentMeteringExport).set(file)
+
+	return 1, nil
+}
+
+// PrometheusPullOptions returns the date range of the pull of a prometheus
+// source, starting at the last pull date of the source by default.
+func PrometheusPullOptions(req *PullRequest) (GenericOptions, error) {
+	startDate, endDate, err := DateRange(req, req.Source.Properties[LastPullDateProperty])
+	if err != nil {
+		return nil, err
+	}
+
+	return NewOptions(
+		StartDate, startDate,
+		EndDate, endDate,
+	), nil
+}
+
+// PrometheusPulled sets the date the next pull of a prometheus source starts
+// from.
+func PrometheusPulled(cfg *api.Config, source *api.Source, err error) {
+	if err != nil {
+		return
+	}
+
+	if source.Properties == nil {
+		source.Properties = map[string]string{}
+	}
+
+	source.Properties[LastPullDateProperty] = time.Now().Format(dateLayout)
+}
@@ -0,0 +1,95 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sources
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-marketplace/datactl/pkg/bundle"
+	"github.com/redhat-marketplace/datactl/pkg/clients/prometheus"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	"github.com/redhat-marketplace/datactl/pkg/printers"
+	"k8s.io/kubectl/pkg/cmd/get"
+)
+
+type fakePrometheus struct {
+	dateRange prometheus.DateRange
+}
+
+func (f *fakePrometheus) FetchUsageData(ctx context.Context, dateRange prometheus.DateRange) (int, string, error) {
+	f.dateRange = dateRange
+	return 0, `{"data":[]}`, nil
+}
+
+var _ = Describe("prometheus source", func() {
+	var (
+		fake       *fakePrometheus
+		bundleFile *bundle.BundleFile
+		export     *api.MeteringExport
+		sut        Source
+	)
+
+	BeforeEach(func() {
+		fake = &fakePrometheus{}
+
+		var err error
+		bundleFile, err = bundle.NewBundle(filepath.Join(GinkgoT().TempDir(), "bundle.tar"))
+		Expect(err).To(Succeed())
+
+		printer, err := printers.NewPrinter(io.Discard, get.NewGetPrintFlags())
+		Expect(err).To(Succeed())
+
+		sut, err = NewPrometheusSource("thanos:9090", fake, printer)
+		Expect(err).To(Succeed())
+
+		export = &api.MeteringExport{FileName: bundleFile.Name()}
+	})
+
+	AfterEach(func() {
+		bundleFile.Close()
+	})
+
+	It("should write a report of the date range", func() {
+		count, err := sut.Pull(context.Background(), export, bundleFile, NewOptions(StartDate, "2022-06-17", EndDate, "2022-06-19"))
+		Expect(err).To(Succeed())
+		Expect(count).To(Equal(1))
+		Expect(fake.dateRange).To(Equal(prometheus.DateRange{StartDate: "2022-06-17", EndDate: "2022-06-19"}))
+
+		Expect(export.Files).To(HaveLen(1))
+		Expect(export.Files[0].Name).To(Equal("upload-prometheus-thanos_9090-2022-06-17-2022-06-19.tar.gz"))
+		Expect(export.Files[0].Source).To(Equal("thanos:9090"))
+		Expect(export.Files[0].VerifiedChecksum).ToNot(BeEmpty())
+	})
+
+	It("should read the queries and step from the properties", func() {
+		promConfig, err := PrometheusConfig(api.Source{
+			Name: "thanos",
+			Type: Prometheus,
+			Properties: map[string]string{
+				URLProperty:                  "https://thanos",
+				StepProperty:                 "5m",
+				QueryPropertyPrefix + "vcpu": "sum(product_vcpu)",
+			},
+		})
+		Expect(err).To(Succeed())
+		Expect(promConfig.Queries).To(Equal(map[string]string{"vcpu": "sum(product_vcpu)"}))
+		Expect(promConfig.Step.Minutes()).To(Equal(5.0))
+	})
+})
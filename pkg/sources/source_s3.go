@@ -0,0 +1,442 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sources
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/redhat-marketplace/datactl/pkg/bundle"
+	"github.com/redhat-marketplace/datactl/pkg/clients/s3"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	dataservicev1 "github.com/redhat-marketplace/datactl/pkg/datactl/api/dataservice/v1"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
+	"github.com/redhat-marketplace/datactl/pkg/printers"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// S3 is the source type of a bucket of an S3 compatible object storage, like
+// MinIO, that products write their usage report archives to. An S3 source is
+// also a destination bundles can be staged to instead of being pushed.
+const S3 api.SourceType = "S3"
+
+// Properties of an S3 source.
+const (
+	// EndpointProperty is the url of the S3 API.
+	EndpointProperty = "endpoint"
+
+	// RegionProperty is the region requests are signed for.
+	RegionProperty = "region"
+
+	// BucketProperty is the bucket of the reports.
+	BucketProperty = "bucket"
+
+	// PrefixProperty is the prefix of the keys of the reports.
+	PrefixProperty = "prefix"
+
+	// AccessKeyIDProperty and SecretAccessKeyProperty are the credentials
+	// of the bucket.
+	AccessKeyIDProperty     = "access-key-id"
+	SecretAccessKeyProperty = "secret-access-key"
+
+	// CommitActionProperty is what is done with the objects once they are
+	// committed, one of the S3CommitActions.
+	CommitActionProperty = "commit-action"
+
+	// StagingPrefixProperty is the prefix of the keys bundles are staged to.
+	// Objects under it are not pulled.
+	StagingPrefixProperty = "staging-prefix"
+)
+
+// Commit actions of an S3 source.
+const (
+	// S3CommitDelete deletes committed objects.
+	S3CommitDelete = "delete"
+
+	// S3CommitTag tags committed objects with S3CommittedTag. Tagged
+	// objects are not pulled again.
+	S3CommitTag = "tag"
+)
+
+const (
+	// S3CommittedTag is the tag of the objects committed by an S3 source with
+	// the tag commit action. Its value is the time of the commit.
+	S3CommittedTag = "datactl-committed"
+
+	DefaultS3StagingPrefix = "datactl-staging/"
+
+	s3SourceType = "s3"
+
+	// s3ETagMetadata is the metadata of a pulled file holding the ETag of its
+	// object, to skip unchanged objects without downloading them.
+	s3ETagMetadata = "etag"
+)
+
+// S3Schema describes the properties of an S3 source.
+var S3Schema = []Property{
+	{Name: EndpointProperty, Description: "url of the S3 API", Required: true},
+	{Name: RegionProperty, Description: "region requests are signed for"},
+	{Name: BucketProperty, Description: "bucket of the usage reports", Required: true},
+	{Name: PrefixProperty, Description: "prefix of the keys of the usage reports"},
+	{Name: AccessKeyIDProperty, Description: "access key id of the bucket", Required: true},
+	{Name: SecretAccessKeyProperty, Description: "secret access key of the bucket", Required: true, Secret: true},
+	{Name: CommitActionProperty, Description: "delete or tag the committed objects"},
+	{Name: StagingPrefixProperty, Description: "prefix of the keys bundles are staged to"},
+}
+
+// Stager is a destination bundles are copied to instead of being pushed to
+// the marketplace.
+type Stager interface {
+	// Stage copies a bundle and returns where it was copied to.
+	Stage(ctx context.Context, name string, bundle io.ReadSeeker) (string, error)
+}
+
+type s3Source struct {
+	printers.TablePrinter
+	s3 s3.Client
+
+	name          string
+	prefix        string
+	stagingPrefix string
+	commitAction  string
+}
+
+// NewS3SourceFromConfig creates an S3 source with a client for the properties
+// of its config.
+func NewS3SourceFromConfig(rhmConfigFlags *config.ConfigFlags, printer printers.Printer, source api.Source) (Source, error) {
+	tlsConfig, err := rhmConfigFlags.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	s3Config := S3Config(source)
+	s3Config.TlsConfig = tlsConfig
+
+	client, err := s3.NewClient(s3Config)
+	if err != nil {
+		return nil, errors.WithDetails(err, "sourceName", source.Name)
+	}
+
+	return NewS3Source(source, client, printer)
+}
+
+// S3Config returns the client config of the properties of a source.
+func S3Config(source api.Source) *s3.S3Config {
+	return &s3.S3Config{
+		Endpoint:        source.Properties[EndpointProperty],
+		Region:          source.Properties[RegionProperty],
+		Bucket:          source.Properties[BucketProperty],
+		AccessKeyID:     source.Properties[AccessKeyIDProperty],
+		SecretAccessKey: source.Properties[SecretAccessKeyProperty],
+	}
+}
+
+func NewS3Source(
+	source api.Source,
+	client s3.Client,
+	printer printers.TablePrinter,
+) (CommitableSource, error) {
+	s := &s3Source{
+		TablePrinter:  printer,
+		s3:            client,
+		name:          source.Name,
+		prefix:        source.Properties[PrefixProperty],
+		stagingPrefix: source.Properties[StagingPrefixProperty],
+		commitAction:  source.Properties[CommitActionProperty],
+	}
+
+	if s.stagingPrefix == "" {
+		s.stagingPrefix = DefaultS3StagingPrefix
+	}
+
+	if s.commitAction == "" {
+		s.commitAction = S3CommitDelete
+	}
+
+	if err := ValidateS3CommitAction(s.commitAction); err != nil {
+		return nil, errors.WithDetails(err, "sourceName", source.Name)
+	}
+
+	return s, nil
+}
+
+// ValidateS3CommitAction checks the commit action is one of the S3 commit
+// actions.
+func ValidateS3CommitAction(action string) error {
+	if action != S3CommitDelete && action != S3CommitTag {
+		return errors.NewWithDetails("commit action must be delete or tag", "commitAction", action)
+	}
+
+	return nil
+}
+
+func (s *s3Source) GetResponse() string {
+	return ""
+}
+
+// Pull adds the objects under the prefix to the bundle. Objects already
+// pulled with the same ETag, staged bundles and, with the tag commit action,
+// committed objects are skipped.
+func (s *s3Source) Pull(
+	ctx context.Context,
+	currentMeteringExport *api.MeteringExport,
+	bundleFile *bundle.BundleFile,
+	options GenericOptions,
+) (int, error) {
+	checkpoint, err := getCheckpoint(options)
+	if err != nil {
+		return 0, err
+	}
+
+	objects, err := s.s3.ListObjects(ctx, s.prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	files := indexExportFiles(currentMeteringExport)
+	errs := map[string]error{}
+	pulled := 0
+
+	for _, object := range objects {
+		if err := ctx.Err(); err != nil {
+			return pulled, err
+		}
+
+		if strings.HasPrefix(object.Key, s.stagingPrefix) || strings.HasSuffix(object.Key, "/") {
+			continue
+		}
+
+		cliFile := &dataservicev1.FileInfoCTLAction{
+			Action: dataservicev1.Pull,
+			FileInfo: &dataservicev1.FileInfo{
+				Source:     s.name,
+				SourceType: s3SourceType,
+				MimeType:   mimeType(object.Key),
+				Metadata:   map[string]string{s3ETagMetadata: object.ETag},
+			},
+		}
+		cliFile.Name = object.Key
+
+		if existing := files.get(fileKey(cliFile)); existing != nil &&
+			existing.Metadata[s3ETagMetadata] == object.ETag && existing.Result != dataservicev1.Error {
+			continue
+		}
+
+		if other := nameTakenBy(currentMeteringExport, cliFile); other != nil {
+			err := errors.NewWithDetails("file name already pulled from another source", "file", cliFile.Name, "otherSource", other.Source)
+
+			// not recorded in the export, push looks files up by name
+			cliFile.Result = dataservicev1.Error
+			cliFile.Error = err.Error()
+			errs[cliFile.Name] = err
+
+			s.TableOutput(func(po printers.PrintObj) {
+				po.Print(cliFile)
+			})
+			continue
+		}
+
+		if s.commitAction == S3CommitTag {
+			tags, err := s.s3.GetObjectTagging(ctx, object.Key)
+			if err == nil && tags[S3CommittedTag] != "" {
+				continue
+			}
+
+			if err != nil {
+				s.pullFailed(files, cliFile, err, errs)
+				continue
+			}
+		}
+
+		spool := s.download(ctx, object.Key)
+		if spool.err != nil {
+			spool.Close()
+			s.pullFailed(files, cliFile, spool.err, errs)
+			continue
+		}
+
+		cliFile.Size = uint32(object.Size)
+		cliFile.VerifiedChecksum = spool.checksum
+		cliFile.CreatedAt = &metav1.Time{Time: object.LastModified}
+
+		err := spool.appendTo(bundleFile, cliFile, currentMeteringExport.DisplayName())
+		spool.Close()
+		if err != nil {
+			return pulled, err
+		}
+
+		if err := bundleFile.Flush(); err != nil {
+			return pulled, err
+		}
+
+		cliFile.Result = dataservicev1.Ok
+		files.set(cliFile)
+		pulled = pulled + 1
+
+		if err := checkpoint(); err != nil {
+			return pulled, err
+		}
+
+		s.TableOutput(func(po printers.PrintObj) {
+			po.Print(cliFile)
+		})
+	}
+
+	if len(errs) != 0 {
+		return pulled, errors.NewWithDetails("failed to pull files", "files", len(errs))
+	}
+
+	return pulled, nil
+}
+
+func (s *s3Source) pullFailed(files *exportFiles, cliFile *dataservicev1.FileInfoCTLAction, err error, errs map[string]error) {
+	cliFile.Result = dataservicev1.Error
+	cliFile.Error = err.Error()
+	errs[cliFile.Name] = err
+	files.set(cliFile)
+
+	s.TableOutput(func(po printers.PrintObj) {
+		po.Print(cliFile)
+	})
+}
+
+// download copies an object to a spool file while hashing it.
+func (s *s3Source) download(ctx context.Context, key string) *spoolFile {
+	spool := &spoolFile{}
+
+	body, err := s.s3.GetObject(ctx, key)
+	if err != nil {
+		spool.err = err
+		return spool
+	}
+	defer body.Close()
+
+	spool.file, spool.err = os.CreateTemp("", "datactl-spool-*")
+	if spool.err != nil {
+		return spool
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(spool.file, hash), body); err != nil {
+		spool.err = err
+		return spool
+	}
+
+	spool.checksum = fmt.Sprintf("%x", hash.Sum(nil))
+	return spool
+}
+
+// Commit deletes or tags the objects of the pushed files of the source.
+func (s *s3Source) Commit(
+	ctx context.Context,
+	currentMeteringExport *api.MeteringExport,
+	bundleFile *bundle.BundleFile,
+	opts GenericOptions,
+) (int, error) {
+	commitOpts, err := commitOptionsFrom(opts)
+	if err != nil {
+		return 0, err
+	}
+
+	dryRun := commitOpts.dryRun
+
+	errs := map[string]error{}
+	committed := 0
+
+	for _, file := range currentMeteringExport.Files {
+		if file.FileInfo == nil || file.Source != s.name || file.SourceType != s3SourceType {
+			continue
+		}
+
+		if file.Committed || !commitOpts.selected(file) {
+			continue
+		}
+
+		if commitOpts.skip(file) {
+			s.TableOutput(func(po printers.PrintObj) {
+				po.Print(file)
+			})
+			continue
+		}
+
+		file.Action = dataservicev1.Commit
+		file.Result = dataservicev1.Ok
+
+		if dryRun {
+			file.Result = dataservicev1.DryRun
+
+			s.TableOutput(func(po printers.PrintObj) {
+				po.Print(file)
+			})
+			continue
+		}
+
+		if err := s.commitObject(ctx, file.Name); err != nil {
+			file.Error = err.Error()
+			file.Result = dataservicev1.Error
+			errs[file.Name] = err
+
+			s.TableOutput(func(po printers.PrintObj) {
+				po.Print(file)
+			})
+			continue
+		}
+
+		file.Error = ""
+		file.Committed = true
+		committed = committed + 1
+
+		s.TableOutput(func(po printers.PrintObj) {
+			po.Print(file)
+		})
+	}
+
+	if len(errs) != 0 {
+		return committed, errors.NewWithDetails("failed to commit files", "files", len(errs))
+	}
+
+	return committed, nil
+}
+
+func (s *s3Source) commitObject(ctx context.Context, key string) error {
+	if s.commitAction == S3CommitTag {
+		tags, err := s.s3.GetObjectTagging(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		tags[S3CommittedTag] = time.Now().UTC().Format(time.RFC3339)
+		return s.s3.PutObjectTagging(ctx, key, tags)
+	}
+
+	return s.s3.DeleteObject(ctx, key)
+}
+
+// Stage copies a bundle to the staging prefix of the bucket.
+func (s *s3Source) Stage(ctx context.Context, name string, bundle io.ReadSeeker) (string, error) {
+	key := s.stagingPrefix + name
+
+	if err := s.s3.PutObject(ctx, key, bundle); err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
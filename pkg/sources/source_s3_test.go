@@ -0,0 +1,215 @@
+// Copyright 2021 IBM Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sources
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-marketplace/datactl/pkg/bundle"
+	"github.com/redhat-marketplace/datactl/pkg/clients/s3"
+	"github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	dataservicev1 "github.com/redhat-marketplace/datactl/pkg/datactl/api/dataservice/v1"
+	"github.com/redhat-marketplace/datactl/pkg/printers"
+	"k8s.io/kubectl/pkg/cmd/get"
+)
+
+// fakeS3 is a bucket in memory.
+type fakeS3 struct {
+	objects map[string]string
+	tags    map[string]map[string]string
+	gets    int
+}
+
+func (f *fakeS3) ListObjects(ctx context.Context, prefix string) ([]s3.Object, error) {
+	out := []s3.Object{}
+	for key, data := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			out = append(out, s3.Object{Key: key, Size: int64(len(data)), ETag: data})
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, nil
+}
+
+func (f *fakeS3) GetObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	f.gets++
+	return io.NopCloser(strings.NewReader(f.objects[key])), nil
+}
+
+func (f *fakeS3) PutObject(ctx context.Context, key string, body io.ReadSeeker) error {
+	data, err := io.ReadAll(body)
+	f.objects[key] = string(data)
+	return err
+}
+
+func (f *fakeS3) DeleteObject(ctx context.Context, key string) error {
+	delete(f.objects, key)
+	return nil
+}
+
+func (f *fakeS3) GetObjectTagging(ctx context.Context, key string) (map[string]string, error) {
+	tags := map[string]string{}
+	for k, v := range f.tags[key] {
+		tags[k] = v
+	}
+	return tags, nil
+}
+
+func (f *fakeS3) PutObjectTagging(ctx context.Context, key string, tags map[string]string) error {
+	f.tags[key] = tags
+	return nil
+}
+
+var _ = Describe("s3 source", func() {
+	var (
+		fake       *fakeS3
+		bundleFile *bundle.BundleFile
+		export     *api.MeteringExport
+		source     api.Source
+		printer    printers.Printer
+	)
+
+	newSource := func() CommitableSource {
+		sut, err := NewS3Source(source, fake, printer)
+		Expect(err).To(Succeed())
+		return sut
+	}
+
+	BeforeEach(func() {
+		fake = &fakeS3{
+			objects: map[string]string{
+				"reports/a.tar.gz":                   "a",
+				"reports/b.tar.gz":                   "b",
+				"reports/datactl-staging/bundle.tar": "staged",
+				"other/c.tar.gz":                     "c",
+			},
+			tags: map[string]map[string]string{},
+		}
+
+		var err error
+		bundleFile, err = bundle.NewBundle(filepath.Join(GinkgoT().TempDir(), "bundle.tar"))
+		Expect(err).To(Succeed())
+
+		printer, err = printers.NewPrinter(io.Discard, get.NewGetPrintFlags())
+		Expect(err).To(Succeed())
+
+		export = &api.MeteringExport{FileName: bundleFile.Name()}
+		source = api.Source{
+			Name: "minio",
+			Type: S3,
+			Properties: map[string]string{
+				BucketProperty:        "usage",
+				PrefixProperty:        "reports/",
+				StagingPrefixProperty: "reports/datactl-staging/",
+			},
+		}
+	})
+
+	AfterEach(func() {
+		bundleFile.Close()
+	})
+
+	It("should pull the objects of the prefix and skip unchanged ones", func() {
+		count, err := newSource().Pull(context.Background(), export, bundleFile, EmptyOptions())
+		Expect(err).To(Succeed())
+		Expect(count).To(Equal(2))
+
+		Expect(export.Files).To(HaveLen(2))
+		Expect(export.Files[0].Name).To(Equal("reports/a.tar.gz"))
+		Expect(export.Files[0].Source).To(Equal("minio"))
+		Expect(export.Files[0].VerifiedChecksum).ToNot(BeEmpty())
+
+		fake.objects["reports/b.tar.gz"] = "changed"
+
+		count, err = newSource().Pull(context.Background(), export, bundleFile, EmptyOptions())
+		Expect(err).To(Succeed())
+		Expect(count).To(Equal(1))
+		Expect(fake.gets).To(Equal(3))
+	})
+
+	It("should delete the pushed objects on commit", func() {
+		sut := newSource()
+
+		_, err := sut.Pull(context.Background(), export, bundleFile, EmptyOptions())
+		Expect(err).To(Succeed())
+
+		export.Files[0].Pushed = true
+
+		count, err := sut.Commit(context.Background(), export, bundleFile, EmptyOptions())
+		Expect(err).To(Succeed())
+		Expect(count).To(Equal(1))
+		Expect(export.Files[0].Committed).To(BeTrue())
+		Expect(fake.objects).ToNot(HaveKey("reports/a.tar.gz"))
+		Expect(fake.objects).To(HaveKey("reports/b.tar.gz"))
+	})
+
+	It("should tag the pushed objects on commit and not pull them again", func() {
+		source.Properties[CommitActionProperty] = S3CommitTag
+		sut := newSource()
+
+		_, err := sut.Pull(context.Background(), export, bundleFile, EmptyOptions())
+		Expect(err).To(Succeed())
+
+		export.Files[0].Pushed = true
+
+		_, err = sut.Commit(context.Background(), export, bundleFile, EmptyOptions())
+		Expect(err).To(Succeed())
+		Expect(fake.objects).To(HaveKey("reports/a.tar.gz"))
+		Expect(fake.tags["reports/a.tar.gz"]).To(HaveKey(S3CommittedTag))
+
+		next := &api.MeteringExport{FileName: bundleFile.Name()}
+		count, err := sut.Pull(context.Background(), next, bundleFile, EmptyOptions())
+		Expect(err).To(Succeed())
+		Expect(count).To(Equal(1))
+		Expect(next.Files[0].Name).To(Equal("reports/b.tar.gz"))
+	})
+
+	It("should stage bundles under the staging prefix", func() {
+		stager, ok := newSource().(Stager)
+		Expect(ok).To(BeTrue())
+
+		key, err := stager.Stage(context.Background(), "rhm-upload.tar", bytes.NewReader([]byte("bundle")))
+		Expect(err).To(Succeed())
+		Expect(key).To(Equal("reports/datactl-staging/rhm-upload.tar"))
+		Expect(fake.objects).To(HaveKeyWithValue(key, "bundle"))
+	})
+
+	It("should refuse unknown commit actions", func() {
+		source.Properties[CommitActionProperty] = "move"
+
+		_, err := NewS3Source(source, fake, printer)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should not pull an object named like a file of another source", func() {
+		other := &dataservicev1.FileInfoCTLAction{FileInfo: &dataservicev1.FileInfo{Source: "cluster", SourceType: "report"}}
+		other.Name = "reports/a.tar.gz"
+		export.Files = append(export.Files, other)
+
+		count, err := newSource().Pull(context.Background(), export, bundleFile, EmptyOptions())
+		Expect(err).To(HaveOccurred())
+		Expect(count).To(Equal(1))
+		Expect(export.Files).To(HaveLen(2))
+	})
+})
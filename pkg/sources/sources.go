@@ -7,6 +7,7 @@ import (
 
 	"github.com/redhat-marketplace/datactl/pkg/bundle"
 	"github.com/redhat-marketplace/datactl/pkg/datactl/api"
+	dataservicev1 "github.com/redhat-marketplace/datactl/pkg/datactl/api/dataservice/v1"
 	"github.com/redhat-marketplace/datactl/pkg/datactl/config"
 	"github.com/redhat-marketplace/datactl/pkg/printers"
 )
@@ -52,25 +53,14 @@ type sourceFactory struct {
 	printer        printers.Printer
 }
 
+// FromSource creates the source with the constructor registered for its type.
 func (s *sourceFactory) FromSource(source api.Source) (Source, error) {
-	switch source.Type {
-	case api.DataService:
-		dataService, err := s.rhmConfigFlags.DataServiceClient(source)
-		if err != nil {
-			return nil, err
-		}
-
-		return NewDataService(dataService, s.printer)
-	case api.ILMT:
-		ilmt, err := s.rhmConfigFlags.IlmtClient(source)
-		if err != nil {
-			return nil, err
-		}
-
-		return NewIlmtSource(ilmt, s.printer)
+	r, err := Lookup(source.Type)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, fmt.Errorf("sourceType %s not found", source.Type)
+	return r.New(s.rhmConfigFlags, s.printer, source)
 }
 
 type SourceFactoryBuilder struct {
@@ -136,6 +126,33 @@ func NewOptions(key string, value interface{}, fields ...interface{}) GenericOpt
 	return &Options{opts: opts}
 }
 
+// WithOptions returns a copy of opts with the fields added, like the fields of
+// NewOptions. Fields already set in opts are kept.
+func WithOptions(opts GenericOptions, fields ...interface{}) GenericOptions {
+	out := &Options{opts: make(map[string]interface{})}
+
+	if o, ok := opts.(*Options); ok {
+		for k, v := range o.opts {
+			out.opts[k] = v
+		}
+	}
+
+	if len(fields) > 0 && len(fields)%2 == 0 {
+		for i := 0; i < len(fields); i = i + 2 {
+			key, ok := fields[i].(string)
+			if !ok {
+				continue
+			}
+
+			if _, ok := out.opts[key]; !ok {
+				out.opts[key] = fields[i+1]
+			}
+		}
+	}
+
+	return out
+}
+
 func (o *Options) GetString(name string) (string, bool, error) {
 	if v, ok := o.opts[name]; ok {
 		s, ok := v.(string)
@@ -195,3 +212,80 @@ func (o *Options) Get(name string) (interface{}, bool, error) {
 
 	return "", false, nil
 }
+
+// commitOptions are the options of a commit shared by the source types.
+type commitOptions struct {
+	dryRun bool
+
+	// force commits the files that are not pushed
+	force bool
+
+	// names and ids select the files to commit, all of them if both are empty
+	names, ids map[string]bool
+}
+
+func commitOptionsFrom(opts GenericOptions) (*commitOptions, error) {
+	c := &commitOptions{}
+
+	var err error
+	c.dryRun, _, err = opts.GetBool(DryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	c.force, _, err = opts.GetBool(Force)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.names, err = stringSet(opts, CommitFileNames); err != nil {
+		return nil, err
+	}
+
+	if c.ids, err = stringSet(opts, CommitFileIDs); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func stringSet(opts GenericOptions, name string) (map[string]bool, error) {
+	v, ok, err := opts.Get(name)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	values, ok := v.([]string)
+	if !ok {
+		return nil, fmt.Errorf("failed to convert type %T to []string", v)
+	}
+
+	set := map[string]bool{}
+	for _, value := range values {
+		set[value] = true
+	}
+
+	return set, nil
+}
+
+// selected returns whether the file is selected for the commit.
+func (c *commitOptions) selected(file *dataservicev1.FileInfoCTLAction) bool {
+	if len(c.names) == 0 && len(c.ids) == 0 {
+		return true
+	}
+
+	return c.names[file.Name] || (file.Id != "" && c.ids[file.Id])
+}
+
+// skip marks a file that is not pushed as skipped, unless the commit is
+// forced, so the source keeps the files that didn't reach the upload API.
+func (c *commitOptions) skip(file *dataservicev1.FileInfoCTLAction) bool {
+	if file.Pushed || c.force {
+		return false
+	}
+
+	file.Action = dataservicev1.Commit
+	file.Result = dataservicev1.Skipped
+	file.Error = "file is not pushed"
+	return true
+}